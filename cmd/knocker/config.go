@@ -0,0 +1,94 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/FarisZR/knocker-cli/internal/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect the Knocker configuration",
+	Long:  `Commands for validating and displaying the effective Knocker configuration.`,
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Validate the effective configuration",
+	Long:  `Checks the config file, environment and flags for unknown keys, type errors, invalid URLs, negative values and missing required settings.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		issues := config.Validate(viper.GetViper())
+		if err := config.LoadError(); err != nil {
+			loadIssue := config.Issue{Severity: config.SeverityError, Message: fmt.Sprintf("unable to read config file: %v", err)}
+			issues = append([]config.Issue{loadIssue}, issues...)
+		}
+
+		result := configValidateResult{Valid: !config.HasErrors(issues), Issues: issues}
+		if !jsonOutput() {
+			for _, issue := range issues {
+				fmt.Println(issue)
+			}
+		}
+
+		if !result.Valid {
+			exitWithErrorResult(cmd, result, newConfigError(errors.New("configuration is invalid")))
+		}
+
+		emitResult(cmd, result, "Configuration is valid.")
+	},
+}
+
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Show the effective configuration and where each value came from",
+	Long:  `Prints every setting Knocker uses along with its source (flag, env, file or default). Secrets are redacted.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		result := configShowResult{
+			ConfigFile: viper.ConfigFileUsed(),
+			Settings: config.Describe(viper.GetViper(), func(name string) bool {
+				return explicitFlags[name]
+			}),
+		}
+
+		if jsonOutput() {
+			emitResult(cmd, result, "")
+			return
+		}
+
+		if result.ConfigFile != "" {
+			fmt.Printf("Config file: %s\n\n", result.ConfigFile)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "KEY\tVALUE\tSOURCE")
+		for _, setting := range result.Settings {
+			value := setting.Value
+			if value == "" {
+				value = "-"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", setting.Key, value, setting.Source)
+		}
+		w.Flush()
+	},
+}
+
+type configValidateResult struct {
+	Valid  bool           `json:"valid"`
+	Issues []config.Issue `json:"issues"`
+}
+
+type configShowResult struct {
+	ConfigFile string           `json:"config_file,omitempty"`
+	Settings   []config.Setting `json:"settings"`
+}
+
+func init() {
+	configCmd.AddCommand(configValidateCmd)
+	configCmd.AddCommand(configShowCmd)
+	rootCmd.AddCommand(configCmd)
+}
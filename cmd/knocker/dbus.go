@@ -0,0 +1,49 @@
+package main
+
+import (
+	"github.com/FarisZR/knocker-cli/internal/config"
+	"github.com/FarisZR/knocker-cli/internal/dbusapi"
+	"github.com/FarisZR/knocker-cli/internal/notify"
+	internalService "github.com/FarisZR/knocker-cli/internal/service"
+	"github.com/godbus/dbus/v5"
+)
+
+// startDBusServer exports svc on the session bus (the `dbus.enabled`
+// setting) and keeps its properties in step with the service status. The
+// returned function releases the bus name and closes the connection.
+func startDBusServer(svc *internalService.Service) (func(), error) {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return nil, err
+	}
+
+	server, err := dbusapi.Export(conn, svc)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	svc.OnStatusChange = server.Update
+
+	return func() {
+		if err := server.Close(); err != nil {
+			logger.Warn("Releasing D-Bus name failed", "error", err)
+		}
+		conn.Close()
+	}, nil
+}
+
+// startNotifier connects to the session bus for desktop notifications (the
+// `notifications` settings). The returned function cancels pending warnings
+// and closes the connection.
+func startNotifier(cfg config.Notifications) (*notify.Notifier, func(), error) {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	notifier := notify.New(cfg, notify.NewDBusSender(conn), logger)
+	return notifier, func() {
+		notifier.Close()
+		conn.Close()
+	}, nil
+}
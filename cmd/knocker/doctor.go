@@ -0,0 +1,175 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/FarisZR/knocker-cli/internal/api"
+	"github.com/FarisZR/knocker-cli/internal/config"
+	"github.com/FarisZR/knocker-cli/internal/doctor"
+	"github.com/FarisZR/knocker-cli/internal/util"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Diagnose configuration, connectivity and service setup",
+	Long: `Runs end-to-end diagnostics: config resolution, DNS and TLS for the API host, the /health endpoint,
+the IP checker, journald availability, the installed service unit and systemd linger status. With --knock
+it also sends an authenticated knock, which whitelists this machine's address. Each check reports pass,
+warn, fail or skip.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		knock, _ := cmd.Flags().GetBool("knock")
+
+		report := runDoctor(knock)
+
+		if !jsonOutput() {
+			for _, check := range report.Checks {
+				fmt.Printf("[%s] %-9s %s\n", strings.ToUpper(string(check.Status)), check.Name, check.Detail)
+			}
+			counts := report.Counts()
+			fmt.Printf("\n%d passed, %d warnings, %d failed, %d skipped\n", counts[doctor.StatusPass], counts[doctor.StatusWarn], counts[doctor.StatusFail], counts[doctor.StatusSkip])
+		}
+
+		if report.Failed() {
+			exitWithErrorResult(cmd, report, &cliError{code: errorCodeChecksFailed, exitCode: exitCodeChecksFailed, err: errors.New("one or more checks failed")})
+		}
+
+		emitResult(cmd, report, "")
+	},
+}
+
+func init() {
+	doctorCmd.Flags().Bool("knock", false, "also send an authenticated knock, whitelisting this machine's address")
+	rootCmd.AddCommand(doctorCmd)
+}
+
+func runDoctor(knock bool) *doctor.Report {
+	report := &doctor.Report{}
+
+	configOK := true
+	if err := config.LoadError(); err != nil {
+		report.Add(doctor.Result{Name: "config", Status: doctor.StatusFail, Detail: fmt.Sprintf("unable to read config file: %v", err)})
+		configOK = false
+	} else {
+		report.Add(checkConfig())
+	}
+
+	apiURL := viper.GetString("api_url")
+	apiKey := viper.GetString("api_key")
+	if apiURL == "" || apiKey == "" {
+		configOK = false
+	}
+
+	report.Add(doctor.CheckDNS(apiURL))
+	report.Add(doctor.CheckTLS(apiURL, nil, time.Now()))
+
+	if configOK {
+		client := api.NewClient(apiURL, apiKey)
+		health := doctor.CheckHealth(client)
+		report.Add(health)
+
+		switch {
+		case !knock:
+			report.Add(doctor.Result{Name: "knock", Status: doctor.StatusSkip, Detail: "not sent; use --knock to test an authenticated knock (it whitelists this address)"})
+		case health.Status == doctor.StatusFail:
+			report.Add(doctor.Result{Name: "knock", Status: doctor.StatusSkip, Detail: "API health check failed"})
+		default:
+			report.Add(doctor.CheckKnock(client, viper.GetInt("ttl")))
+		}
+	} else {
+		report.Add(doctor.Result{Name: "health", Status: doctor.StatusSkip, Detail: "api_url and api_key must be configured"})
+		report.Add(doctor.Result{Name: "knock", Status: doctor.StatusSkip, Detail: "api_url and api_key must be configured"})
+	}
+
+	report.Add(doctor.CheckIPLookup(util.NewIPGetter(), viper.GetString("ip_check_url")))
+	report.Add(doctor.CheckJournald())
+	report.Add(checkUnitFile())
+	report.Add(doctor.CheckLinger())
+
+	return report
+}
+
+func checkConfig() doctor.Result {
+	issues := config.Validate(viper.GetViper())
+	source := viper.ConfigFileUsed()
+	if source == "" {
+		source = "environment and flags only"
+	}
+
+	if len(issues) == 0 {
+		return doctor.Result{Name: "config", Status: doctor.StatusPass, Detail: fmt.Sprintf("configuration valid (%s)", source)}
+	}
+
+	messages := make([]string, 0, len(issues))
+	for _, issue := range issues {
+		messages = append(messages, issue.String())
+	}
+
+	status := doctor.StatusWarn
+	if config.HasErrors(issues) {
+		status = doctor.StatusFail
+	}
+	return doctor.Result{Name: "config", Status: status, Detail: strings.Join(messages, "; ")}
+}
+
+// checkUnitFile compares the installed systemd user unit with the unit that
+// `knocker install` would write today, so stale installs can be spotted.
+func checkUnitFile() doctor.Result {
+	const name = "unit"
+
+	if runtime.GOOS != "linux" {
+		return doctor.Result{Name: name, Status: doctor.StatusSkip, Detail: "unit file check only applies to systemd"}
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return doctor.Result{Name: name, Status: doctor.StatusSkip, Detail: fmt.Sprintf("unable to determine home directory: %v", err)}
+	}
+
+	path := filepath.Join(home, ".config", "systemd", "user", serviceName+".service")
+	installed, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return doctor.Result{Name: name, Status: doctor.StatusWarn, Detail: "service not installed (run `knocker install`)"}
+	}
+	if err != nil {
+		return doctor.Result{Name: name, Status: doctor.StatusFail, Detail: err.Error()}
+	}
+
+	drift := unitDrift(string(installed), systemdUserUnitTemplate(restartSecondsFromConfig()))
+	if executable, err := os.Executable(); err == nil && !strings.Contains(string(installed), "ExecStart="+strings.ReplaceAll(executable, " ", `\x20`)) {
+		drift = append(drift, "ExecStart does not point at "+executable)
+	}
+
+	if len(drift) > 0 {
+		return doctor.Result{Name: name, Status: doctor.StatusWarn, Detail: fmt.Sprintf("%s differs from the current template (%s); reinstall with `knocker uninstall && knocker install`", path, strings.Join(drift, "; "))}
+	}
+	return doctor.Result{Name: name, Status: doctor.StatusPass, Detail: fmt.Sprintf("%s matches the current template", path)}
+}
+
+// unitDrift returns the static lines of template (those without template
+// actions) that are missing from the installed unit.
+func unitDrift(installed, template string) []string {
+	present := map[string]bool{}
+	for _, line := range strings.Split(installed, "\n") {
+		present[strings.TrimSpace(line)] = true
+	}
+
+	var missing []string
+	for _, line := range strings.Split(template, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.Contains(line, "{{") {
+			continue
+		}
+		if !present[line] {
+			missing = append(missing, "missing "+line)
+		}
+	}
+	return missing
+}
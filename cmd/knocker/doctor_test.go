@@ -0,0 +1,17 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnitDriftDetectsChangedDirectives(t *testing.T) {
+	template := systemdUserUnitTemplate(30)
+
+	require.Empty(t, unitDrift(template, template))
+
+	stale := systemdUserUnitTemplate(15)
+	drift := unitDrift(stale, template)
+	require.Equal(t, []string{"missing RestartSec=30"}, drift)
+}
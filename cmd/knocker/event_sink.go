@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/FarisZR/knocker-cli/internal/config"
+	"github.com/FarisZR/knocker-cli/internal/events"
+	"github.com/FarisZR/knocker-cli/internal/journald"
+	internalService "github.com/FarisZR/knocker-cli/internal/service"
+	"github.com/kardianos/service"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// eventSink receives the structured events emitted by the CLI and the
+// service. It is configured from the `events` settings in PersistentPreRun,
+// for the commands that emit events (see emitsEvents).
+var eventSink events.EventSink = events.JournaldSink{}
+
+// emitsEventsAnnotation marks the commands that emit events, so the sinks
+// are only opened (syslog dialled, webhooks started) when they are used.
+const emitsEventsAnnotation = "knocker/emits-events"
+
+// emitsEvents reports whether cmd emits events. The root command does when
+// it runs as the service.
+func emitsEvents(cmd *cobra.Command) bool {
+	if !cmd.HasParent() {
+		return !service.Interactive()
+	}
+	return cmd.Annotations[emitsEventsAnnotation] == "true"
+}
+
+// newEventSink builds the sinks selected by the configuration: journald
+// (events.journald, on by default), a JSON-lines stream (events.jsonl set to
+// "-" for stdout or to a file path), RFC 5424 syslog (events.syslog), any
+// configured webhooks and the hook commands. Sinks that cannot be opened are
+// skipped with a warning.
+//
+// Events reach the sinks in the schema selected by events.schema. With
+// "both", hooks only run for the v2 copy so every hook still runs once.
+func newEventSink(v *viper.Viper) events.EventSink {
+	schema := v.GetString("events.schema")
+	if err := events.ValidateSchema(schema); err != nil {
+		logger.Warn("Invalid event schema; using the default", "error", err, "schema_version", events.DefaultSchema)
+		schema = events.DefaultSchema
+	}
+
+	var sinks events.MultiSink
+	if v.GetBool("events.journald") {
+		sinks = append(sinks, events.JournaldSink{})
+	}
+
+	switch target := v.GetString("events.jsonl"); target {
+	case "":
+	case "-", "stdout":
+		// Keep stdout free for the JSON document when --output json is used.
+		out := os.Stdout
+		if jsonOutput() {
+			out = os.Stderr
+		}
+		sinks = append(sinks, events.NewJSONLinesSink(out))
+	default:
+		sink, err := events.OpenJSONLinesFile(target)
+		if err != nil {
+			logger.Warn("Unable to open event log", "path", target, "error", err)
+			break
+		}
+		sinks = append(sinks, sink)
+	}
+
+	if cfg, ok, err := config.LoadSyslog(v); err != nil {
+		logger.Warn("Syslog output disabled", "error", err)
+	} else if ok {
+		sink, err := events.NewSyslogSink(cfg, func(err error) {
+			logger.Warn("Syslog server unreachable", "error", err)
+		})
+		if err != nil {
+			logger.Warn("Unable to connect to syslog", "error", err)
+		} else {
+			sinks = append(sinks, sink)
+		}
+	}
+
+	webhooks, errs := config.Webhooks(v)
+	for _, err := range errs {
+		logger.Warn("Invalid webhook skipped", "error", err)
+	}
+	for _, webhook := range webhooks {
+		sink, err := events.NewWebhookSink(webhook, func(err error) {
+			logger.Warn("Webhook delivery failed", "error", err)
+		})
+		if err != nil {
+			logger.Warn("Webhook disabled", "url", config.RedactURL(webhook.URL), "error", err)
+			continue
+		}
+		sinks = append(sinks, sink)
+	}
+
+	routed := events.MultiSink{events.NewSchemaSink(sinks, schema)}
+
+	hooks, err := config.LoadHooks(v)
+	if err != nil {
+		logger.Warn("Hooks disabled", "error", err)
+	} else if len(hooks.Commands) > 0 {
+		// Failures go to the other sinks only, so an Error hook that fails
+		// cannot trigger itself.
+		reportTo := routed[0]
+		hookSink := events.NewHookSink(hooks, logger, func(failure events.HookFailure) {
+			_ = reportTo.Emit(hookFailedEvent(failure))
+		})
+		routed = append(routed, events.NewSchemaSink(hookSink, singleSchema(schema)))
+	}
+
+	return routed
+}
+
+// singleSchema returns the schema version for sinks that must see every
+// event once, such as hooks: v2 when events.schema is "both".
+func singleSchema(mode string) string {
+	switch {
+	case mode == events.SchemaBoth:
+		return events.SchemaV2
+	case events.ValidateSchema(mode) != nil:
+		return events.DefaultSchema
+	}
+	return mode
+}
+
+func hookFailedEvent(failure events.HookFailure) events.Event {
+	msg := fmt.Sprintf("Hook %q for %s failed: %v", failure.Command, failure.Event.Type, failure.Err)
+	fields := journald.Fields{
+		"KNOCKER_ERROR_CODE":   internalService.ErrorCodeHookFailed,
+		"KNOCKER_ERROR_MSG":    msg,
+		"KNOCKER_CONTEXT":      failure.Event.Type,
+		"KNOCKER_HOOK_COMMAND": failure.Command,
+	}
+	return events.New(internalService.EventError, msg, journald.PriErr, fields)
+}
+
+// closeEventSink flushes pending deliveries (such as queued webhooks) and
+// releases open files. It must run before the process exits.
+func closeEventSink() {
+	if closer, ok := eventSink.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			logger.Warn("Closing event sinks failed", "error", err)
+		}
+	}
+}
+
+// emitCLIEvent sends an event raised by a CLI command to the configured sinks.
+func emitCLIEvent(eventType, message string, priority journald.Priority, fields journald.Fields) {
+	_ = eventSink.Emit(events.New(eventType, message, priority, fields))
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/FarisZR/knocker-cli/internal/events"
+	"github.com/FarisZR/knocker-cli/internal/journald"
+	internalService "github.com/FarisZR/knocker-cli/internal/service"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// eventsFollowPoll is how often `events --follow` checks a JSON-lines file
+// for new entries.
+var eventsFollowPoll = 500 * time.Millisecond
+
+var eventsCmd = &cobra.Command{
+	Use:   "events",
+	Short: "Show the structured event history",
+	Long: `Reads Knocker's structured events and prints them as a timeline.
+
+Events are read from the user journal where journald is available, otherwise from the
+JSON-lines file configured with events.jsonl (or given with --file).`,
+	Run: func(cmd *cobra.Command, args []string) {
+		follow, _ := cmd.Flags().GetBool("follow")
+		sinceFlag, _ := cmd.Flags().GetString("since")
+		types, _ := cmd.Flags().GetStringSlice("type")
+		jsonLines, _ := cmd.Flags().GetBool("json")
+		file, _ := cmd.Flags().GetString("file")
+
+		filter := events.Filter{Types: types}
+		if viper.GetString("events.schema") == events.SchemaBoth {
+			filter.SchemaVersion = events.SchemaV2
+		}
+		if sinceFlag != "" {
+			since, err := parseSince(sinceFlag, time.Now())
+			if err != nil {
+				exitWithError(cmd, newConfigError(err))
+			}
+			filter.Since = since
+		}
+
+		path, err := eventsSource(file)
+		if err != nil {
+			exitWithError(cmd, newConfigError(err))
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		// A single JSON document cannot be streamed, so --follow with
+		// --output json falls back to one JSON object per line.
+		collect := jsonOutput() && !follow
+		var collected []eventRecord
+		show := func(event events.Event) error {
+			record := newEventRecord(event)
+			switch {
+			case collect:
+				collected = append(collected, record)
+				return nil
+			case jsonLines || jsonOutput():
+				return json.NewEncoder(os.Stdout).Encode(record)
+			default:
+				_, err := fmt.Println(record.String())
+				return err
+			}
+		}
+
+		if path == "" {
+			err = readJournal(ctx, filter, follow, show)
+		} else if follow {
+			err = events.FollowFile(ctx, path, filter, eventsFollowPoll, show)
+		} else {
+			err = readEventsFile(path, filter, show)
+		}
+		if err != nil {
+			exitWithError(cmd, err)
+		}
+
+		if collect {
+			if collected == nil {
+				collected = []eventRecord{}
+			}
+			emitResult(cmd, eventsResult{Events: collected}, "")
+		}
+	},
+}
+
+func init() {
+	eventsCmd.Flags().BoolP("follow", "f", false, "keep printing new events as they are written")
+	eventsCmd.Flags().String("since", "", "only show events after this time: a duration such as 2h, or a date/time such as 2025-06-14 or 2025-06-14T10:00:00Z")
+	eventsCmd.Flags().StringSlice("type", nil, "only show events of these types, e.g. WhitelistApplied (repeatable)")
+	eventsCmd.Flags().Bool("json", false, "print one JSON object per event")
+	eventsCmd.Flags().String("file", "", "read this JSON-lines event file instead of the journal")
+	eventsCmd.AddCommand(eventsSchemaCmd)
+	rootCmd.AddCommand(eventsCmd)
+}
+
+var eventsSchemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Print the machine-readable event schema",
+	Long: `Prints a JSON description of every structured event type and its KNOCKER_* fields,
+including the schema version each field was introduced in.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		schema := internalService.Schema()
+		encoded, err := json.MarshalIndent(schema, "", "  ")
+		if err != nil {
+			exitWithError(cmd, err)
+		}
+		emitResult(cmd, schema, string(encoded))
+	},
+}
+
+// eventRecord is the decoded form of an event printed by `knocker events`.
+// The cycle fields are only present on schema v2 events.
+type eventRecord struct {
+	Time     time.Time   `json:"time"`
+	Type     string      `json:"type"`
+	Message  string      `json:"message"`
+	Priority int         `json:"priority"`
+	CycleID  string      `json:"cycle_id,omitempty"`
+	Attempt  int         `json:"attempt,omitempty"`
+	Profile  string      `json:"profile,omitempty"`
+	Data     interface{} `json:"data"`
+}
+
+func newEventRecord(event events.Event) eventRecord {
+	attempt, _ := strconv.Atoi(event.Fields["KNOCKER_ATTEMPT"])
+	return eventRecord{
+		Time:     event.Time,
+		Type:     event.Type,
+		Message:  event.Message,
+		Priority: int(event.Priority),
+		CycleID:  event.Fields["KNOCKER_CYCLE_ID"],
+		Attempt:  attempt,
+		Profile:  event.Fields["KNOCKER_PROFILE"],
+		Data:     internalService.DecodeEventData(event),
+	}
+}
+
+func (r eventRecord) String() string {
+	marker := " "
+	if r.Priority <= int(journald.PriErr) {
+		marker = "!"
+	}
+	return fmt.Sprintf("%s %s %-16s %s", r.Time.Local().Format("2006-01-02 15:04:05"), marker, r.Type, r.Message)
+}
+
+type eventsResult struct {
+	Events []eventRecord `json:"events"`
+}
+
+// eventsSource picks where to read events from: the given file, the journal
+// when journald is in use, or the configured JSON-lines file. An empty path
+// means the journal.
+func eventsSource(file string) (string, error) {
+	if file != "" {
+		return file, nil
+	}
+	if viper.GetBool("events.journald") && journald.Enabled() {
+		return "", nil
+	}
+	switch target := viper.GetString("events.jsonl"); target {
+	case "", "-", "stdout":
+		return "", errors.New("no event history available: journald is not in use and events.jsonl is not a file; set events.jsonl to a file path or pass --file")
+	default:
+		return target, nil
+	}
+}
+
+func readEventsFile(path string, filter events.Filter, fn func(events.Event) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return events.ReadRecords(f, filter, fn)
+}
+
+// readJournal reads Knocker's entries from the user journal via journalctl.
+func readJournal(ctx context.Context, filter events.Filter, follow bool, fn func(events.Event) error) error {
+	journalctl := exec.CommandContext(ctx, "journalctl", journalctlArgs(filter.Since, follow)...)
+	journalctl.Stderr = os.Stderr
+	stdout, err := journalctl.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := journalctl.Start(); err != nil {
+		return fmt.Errorf("running journalctl: %w", err)
+	}
+
+	readErr := events.ReadRecords(stdout, filter, fn)
+	waitErr := journalctl.Wait()
+	if ctx.Err() != nil {
+		return nil
+	}
+	if readErr != nil {
+		return readErr
+	}
+	if waitErr != nil {
+		return fmt.Errorf("journalctl: %w", waitErr)
+	}
+	return nil
+}
+
+func journalctlArgs(since time.Time, follow bool) []string {
+	args := []string{"--user", "--identifier", "knocker", "--output", "json", "--no-pager"}
+	if !since.IsZero() {
+		args = append(args, "--since", "@"+strconv.FormatInt(since.Unix(), 10))
+	}
+	if follow {
+		args = append(args, "--follow")
+	}
+	return args
+}
+
+// parseSince accepts a duration relative to now (including whole days such
+// as 7d) or an absolute date/time.
+func parseSince(value string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+	if days, err := strconv.Atoi(strings.TrimSuffix(value, "d")); err == nil && strings.HasSuffix(value, "d") {
+		return now.AddDate(0, 0, -days), nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid --since %q: use a duration such as 2h or 7d, or a date such as 2025-06-14", value)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/FarisZR/knocker-cli/internal/events"
+	"github.com/FarisZR/knocker-cli/internal/journald"
+	internalService "github.com/FarisZR/knocker-cli/internal/service"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2025, 6, 14, 12, 0, 0, 0, time.UTC)
+
+	since, err := parseSince("2h", now)
+	require.NoError(t, err)
+	require.Equal(t, now.Add(-2*time.Hour), since)
+
+	since, err = parseSince("7d", now)
+	require.NoError(t, err)
+	require.Equal(t, now.AddDate(0, 0, -7), since)
+
+	since, err = parseSince("2025-06-14T10:00:00Z", now)
+	require.NoError(t, err)
+	require.True(t, since.Equal(time.Date(2025, 6, 14, 10, 0, 0, 0, time.UTC)))
+
+	since, err = parseSince("2025-06-13", now)
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2025, 6, 13, 0, 0, 0, 0, time.Local), since)
+
+	_, err = parseSince("yesterday", now)
+	require.Error(t, err)
+}
+
+func TestJournalctlArgs(t *testing.T) {
+	require.Equal(t,
+		[]string{"--user", "--identifier", "knocker", "--output", "json", "--no-pager", "--since", "@1750000000", "--follow"},
+		journalctlArgs(time.Unix(1750000000, 0), true))
+	require.NotContains(t, journalctlArgs(time.Time{}, false), "--since")
+}
+
+func TestEventRecordDecodesFields(t *testing.T) {
+	event := events.New(internalService.EventKnockTriggered, "Knock triggered via cli: failure", journald.PriErr, journald.Fields{
+		"KNOCKER_TRIGGER_SOURCE": "cli",
+		"KNOCKER_RESULT":         "failure",
+	})
+
+	record := newEventRecord(event)
+
+	require.Equal(t, internalService.KnockTriggeredData{Source: "cli", Result: "failure"}, record.Data)
+	require.Contains(t, record.String(), "! KnockTriggered")
+	require.Contains(t, record.String(), "Knock triggered via cli: failure")
+}
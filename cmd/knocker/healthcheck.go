@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var healthcheckCmd = &cobra.Command{
+	Use:   "healthcheck",
+	Short: "Check the running service through its local HTTP listener",
+	Long: `Queries /readyz on the listener configured with http.listen and exits non-zero
+unless a whitelist is currently active. With --live only /healthz is queried, which
+succeeds as long as the service process is up. Suitable for a Docker HEALTHCHECK.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		live, _ := cmd.Flags().GetBool("live")
+		timeout, _ := cmd.Flags().GetDuration("timeout")
+
+		target, err := healthcheckURL(viper.GetString("http.listen"), live)
+		if err != nil {
+			exitWithError(cmd, newConfigError(err))
+		}
+
+		result, err := runHealthcheck(&http.Client{Timeout: timeout}, target)
+		if err != nil {
+			exitWithErrorResult(cmd, result, err)
+		}
+		emitResult(cmd, result, fmt.Sprintf("%s: healthy", target))
+	},
+}
+
+func init() {
+	healthcheckCmd.Flags().Bool("live", false, "only check that the service is running (/healthz) instead of whitelisted (/readyz)")
+	healthcheckCmd.Flags().Duration("timeout", 5*time.Second, "how long to wait for the listener")
+	rootCmd.AddCommand(healthcheckCmd)
+}
+
+type healthcheckResult struct {
+	URL        string `json:"url"`
+	StatusCode int    `json:"status_code,omitempty"`
+	Healthy    bool   `json:"healthy"`
+}
+
+// healthcheckURL builds the URL to probe from the http.listen address. A
+// wildcard bind address is reached through loopback.
+func healthcheckURL(listen string, live bool) (string, error) {
+	if listen == "" {
+		return "", errors.New("http.listen is not set; the service has no HTTP listener to check")
+	}
+
+	host, port, err := net.SplitHostPort(listen)
+	if err != nil {
+		return "", fmt.Errorf("invalid http.listen %q: %w", listen, err)
+	}
+	switch host {
+	case "", "0.0.0.0":
+		host = "127.0.0.1"
+	case "::":
+		host = "::1"
+	}
+
+	path := "/readyz"
+	if live {
+		path = "/healthz"
+	}
+	return "http://" + net.JoinHostPort(host, port) + path, nil
+}
+
+func runHealthcheck(client *http.Client, target string) (healthcheckResult, error) {
+	result := healthcheckResult{URL: target}
+
+	res, err := client.Get(target)
+	if err != nil {
+		return result, newServiceError(fmt.Errorf("service unreachable: %w", err))
+	}
+	res.Body.Close()
+
+	result.StatusCode = res.StatusCode
+	if res.StatusCode != http.StatusOK {
+		return result, &cliError{code: errorCodeChecksFailed, exitCode: exitCodeChecksFailed, err: fmt.Errorf("%s returned status %d", target, res.StatusCode)}
+	}
+
+	result.Healthy = true
+	return result, nil
+}
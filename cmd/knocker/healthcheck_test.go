@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/FarisZR/knocker-cli/internal/metrics"
+	internalService "github.com/FarisZR/knocker-cli/internal/service"
+	"github.com/stretchr/testify/require"
+)
+
+type fixedStatus internalService.Status
+
+func (f fixedStatus) Status() internalService.Status {
+	return internalService.Status(f)
+}
+
+func TestHTTPHandlerReadiness(t *testing.T) {
+	initLogger(t)
+
+	notReady := httptest.NewServer(newHTTPHandler(metrics.New(), fixedStatus{}))
+	defer notReady.Close()
+
+	res, err := http.Get(notReady.URL + "/healthz")
+	require.NoError(t, err)
+	res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	res, err = http.Get(notReady.URL + "/readyz")
+	require.NoError(t, err)
+	res.Body.Close()
+	require.Equal(t, http.StatusServiceUnavailable, res.StatusCode)
+
+	expires := time.Now().Add(time.Hour).Unix()
+	ready := httptest.NewServer(newHTTPHandler(metrics.New(), fixedStatus{
+		Whitelisted: true,
+		WhitelistIP: "203.0.113.10",
+		ExpiresUnix: expires,
+	}))
+	defer ready.Close()
+
+	res, err = http.Get(ready.URL + "/readyz")
+	require.NoError(t, err)
+	res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	res, err = http.Get(ready.URL + "/status")
+	require.NoError(t, err)
+	defer res.Body.Close()
+	var doc map[string]interface{}
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&doc))
+	require.Equal(t, "203.0.113.10", doc["whitelist_ip"])
+	require.Equal(t, float64(expires), doc["expires_unix"])
+	require.Equal(t, true, doc["ready"])
+}
+
+func TestHealthcheckURL(t *testing.T) {
+	target, err := healthcheckURL("0.0.0.0:9464", false)
+	require.NoError(t, err)
+	require.Equal(t, "http://127.0.0.1:9464/readyz", target)
+
+	target, err = healthcheckURL("[::]:9464", true)
+	require.NoError(t, err)
+	require.Equal(t, "http://[::1]:9464/healthz", target)
+
+	_, err = healthcheckURL("", false)
+	require.Error(t, err)
+}
+
+func TestRunHealthcheckClassifiesFailures(t *testing.T) {
+	server := httptest.NewServer(newHTTPHandler(metrics.New(), fixedStatus{}))
+	defer server.Close()
+	client := &http.Client{Timeout: time.Second}
+
+	result, err := runHealthcheck(client, server.URL+"/healthz")
+	require.NoError(t, err)
+	require.True(t, result.Healthy)
+
+	result, err = runHealthcheck(client, server.URL+"/readyz")
+	var classified *cliError
+	require.True(t, errors.As(err, &classified))
+	require.Equal(t, exitCodeChecksFailed, classified.exitCode)
+	require.Equal(t, http.StatusServiceUnavailable, result.StatusCode)
+
+	server.Close()
+	_, err = runHealthcheck(client, server.URL+"/healthz")
+	require.True(t, errors.As(err, &classified))
+	require.Equal(t, exitCodeService, classified.exitCode)
+}
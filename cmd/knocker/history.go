@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/FarisZR/knocker-cli/internal/config"
+	"github.com/FarisZR/knocker-cli/internal/history"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Show past knock attempts and statistics",
+	Long: `Lists the knock attempts recorded by the service and by knocker knock, newest last, followed by
+summary statistics over every matching attempt: success rate, IP changes and total time whitelisted.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		sinceFlag, _ := cmd.Flags().GetString("since")
+		limit, _ := cmd.Flags().GetInt("limit")
+
+		filter := history.Filter{}
+		filter.Source, _ = cmd.Flags().GetString("source")
+		filter.Result, _ = cmd.Flags().GetString("result")
+		filter.IP, _ = cmd.Flags().GetString("ip")
+		if sinceFlag != "" {
+			since, err := parseSince(sinceFlag, time.Now())
+			if err != nil {
+				exitWithError(cmd, newConfigError(err))
+			}
+			filter.Since = since
+		}
+
+		store, err := openHistory(viper.GetViper())
+		if err != nil {
+			exitWithError(cmd, newConfigError(err))
+		}
+		if store == nil {
+			exitWithError(cmd, newConfigError(fmt.Errorf("knock history is disabled (history.enabled is false)")))
+		}
+
+		records, err := store.Load()
+		if err != nil {
+			exitWithError(cmd, err)
+		}
+		records = filter.Apply(records)
+
+		result := historyResult{
+			Path:    store.Path(),
+			Records: records,
+			Stats:   history.Summarize(records, time.Now()),
+		}
+		if limit > 0 && len(result.Records) > limit {
+			result.Records = result.Records[len(result.Records)-limit:]
+		}
+		if result.Records == nil {
+			result.Records = []history.Record{}
+		}
+
+		emitResult(cmd, result, result.String())
+	},
+}
+
+func init() {
+	historyCmd.Flags().String("since", "", "only include attempts after this time: a duration such as 24h or 7d, or a date such as 2025-06-14")
+	historyCmd.Flags().String("source", "", "only include attempts from this trigger source (schedule or cli)")
+	historyCmd.Flags().String("result", "", "only include attempts with this result (success or failure)")
+	historyCmd.Flags().String("ip", "", "only include attempts that whitelisted this IP")
+	historyCmd.Flags().Int("limit", 20, "how many of the most recent attempts to list (0 for all); statistics always cover every match")
+	rootCmd.AddCommand(historyCmd)
+}
+
+// openHistory returns the knock history store selected by the `history`
+// settings, or nil when history is disabled.
+func openHistory(v *viper.Viper) (*history.Store, error) {
+	if !v.GetBool("history.enabled") {
+		return nil, nil
+	}
+
+	path := v.GetString("history.path")
+	if path == "" {
+		dir, err := config.DefaultStateDir()
+		if err != nil {
+			return nil, err
+		}
+		path = filepath.Join(dir, "history.jsonl")
+	}
+	return history.Open(path, v.GetInt("history.max_records")), nil
+}
+
+type historyResult struct {
+	Path    string           `json:"path"`
+	Records []history.Record `json:"records"`
+	Stats   history.Stats    `json:"stats"`
+}
+
+func (r historyResult) String() string {
+	if r.Stats.Attempts == 0 {
+		return "No knock attempts recorded."
+	}
+
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tSOURCE\tRESULT\tIP\tTTL\tLATENCY\tERROR")
+	for _, record := range r.Records {
+		ttl := "-"
+		if record.TTLSeconds > 0 {
+			ttl = fmt.Sprintf("%ds", record.TTLSeconds)
+		}
+		ip := record.IP
+		if ip == "" {
+			ip = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%dms\t%s\n",
+			record.Time.Local().Format("2006-01-02 15:04:05"), record.Source, record.Result, ip, ttl, record.LatencyMS, record.ErrorCode)
+	}
+	w.Flush()
+
+	s := r.Stats
+	fmt.Fprintf(&buf, "\nAttempts: %d (%d succeeded, %d failed, %.1f%% success rate)\n", s.Attempts, s.Successes, s.Failures, s.SuccessRate*100)
+	if s.IPChanges > 0 {
+		fmt.Fprintf(&buf, "IP changes: %d (mean time between changes: %s)\n", s.IPChanges, s.MeanTimeBetweenIPChanges.Round(time.Minute))
+	} else {
+		fmt.Fprintln(&buf, "IP changes: 0")
+	}
+	fmt.Fprintf(&buf, "Time whitelisted: %s since %s", s.TotalWhitelisted.Round(time.Minute), s.First.Local().Format("2006-01-02 15:04"))
+	return buf.String()
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/FarisZR/knocker-cli/internal/metrics"
+	internalService "github.com/FarisZR/knocker-cli/internal/service"
+)
+
+// statusProvider exposes the running service's state to the HTTP handlers.
+type statusProvider interface {
+	Status() internalService.Status
+}
+
+// startHTTPServer serves the local HTTP endpoints on addr (the `http.listen`
+// setting). It binds before returning so a taken port is reported at startup.
+func startHTTPServer(addr string, m *metrics.Metrics, svc statusProvider) (*http.Server, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	server := &http.Server{
+		Handler:           newHTTPHandler(m, svc),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("HTTP listener stopped", "error", err)
+		}
+	}()
+	return server, nil
+}
+
+// newHTTPHandler routes the local endpoints:
+//
+//	/metrics  Prometheus metrics
+//	/healthz  the process is alive
+//	/readyz   a whitelist is currently active (503 otherwise)
+//	/status   the latest status snapshot as JSON
+func newHTTPHandler(m *metrics.Metrics, svc statusProvider) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m.Handler())
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok")
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		if !svc.Status().Ready(time.Now()) {
+			http.Error(w, "no active whitelist", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	})
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		status := svc.Status()
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(httpStatus{Status: status, Ready: status.Ready(time.Now())}); err != nil {
+			logger.Warn("Writing status failed", "error", err)
+		}
+	})
+	return mux
+}
+
+// httpStatus is the /status document.
+type httpStatus struct {
+	internalService.Status
+	Ready bool `json:"ready"`
+}
+
+func stopHTTPServer(server *http.Server) {
+	if server == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		logger.Warn("Stopping HTTP listener failed", "error", err)
+	}
+}
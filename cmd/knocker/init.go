@@ -0,0 +1,279 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/FarisZR/knocker-cli/internal/api"
+	"github.com/FarisZR/knocker-cli/internal/config"
+	"github.com/FarisZR/knocker-cli/internal/events"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"golang.org/x/term"
+)
+
+var initCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Interactively configure Knocker and install the service",
+	Long: `Prompts for the Knocker API URL and key, verifies them with a health check and a test knock,
+writes them to a private config file (or the systemd environment file) and optionally installs and
+starts the background service. Use --yes to run non-interactively; an existing file is then only
+replaced with --force.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		assumeYes, _ := cmd.Flags().GetBool("yes")
+		force, _ := cmd.Flags().GetBool("force")
+		useEnvFile, _ := cmd.Flags().GetBool("env-file")
+		install, _ := cmd.Flags().GetBool("install")
+		skipVerify, _ := cmd.Flags().GetBool("skip-verify")
+		path, _ := cmd.Flags().GetString("path")
+
+		// Prompts must not end up inside the JSON document.
+		promptOut := cmd.OutOrStdout()
+		if jsonOutput() {
+			promptOut = os.Stderr
+		}
+		p := newPrompter(cmd.InOrStdin(), promptOut, assumeYes)
+
+		apiURL, _ := cmd.Flags().GetString("api-url")
+		if apiURL == "" {
+			apiURL = viper.GetString("api_url")
+		}
+		apiURL = strings.TrimRight(p.ask("Knocker API URL", apiURL, false), "/")
+		if err := config.ValidateURL(apiURL); err != nil {
+			exitWithError(cmd, newConfigError(err))
+		}
+
+		apiKey, _ := cmd.Flags().GetString("api-key")
+		if apiKey == "" {
+			apiKey = viper.GetString("api_key")
+		}
+		apiKey = p.ask("Knocker API key", apiKey, true)
+		if apiKey == "" {
+			exitWithError(cmd, newConfigError(errors.New("an API key is required")))
+		}
+
+		result := initResult{Format: "yaml", Verified: !skipVerify}
+
+		ttl := viper.GetInt("ttl")
+		if !skipVerify {
+			knockResponse, err := verifyCredentials(api.NewClient(apiURL, apiKey), ttl)
+			if err != nil {
+				exitWithError(cmd, newAPIError(fmt.Errorf("verification failed: %w (use --skip-verify to write the config anyway)", err)))
+			}
+			result.Knock = knockResponse
+		}
+
+		if path == "" {
+			var err error
+			if path, err = defaultInitPath(useEnvFile); err != nil {
+				exitWithError(cmd, fmt.Errorf("unable to determine config location: %w", err))
+			}
+		}
+		if _, err := os.Stat(path); err == nil && !force {
+			// --yes accepts defaults; it does not consent to replacing a file.
+			if assumeYes {
+				exitWithError(cmd, fmt.Errorf("%s already exists, use --force to overwrite it", path))
+			}
+			if !p.confirm(fmt.Sprintf("%s already exists. Overwrite it?", path), false) {
+				exitWithError(cmd, errors.New("aborted, existing configuration left untouched"))
+			}
+		}
+
+		values := map[string]interface{}{
+			"api_url": apiURL,
+			"api_key": apiKey,
+		}
+		if ttl > 0 {
+			values["ttl"] = ttl
+		}
+		if ipCheckURL := viper.GetString("ip_check_url"); ipCheckURL != "" {
+			values["ip_check_url"] = ipCheckURL
+			values["check_interval"] = viper.GetInt("check_interval")
+		}
+
+		write := config.WriteFile
+		if useEnvFile {
+			write = config.WriteEnvFile
+			result.Format = "env"
+		}
+		if err := write(path, values); err != nil {
+			exitWithError(cmd, fmt.Errorf("failed to write configuration: %w", err))
+		}
+		result.Path = path
+		logger.Info("Configuration written", "path", path)
+
+		if !install && !assumeYes {
+			install = p.confirm("Install and start the Knocker service now?", true)
+		}
+		if install {
+			if err := installAndStartService(); err != nil {
+				exitWithErrorResult(cmd, result, newServiceError(err))
+			}
+			result.Installed = true
+		}
+
+		emitResult(cmd, result, "")
+	},
+}
+
+type initResult struct {
+	Path      string             `json:"path"`
+	Format    string             `json:"format"`
+	Verified  bool               `json:"verified"`
+	Installed bool               `json:"installed"`
+	Knock     *api.KnockResponse `json:"knock,omitempty"`
+}
+
+func init() {
+	initCmd.Flags().String("api-url", "", "Knocker API URL (defaults to the configured value)")
+	initCmd.Flags().String("api-key", "", "Knocker API key (defaults to the configured value)")
+	initCmd.Flags().String("path", "", "file to write (default is $HOME/.knocker.yaml, or $HOME/.config/knocker/env with --env-file)")
+	initCmd.Flags().Bool("env-file", false, "write the systemd environment file instead of the YAML config")
+	initCmd.Flags().Bool("install", false, "install and start the service after writing the config")
+	initCmd.Flags().Bool("skip-verify", false, "do not verify the credentials with a health check and test knock")
+	initCmd.Flags().BoolP("yes", "y", false, "do not prompt; accept provided and default values")
+	initCmd.Flags().Bool("force", false, "overwrite an existing config file without asking")
+	rootCmd.AddCommand(initCmd)
+}
+
+func defaultInitPath(useEnvFile bool) (string, error) {
+	if useEnvFile {
+		return config.DefaultEnvFilePath()
+	}
+	if config.CfgFile != "" {
+		return config.CfgFile, nil
+	}
+	return config.DefaultConfigPath()
+}
+
+func verifyCredentials(client *api.Client, ttl int) (*api.KnockResponse, error) {
+	logger.Info("Checking API health")
+	if err := client.HealthCheck(); err != nil {
+		return nil, fmt.Errorf("health check: %w", err)
+	}
+
+	logger.Info("Sending test knock")
+	cycle := &knockCycle{ID: events.NewCycleID(context.Background()), Attempt: 1}
+	start := time.Now()
+	knockResponse, err := client.Knock("", ttl)
+	cycle.Latency = time.Since(start)
+	if err != nil {
+		emitManualKnockFailure(cycle, err)
+		return nil, fmt.Errorf("test knock: %w", err)
+	}
+	emitManualKnockSuccess(cycle, knockResponse)
+
+	logger.Info("Test knock succeeded", "whitelist_ip", knockResponse.WhitelistedEntry, "ttl_sec", knockResponse.ExpiresInSeconds)
+	return knockResponse, nil
+}
+
+func installAndStartService() error {
+	s, err := newServiceInstance(true)
+	if err != nil {
+		return err
+	}
+
+	if err := s.Install(); err != nil {
+		logger.Warn("Could not install service", "error", err)
+	} else {
+		logger.Info("Service installed successfully")
+	}
+
+	if err := s.Start(); err != nil {
+		return fmt.Errorf("failed to start service: %w", err)
+	}
+	logger.Info("Service started successfully")
+	return nil
+}
+
+// prompter reads answers from the user, falling back to defaults when running
+// non-interactively.
+type prompter struct {
+	in        *bufio.Reader
+	out       io.Writer
+	assumeYes bool
+	// readSecret reads a line without echoing it; nil when the input is not
+	// a terminal.
+	readSecret func() (string, error)
+}
+
+func newPrompter(in io.Reader, out io.Writer, assumeYes bool) *prompter {
+	p := &prompter{in: bufio.NewReader(in), out: out, assumeYes: assumeYes}
+	if f, ok := in.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		p.readSecret = func() (string, error) {
+			answer, err := term.ReadPassword(int(f.Fd()))
+			// The newline typed by the user is not echoed either.
+			fmt.Fprintln(p.out)
+			return string(answer), err
+		}
+	}
+	return p
+}
+
+// ask prompts for a value, returning def when the answer is empty. Secret
+// defaults are not echoed back, and neither is a secret typed on a terminal.
+func (p *prompter) ask(label, def string, secret bool) string {
+	if p.assumeYes {
+		return def
+	}
+
+	shown := def
+	if secret && def != "" {
+		shown = "keep existing"
+	}
+	if shown != "" {
+		fmt.Fprintf(p.out, "%s [%s]: ", label, shown)
+	} else {
+		fmt.Fprintf(p.out, "%s: ", label)
+	}
+
+	var (
+		answer string
+		err    error
+	)
+	if secret && p.readSecret != nil {
+		answer, err = p.readSecret()
+	} else {
+		answer, err = p.in.ReadString('\n')
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		return def
+	}
+	answer = strings.TrimSpace(answer)
+	if answer == "" {
+		return def
+	}
+	return answer
+}
+
+// confirm asks a yes/no question. With --yes every question is answered yes.
+func (p *prompter) confirm(question string, def bool) bool {
+	if p.assumeYes {
+		return true
+	}
+
+	hint := "y/N"
+	if def {
+		hint = "Y/n"
+	}
+	fmt.Fprintf(p.out, "%s [%s]: ", question, hint)
+
+	answer, err := p.in.ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return def
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	case "n", "no":
+		return false
+	default:
+		return def
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrompterUsesAnswersAndDefaults(t *testing.T) {
+	var out bytes.Buffer
+	p := newPrompter(strings.NewReader("https://knocker.example.com\n\nn\n"), &out, false)
+
+	require.Equal(t, "https://knocker.example.com", p.ask("Knocker API URL", "", false))
+	require.Equal(t, "existing-key", p.ask("Knocker API key", "existing-key", true))
+	require.False(t, p.confirm("Install?", true))
+	require.NotContains(t, out.String(), "existing-key")
+}
+
+func TestPrompterAssumeYesSkipsInput(t *testing.T) {
+	var out bytes.Buffer
+	p := newPrompter(strings.NewReader(""), &out, true)
+
+	require.Equal(t, "default", p.ask("Value", "default", false))
+	require.True(t, p.confirm("Overwrite?", false))
+	require.Empty(t, out.String())
+}
+
+func TestPrompterReadsSecretsWithoutEcho(t *testing.T) {
+	var out bytes.Buffer
+	p := newPrompter(strings.NewReader("https://knocker.example.com\n"), &out, false)
+	p.readSecret = func() (string, error) { return "typed-key", nil }
+
+	require.Equal(t, "typed-key", p.ask("Knocker API key", "", true))
+	require.Equal(t, "https://knocker.example.com", p.ask("Knocker API URL", "", false))
+}
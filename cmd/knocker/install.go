@@ -13,21 +13,27 @@ var installCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		s, err := newServiceInstance(true)
 		if err != nil {
-			logger.Fatal(err)
+			exitWithError(cmd, newServiceError(err))
 		}
 
 		if err := s.Install(); err != nil {
-			logger.Fatal(err)
+			exitWithError(cmd, newServiceError(err))
 		}
 
-		logger.Println("Service installed successfully.")
+		logger.Info("Service installed successfully")
 
+		hint := ""
 		switch runtime.GOOS {
 		case "linux":
-			logger.Println("Hint: use `systemctl --user enable --now knocker` to start the service immediately.")
+			hint = "use `systemctl --user enable --now knocker` to start the service immediately."
 		case "darwin":
-			logger.Println("Hint: use `launchctl bootstrap gui/$(id -u) ~/Library/LaunchAgents/knocker.plist` to load the agent.")
+			hint = "use `launchctl bootstrap gui/$(id -u) ~/Library/LaunchAgents/knocker.plist` to load the agent."
 		}
+		if hint != "" {
+			logger.Info("Hint: " + hint)
+		}
+
+		emitResult(cmd, serviceResult{Action: "install", Changed: true, Hint: hint}, "")
 	},
 }
 
@@ -1,64 +1,336 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net"
+	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/FarisZR/knocker-cli/internal/api"
+	"github.com/FarisZR/knocker-cli/internal/config"
+	"github.com/FarisZR/knocker-cli/internal/events"
+	"github.com/FarisZR/knocker-cli/internal/history"
 	"github.com/FarisZR/knocker-cli/internal/journald"
 	internalService "github.com/FarisZR/knocker-cli/internal/service"
+	"github.com/FarisZR/knocker-cli/internal/telemetry"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// knockWaitPollInterval is how long `knock --wait` pauses between attempts.
+var knockWaitPollInterval = 2 * time.Second
+
+// knockHistory records manual knock attempts; nil disables recording.
+var knockHistory *history.Store
+
 var knockCmd = &cobra.Command{
 	Use:   "knock",
 	Short: "Manually trigger a whitelist request",
-	Long:  `Manually triggers a request to the Knocker API to whitelist the public IP of the machine.`,
+	Long: `Manually triggers a request to the Knocker API to whitelist the public IP of the machine.
+
+Use --ip to whitelist other addresses or CIDR ranges (for example a colleague's machine or a CI runner),
+--profile to use the connection settings of a named profile, --ttl to override the TTL and
+--dry-run to print the request without sending it.`,
+	Annotations: map[string]string{emitsEventsAnnotation: "true"},
 	Run: func(cmd *cobra.Command, args []string) {
-		apiURL := viper.GetString("api_url")
-		apiKey := viper.GetString("api_key")
-		ttl := viper.GetInt("ttl")
+		profileName, _ := cmd.Flags().GetString("profile")
+		entries, _ := cmd.Flags().GetStringSlice("ip")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		wait, _ := cmd.Flags().GetDuration("wait")
 
-		if apiURL == "" || apiKey == "" {
-			logger.Fatal("API URL and API Key must be configured.")
+		profile, err := config.ResolveProfile(viper.GetViper(), profileName)
+		if err != nil {
+			exitWithError(cmd, newConfigError(err))
+		}
+		ttl := profile.TTL
+		if explicitFlags["ttl"] {
+			ttl = viper.GetInt("ttl")
 		}
 
-		client := api.NewClient(apiURL, apiKey)
+		if profile.APIURL == "" || profile.APIKey == "" {
+			exitWithError(cmd, newConfigError(errors.New("API URL and API Key must be configured.")))
+		}
+		for _, entry := range entries {
+			if err := config.ValidateEntry(entry); err != nil {
+				exitWithError(cmd, newConfigError(err))
+			}
+		}
+
+		// Configured extra entries ride along unless specific entries were requested.
+		var extras []string
+		if len(entries) == 0 {
+			extras = viper.GetStringSlice("extra_entries")
+		}
 
-		logger.Println("Manually knocking to whitelist IP...")
-		knockResponse, err := client.Knock("", ttl)
+		client := api.NewClient(profile.APIURL, profile.APIKey)
+
+		if dryRun {
+			preview, err := previewKnockRequest(client, entries, extras, ttl)
+			if err != nil {
+				exitWithError(cmd, err)
+			}
+			emitResult(cmd, preview, preview.String())
+			return
+		}
+
+		if len(entries) > 0 {
+			logger.Info("Manually knocking", "trigger_source", internalService.TriggerSourceCLI, "entries", entries)
+		} else {
+			logger.Info("Manually knocking", "trigger_source", internalService.TriggerSourceCLI)
+		}
+
+		knockHistory, err = openHistory(viper.GetViper())
+		if err != nil {
+			logger.Warn("Knock history disabled", "error", err)
+		}
+
+		profileAttr := profile.Name
+		if profileAttr == "" {
+			profileAttr = "default"
+		}
+		ctx, span := tracer.Start(context.Background(), "knocker.manual_knock", trace.WithAttributes(
+			attribute.String(telemetry.AttrProfile, profileAttr),
+			attribute.String(telemetry.AttrTriggerSource, internalService.TriggerSourceCLI),
+		))
+		cycle := &knockCycle{ID: events.NewCycleID(ctx), Profile: profile.Name}
+		knockResponse, err := knockWithWait(ctx, client, entries, extras, ttl, wait, cycle)
+		endKnockSpan(span, err)
+		var extrasErr *internalService.ExtrasError
+		if errors.As(err, &extrasErr) && knockResponse != nil {
+			// The caller's address was whitelisted; only the extras failed.
+			emitManualKnockSuccess(cycle, knockResponse)
+			emitManualExtrasFailure(cycle, extrasErr)
+			exitWithErrorResult(cmd, knockResponse, newAPIError(fmt.Errorf("knocked, but %w", err)))
+		}
 		if err != nil {
-			emitManualKnockFailure(err)
-			logger.Fatalf("Failed to knock: %v", err)
+			emitManualKnockFailure(cycle, err)
+			exitWithError(cmd, newAPIError(fmt.Errorf("failed to knock: %w", err)))
 		}
 
-		emitManualKnockSuccess(knockResponse)
+		emitManualKnockSuccess(cycle, knockResponse)
 
-		logger.Printf("Successfully knocked. Whitelisted entry: %s (ttl: %d seconds)", knockResponse.WhitelistedEntry, knockResponse.ExpiresInSeconds)
-		fmt.Printf("Successfully knocked and whitelisted IP. TTL: %d seconds\n", knockResponse.ExpiresInSeconds)
+		logger.Info("Successfully knocked", "whitelist_ip", strings.Join(knockResponse.Entries(), ","), "ttl_sec", knockResponse.ExpiresInSeconds)
+		emitResult(cmd, knockResponse, fmt.Sprintf("Successfully knocked and whitelisted IP. TTL: %d seconds", knockResponse.ExpiresInSeconds))
 	},
 }
 
 func init() {
+	knockCmd.Flags().StringSlice("ip", nil, "whitelist these addresses or CIDR ranges instead of the caller's public IP (repeatable)")
+	knockCmd.Flags().String("profile", "", "use the api_url, api_key and ttl of this profile from the config file")
+	knockCmd.Flags().Bool("dry-run", false, "print the request that would be sent (API key redacted) without sending it")
+	knockCmd.Flags().Duration("wait", 0, "keep retrying until the API confirms the whitelist entry, for at most this long (e.g. 30s)")
 	rootCmd.AddCommand(knockCmd)
 }
 
-func emitManualKnockFailure(err error) {
+// knockCycle ties together the events raised by one manual knock. Attempt
+// and Latency describe the last knock request sent.
+type knockCycle struct {
+	ID      string
+	Profile string
+	Attempt int
+	Latency time.Duration
+}
+
+// fields adds the cycle's correlation fields to fields and returns it.
+func (c *knockCycle) fields(fields journald.Fields) journald.Fields {
+	if c == nil || c.ID == "" {
+		return fields
+	}
+	fields["KNOCKER_CYCLE_ID"] = c.ID
+	fields["KNOCKER_ATTEMPT"] = strconv.Itoa(c.Attempt)
+	if c.Profile != "" {
+		fields["KNOCKER_PROFILE"] = c.Profile
+	}
+	return fields
+}
+
+// knockWithWait knocks once, or with wait > 0 keeps retrying until the API
+// confirms every requested entry or the wait elapses. Rejected API keys are
+// not retried. Each attempt is traced as a child span of ctx and counted in
+// cycle.
+func knockWithWait(ctx context.Context, client *api.Client, entries, extras []string, ttl int, wait time.Duration, cycle *knockCycle) (*api.KnockResponse, error) {
+	knock := func() (*api.KnockResponse, error) {
+		cycle.Attempt++
+		ctx, span := tracer.Start(ctx, "knocker.knock_attempt", trace.WithAttributes(
+			attribute.Int(telemetry.AttrAttempt, cycle.Attempt),
+		))
+
+		var knockResponse *api.KnockResponse
+		var err error
+		start := time.Now()
+		if len(entries) > 0 {
+			knockResponse, err = client.KnockEntriesContext(ctx, entries, ttl)
+		} else {
+			knockResponse, err = internalService.KnockWithExtras(ctx, client, "", extras, ttl)
+		}
+		cycle.Latency = time.Since(start)
+		endKnockSpan(span, err)
+
+		record := internalService.NewHistoryRecord(start, internalService.TriggerSourceCLI, "", cycle.Latency, knockResponse, err)
+		if err := knockHistory.Append(record); err != nil {
+			logger.Warn("Unable to record knock history", "error", err)
+		}
+		return knockResponse, err
+	}
+
+	if wait <= 0 {
+		return knock()
+	}
+
+	deadline := time.Now().Add(wait)
+	for {
+		knockResponse, err := knock()
+		if err == nil && knockConfirmed(knockResponse, entries) {
+			return knockResponse, nil
+		}
+		if err == nil {
+			err = fmt.Errorf("API whitelisted %q instead of %q", knockResponse.Entries(), entries)
+		}
+
+		var statusErr *api.StatusError
+		if errors.As(err, &statusErr) && statusErr.Unauthorized() {
+			return nil, err
+		}
+		if time.Now().Add(knockWaitPollInterval).After(deadline) {
+			// Keep the response of a knock that only failed for the extras.
+			return knockResponse, err
+		}
+
+		logger.Info("Knock not confirmed yet; retrying", "error", err, "retry_in", knockWaitPollInterval)
+		time.Sleep(knockWaitPollInterval)
+	}
+}
+
+// endKnockSpan records the result of a knock on span and ends it.
+func endKnockSpan(span trace.Span, err error) {
+	result := internalService.ResultSuccess
+	if err != nil {
+		result = internalService.ResultFailure
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.SetAttributes(attribute.String(telemetry.AttrResult, result))
+	span.End()
+}
+
+// knockConfirmed reports whether the API whitelisted an entry and, when
+// specific entries were requested, that each of them is present.
+func knockConfirmed(knockResponse *api.KnockResponse, entries []string) bool {
+	whitelisted := knockResponse.Entries()
+	if len(whitelisted) == 0 {
+		return false
+	}
+
+	for _, entry := range entries {
+		found := false
+		for _, candidate := range whitelisted {
+			if sameEntry(entry, candidate) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// sameEntry compares two whitelist entries, treating different spellings of
+// the same IP address as equal.
+func sameEntry(a, b string) bool {
+	if ipA, ipB := net.ParseIP(a), net.ParseIP(b); ipA != nil && ipB != nil {
+		return ipA.Equal(ipB)
+	}
+	return a == b
+}
+
+// knockPreview describes the request `knock --dry-run` would send.
+type knockPreview struct {
+	DryRun  bool              `json:"dry_run"`
+	Method  string            `json:"method"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers"`
+	Body    json.RawMessage   `json:"body"`
+	// ExtraEntries are sent in a follow-up request after the caller's knock.
+	ExtraEntries []string `json:"extra_entries,omitempty"`
+}
+
+func (p knockPreview) String() string {
+	text := fmt.Sprintf("Dry run, not sending:\n%s %s\n", p.Method, p.URL)
+	for _, name := range []string{"Content-Type", "X-Api-Key"} {
+		if value, ok := p.Headers[name]; ok {
+			text += fmt.Sprintf("%s: %s\n", name, value)
+		}
+	}
+	text += "\n" + string(p.Body)
+	if len(p.ExtraEntries) > 0 {
+		text += fmt.Sprintf("\n\nFollowed by a knock for extra entries: %s", strings.Join(p.ExtraEntries, ", "))
+	}
+	return text
+}
+
+func previewKnockRequest(client *api.Client, entries, extras []string, ttl int) (knockPreview, error) {
+	req, err := client.NewKnockEntriesRequest(entries, ttl)
+	if err != nil {
+		return knockPreview{}, err
+	}
+
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return knockPreview{}, err
+	}
+
+	headers := map[string]string{}
+	for name := range req.Header {
+		headers[http.CanonicalHeaderKey(name)] = req.Header.Get(name)
+	}
+	if _, ok := headers["X-Api-Key"]; ok {
+		headers["X-Api-Key"] = "********"
+	}
+
+	return knockPreview{
+		DryRun:       true,
+		Method:       req.Method,
+		URL:          req.URL.String(),
+		Headers:      headers,
+		Body:         body,
+		ExtraEntries: extras,
+	}, nil
+}
+
+func emitManualKnockFailure(cycle *knockCycle, err error) {
 	msg := fmt.Sprintf("Manual knock failed: %v", err)
-	_ = journald.Emit(internalService.EventKnockTriggered, msg, journald.PriErr, journald.Fields{
-		"KNOCKER_TRIGGER_SOURCE": internalService.TriggerSourceCLI,
-		"KNOCKER_RESULT":         internalService.ResultFailure,
-	})
-	_ = journald.Emit(internalService.EventError, msg, journald.PriErr, journald.Fields{
+	knockFields := internalService.KnockFields(internalService.TriggerSourceCLI, internalService.ResultFailure, cycle.Latency, err)
+	emitCLIEvent(internalService.EventKnockTriggered, msg, journald.PriErr, cycle.fields(knockFields))
+	emitCLIEvent(internalService.EventError, msg, journald.PriErr, cycle.fields(journald.Fields{
 		"KNOCKER_ERROR_CODE": internalService.ErrorCodeKnockFailed,
 		"KNOCKER_ERROR_MSG":  msg,
 		"KNOCKER_CONTEXT":    "cli",
-	})
+	}))
 }
 
-func emitManualKnockSuccess(knockResponse *api.KnockResponse) {
+// emitManualExtrasFailure reports extra entries that were not whitelisted
+// although the knock for the caller's address succeeded.
+func emitManualExtrasFailure(cycle *knockCycle, err *internalService.ExtrasError) {
+	msg := fmt.Sprintf("Manual knock failed: %v", err)
+	emitCLIEvent(internalService.EventError, msg, journald.PriErr, cycle.fields(journald.Fields{
+		"KNOCKER_ERROR_CODE": internalService.ErrorCodeKnockFailed,
+		"KNOCKER_ERROR_MSG":  msg,
+		"KNOCKER_CONTEXT":    strings.Join(err.Extras, ","),
+	}))
+}
+
+func emitManualKnockSuccess(cycle *knockCycle, knockResponse *api.KnockResponse) {
 	whitelistIP := ""
 	ttlSeconds := 0
 	expiresUnix := int64(0)
@@ -68,19 +340,26 @@ func emitManualKnockSuccess(knockResponse *api.KnockResponse) {
 		expiresUnix = knockResponse.ExpiresAt
 	}
 
-	knockFields := journald.Fields{
-		"KNOCKER_TRIGGER_SOURCE": internalService.TriggerSourceCLI,
-		"KNOCKER_RESULT":         internalService.ResultSuccess,
-	}
+	knockFields := internalService.KnockFields(internalService.TriggerSourceCLI, internalService.ResultSuccess, cycle.Latency, nil)
 	if whitelistIP != "" {
 		knockFields["KNOCKER_WHITELIST_IP"] = whitelistIP
 	}
-	_ = journald.Emit(internalService.EventKnockTriggered, "Manual knock succeeded", journald.PriInfo, knockFields)
+	emitCLIEvent(internalService.EventKnockTriggered, "Manual knock succeeded", journald.PriInfo, cycle.fields(knockFields))
 
 	if knockResponse == nil {
 		return
 	}
 
+	entries := knockResponse.Entries()
+	if len(entries) == 0 {
+		entries = []string{""}
+	}
+	for _, entry := range entries {
+		emitManualWhitelistApplied(cycle, entry, ttlSeconds, expiresUnix)
+	}
+}
+
+func emitManualWhitelistApplied(cycle *knockCycle, whitelistIP string, ttlSeconds int, expiresUnix int64) {
 	whitelistFields := journald.Fields{
 		"KNOCKER_SOURCE": internalService.TriggerSourceCLI,
 	}
@@ -101,5 +380,5 @@ func emitManualKnockSuccess(knockResponse *api.KnockResponse) {
 		message = fmt.Sprintf("Whitelisted %s", whitelistIP)
 	}
 
-	_ = journald.Emit(internalService.EventWhitelistApplied, message, journald.PriInfo, whitelistFields)
+	emitCLIEvent(internalService.EventWhitelistApplied, message, journald.PriInfo, cycle.fields(whitelistFields))
 }
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/FarisZR/knocker-cli/internal/api"
+	"github.com/FarisZR/knocker-cli/internal/events"
+	internalService "github.com/FarisZR/knocker-cli/internal/service"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPreviewKnockRequestRedactsAPIKey(t *testing.T) {
+	client := api.NewClient("https://knocker.example.com", "super-secret")
+
+	preview, err := previewKnockRequest(client, []string{"203.0.113.7"}, nil, 600)
+	require.NoError(t, err)
+
+	require.Equal(t, "POST", preview.Method)
+	require.Equal(t, "https://knocker.example.com/knock", preview.URL)
+	require.Equal(t, "********", preview.Headers["X-Api-Key"])
+	require.JSONEq(t, `{"ip_address":"203.0.113.7","ttl":600}`, string(preview.Body))
+	require.NotContains(t, preview.String(), "super-secret")
+}
+
+func TestKnockWithWaitRetriesUntilConfirmed(t *testing.T) {
+	initLogger(t)
+	defer func(interval time.Duration) { knockWaitPollInterval = interval }(knockWaitPollInterval)
+	knockWaitPollInterval = 10 * time.Millisecond
+
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) <= 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(api.KnockResponse{WhitelistedEntry: "203.0.113.7", ExpiresInSeconds: 600})
+	}))
+	defer server.Close()
+
+	client := api.NewClient(server.URL, "test-key")
+
+	_, err := knockWithWait(context.Background(), client, []string{"203.0.113.7"}, nil, 0, 0, &knockCycle{})
+	require.Error(t, err)
+
+	cycle := &knockCycle{}
+	knockResponse, err := knockWithWait(context.Background(), client, []string{"203.0.113.7"}, nil, 0, 10*time.Second, cycle)
+	require.NoError(t, err)
+	require.Equal(t, "203.0.113.7", knockResponse.WhitelistedEntry)
+	require.Equal(t, int32(4), attempts.Load())
+	require.Equal(t, 3, cycle.Attempt)
+}
+
+func TestKnockWithWaitDoesNotRetryRejectedKey(t *testing.T) {
+	initLogger(t)
+
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	_, err := knockWithWait(context.Background(), api.NewClient(server.URL, "bad-key"), nil, nil, 0, time.Minute, &knockCycle{})
+	require.Error(t, err)
+	require.Equal(t, int32(1), attempts.Load())
+}
+
+func TestKnockConfirmedRequiresEveryEntry(t *testing.T) {
+	knockResponse := &api.KnockResponse{WhitelistedEntries: []string{"2001:db8::1", "203.0.113.0/29"}}
+
+	require.True(t, knockConfirmed(knockResponse, []string{"2001:0db8::0001", "203.0.113.0/29"}))
+	require.False(t, knockConfirmed(knockResponse, []string{"198.51.100.0/24"}))
+	require.False(t, knockConfirmed(&api.KnockResponse{}, nil))
+}
+
+type capturedEvents []events.Event
+
+func (c *capturedEvents) Emit(event events.Event) error {
+	*c = append(*c, event)
+	return nil
+}
+
+func TestManualKnockEventsCarryKnockCycle(t *testing.T) {
+	defer func(sink events.EventSink) { eventSink = sink }(eventSink)
+	var captured capturedEvents
+	eventSink = &captured
+
+	cycle := &knockCycle{ID: "0123456789abcdef0123456789abcdef", Profile: "office", Attempt: 2, Latency: 120 * time.Millisecond}
+	emitManualKnockFailure(cycle, &api.StatusError{Operation: "knock", StatusCode: http.StatusBadGateway})
+
+	require.Len(t, captured, 2)
+	knock := captured[0]
+	require.Equal(t, internalService.EventKnockTriggered, knock.Type)
+	require.Equal(t, cycle.ID, knock.Fields["KNOCKER_CYCLE_ID"])
+	require.Equal(t, "2", knock.Fields["KNOCKER_ATTEMPT"])
+	require.Equal(t, "office", knock.Fields["KNOCKER_PROFILE"])
+	require.Equal(t, "120", knock.Fields["KNOCKER_LATENCY_MS"])
+	require.Equal(t, "502", knock.Fields["KNOCKER_HTTP_STATUS"])
+	require.Equal(t, cycle.ID, captured[1].Fields["KNOCKER_CYCLE_ID"])
+}
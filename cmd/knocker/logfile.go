@@ -0,0 +1,66 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/FarisZR/knocker-cli/internal/config"
+	"github.com/FarisZR/knocker-cli/internal/logging"
+	"github.com/kardianos/service"
+	"github.com/spf13/viper"
+)
+
+var (
+	// logFile is the rotating log file opened by attachLogFile, if any.
+	logFile *logging.RotatingFile
+	// consoleLogger is the logger in use before logFile was attached.
+	consoleLogger *slog.Logger
+)
+
+// attachLogFile copies the runtime log into the file set by log.file when
+// running under the service manager. launchd and the Windows service manager
+// discard the service's output, so without it those platforms keep no logs.
+func attachLogFile(v *viper.Viper) {
+	if service.Interactive() {
+		return
+	}
+	if err := openLogFile(v); err != nil {
+		logger.Warn("Log file disabled", "error", err)
+	}
+}
+
+// openLogFile replaces the logger with one writing to both the configured log
+// file and stdout, keeping the log level and format.
+func openLogFile(v *viper.Viper) error {
+	cfg, ok := config.LoadLogFile(v)
+	if !ok || logFile != nil {
+		return nil
+	}
+	file, err := logging.OpenRotatingFile(cfg)
+	if err != nil {
+		return err
+	}
+	logFile, consoleLogger = file, logger
+
+	level, _ := logging.ParseLevel(v.GetString("log.level"))
+	format := v.GetString("log.format")
+	// The file comes first: stdout may be unusable under a service manager.
+	logger = logging.New(io.MultiWriter(file, os.Stdout), level, format)
+	slog.SetDefault(logger)
+	return nil
+}
+
+// closeLogFile closes the log file opened by attachLogFile and goes back to
+// logging to the console only.
+func closeLogFile() {
+	if logFile == nil {
+		return
+	}
+	logger = consoleLogger
+	slog.SetDefault(logger)
+	if err := logFile.Close(); err != nil {
+		logger.Warn("Closing log file failed", "path", logFile.Path(), "error", err)
+	}
+	logFile, consoleLogger = nil, nil
+}
@@ -2,10 +2,12 @@ package main
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
 
 	"github.com/FarisZR/knocker-cli/internal/config"
+	"github.com/FarisZR/knocker-cli/internal/events"
+	"github.com/FarisZR/knocker-cli/internal/logging"
 	"github.com/kardianos/service"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
@@ -13,8 +15,20 @@ import (
 )
 
 var (
-	logger  *log.Logger
+	logger  = logging.Discard()
 	version = "dev"
+
+	// explicitFlags records the flags the user passed on the command line by
+	// the config key they set, captured before applyConfigDefaults copies
+	// config values into the rest.
+	explicitFlags = map[string]bool{}
+
+	// flagKeys maps flags to the config key they are bound to where the two
+	// names differ.
+	flagKeys = map[string]string{
+		"log-level":  "log.level",
+		"log-format": "log.format",
+	}
 )
 
 var rootCmd = &cobra.Command{
@@ -24,33 +38,107 @@ var rootCmd = &cobra.Command{
 	Long: `A reliable, cross-platform service that keeps your external IP address whitelisted.
 It runs in the background, detects IP changes, and ensures you always have access.`,
 	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		recordExplicitFlags(cmd)
 		applyConfigDefaults(cmd, viper.GetViper())
-		logger = log.New(os.Stdout, "knocker: ", log.LstdFlags)
+
+		if err := setupLogger(viper.GetViper()); err != nil {
+			exitWithError(cmd, newConfigError(err))
+		}
+
+		if err := validateOutputFormat(); err != nil {
+			exitWithError(cmd, newUsageError(err))
+		}
+
+		if emitsEvents(cmd) {
+			eventSink = newEventSink(viper.GetViper())
+		}
+		setupTracing(viper.GetViper())
+	},
+	PersistentPostRun: func(cmd *cobra.Command, args []string) {
+		closeEventSink()
+		shutdownTelemetry()
+		closeLogFile()
 	},
 	Run: func(cmd *cobra.Command, args []string) {
 		if !service.Interactive() {
 			s, err := newServiceInstance(false)
 			if err != nil {
-				logger.Fatalf("unable to initialise service runtime: %v", err)
+				fatal("Unable to initialise service runtime", "error", err)
 			}
 
 			if err := s.Run(); err != nil {
-				logger.Fatalf("service run failed: %v", err)
+				fatal("Service run failed", "error", err)
 			}
 			return
 		}
 
 		if err := cmd.Help(); err != nil {
-			logger.Printf("unable to show help: %v", err)
+			logger.Error("Unable to show help", "error", err)
 		}
 	},
 }
 
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
-		fmt.Fprintf(os.Stderr, "Whoops. There was an error while executing your CLI '%s'", err)
-		os.Exit(1)
+	// Errors cobra returns come from the command line itself, such as an
+	// unknown flag. In JSON mode they are reported in the envelope instead of
+	// cobra's usage text.
+	jsonErrors := outputFormatArg(os.Args[1:]) == outputJSON
+	rootCmd.SilenceErrors = jsonErrors
+	rootCmd.SilenceUsage = jsonErrors
+
+	cmd, err := rootCmd.ExecuteC()
+	if err == nil {
+		return
 	}
+	if jsonErrors {
+		outputFormat = outputJSON
+		exitWithError(cmd, newUsageError(err))
+	}
+	fmt.Fprintf(os.Stderr, "Whoops. There was an error while executing your CLI '%s'\n", err)
+	os.Exit(exitCodeUsage)
+}
+
+// setupLogger replaces the logger with one honouring log.level and
+// log.format (--log-level and --log-format). Logs go to stdout, or to stderr
+// to keep stdout free for the JSON document when --output json is used.
+func setupLogger(v *viper.Viper) error {
+	out := os.Stdout
+	if jsonOutput() {
+		out = os.Stderr
+	}
+	level, levelErr := logging.ParseLevel(v.GetString("log.level"))
+	format := v.GetString("log.format")
+	formatErr := logging.ValidateFormat(format)
+	if formatErr != nil {
+		format = logging.FormatText
+	}
+	logger = logging.New(out, level, format)
+	slog.SetDefault(logger)
+
+	if levelErr != nil {
+		return levelErr
+	}
+	return formatErr
+}
+
+// fatal logs msg at error level and exits with status 1.
+func fatal(msg string, args ...any) {
+	logger.Error(msg, args...)
+	os.Exit(1)
+}
+
+func recordExplicitFlags(cmd *cobra.Command) {
+	if cmd == nil {
+		return
+	}
+
+	cmd.Flags().Visit(func(flag *pflag.Flag) {
+		if key, ok := flagKeys[flag.Name]; ok {
+			explicitFlags[key] = true
+			return
+		}
+		explicitFlags[flag.Name] = true
+	})
 }
 
 func applyConfigDefaults(cmd *cobra.Command, v *viper.Viper) {
@@ -80,14 +168,23 @@ func init() {
 	rootCmd.SetVersionTemplate(`{{printf "%s\n" .Version}}`)
 
 	rootCmd.PersistentFlags().StringVar(&config.CfgFile, "config", "", "config file (default is $HOME/.knocker.yaml)")
+	rootCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", outputText, "output format: text or json")
+	rootCmd.PersistentFlags().String("log-level", "info", "log level: debug, info, warn or error")
+	rootCmd.PersistentFlags().String("log-format", logging.FormatText, "log format: text or json")
 	rootCmd.PersistentFlags().Int("check_interval", 5, "Interval in minutes to poll for IP changes (only used when ip_check_url is set)")
 	rootCmd.PersistentFlags().String("ip_check_url", "", "URL of the external IP checker service")
 	rootCmd.PersistentFlags().Int("ttl", 0, "Time to live in seconds for the knock request (0 for server default)")
 	viper.BindPFlag("check_interval", rootCmd.PersistentFlags().Lookup("check_interval"))
 	viper.BindPFlag("ip_check_url", rootCmd.PersistentFlags().Lookup("ip_check_url"))
 	viper.BindPFlag("ttl", rootCmd.PersistentFlags().Lookup("ttl"))
+	for name, key := range flagKeys {
+		viper.BindPFlag(key, rootCmd.PersistentFlags().Lookup(name))
+	}
 	viper.SetDefault("check_interval", 5)
 	viper.SetDefault("ttl", 0)
+	viper.SetDefault("events.journald", true)
+	viper.SetDefault("events.schema", events.DefaultSchema)
+	viper.SetDefault("history.enabled", true)
 }
 
 func main() {
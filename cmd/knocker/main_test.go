@@ -1,8 +1,13 @@
 package main
 
 import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/FarisZR/knocker-cli/internal/logging"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/require"
@@ -27,3 +32,77 @@ func TestApplyConfigDefaultsSetsPersistentFlagsFromConfig(t *testing.T) {
 	require.Equal(t, 60, ttl)
 	require.Equal(t, 60, v.GetInt("ttl"))
 }
+
+func TestRecordExplicitFlagsUsesConfigKeys(t *testing.T) {
+	t.Cleanup(func() { explicitFlags = map[string]bool{} })
+	explicitFlags = map[string]bool{}
+
+	cmd := &cobra.Command{Use: "test"}
+	cmd.Flags().String("log-level", "info", "")
+	cmd.Flags().Int("ttl", 0, "")
+	cmd.Flags().String("log-format", "text", "")
+	require.NoError(t, cmd.Flags().Parse([]string{"--log-level", "debug", "--ttl", "30"}))
+
+	recordExplicitFlags(cmd)
+
+	require.True(t, explicitFlags["log.level"], "log-level is recorded under the key it sets")
+	require.True(t, explicitFlags["ttl"])
+	require.False(t, explicitFlags["log-level"])
+	require.False(t, explicitFlags["log.format"])
+}
+
+func initLogger(t *testing.T) {
+	t.Helper()
+	logger = logging.Discard()
+}
+
+func TestSetupLoggerHonoursLevelAndFormat(t *testing.T) {
+	t.Cleanup(func() { logger = logging.Discard() })
+
+	v := viper.New()
+	v.Set("log.level", "warn")
+	v.Set("log.format", "json")
+	require.NoError(t, setupLogger(v))
+	require.False(t, logger.Enabled(context.Background(), slog.LevelInfo))
+	require.True(t, logger.Enabled(context.Background(), slog.LevelWarn))
+	_, isJSON := logger.Handler().(*slog.JSONHandler)
+	require.True(t, isJSON)
+
+	v.Set("log.level", "loud")
+	require.Error(t, setupLogger(v))
+	v.Set("log.level", "debug")
+	v.Set("log.format", "xml")
+	require.Error(t, setupLogger(v))
+	require.True(t, logger.Enabled(context.Background(), slog.LevelDebug))
+}
+
+func TestOpenLogFileTeesIntoRotatingFile(t *testing.T) {
+	initLogger(t)
+	t.Cleanup(func() { logger = logging.Discard() })
+
+	path := filepath.Join(t.TempDir(), "knocker.log")
+	v := viper.New()
+	v.Set("log.level", "error")
+	v.Set("log.format", "json")
+	v.Set("log.file", path)
+	require.NoError(t, openLogFile(v))
+	require.NotNil(t, logFile)
+
+	logger.Info("filtered")
+	logger.Error("Service run failed", "error", "boom")
+	closeLogFile()
+	require.Nil(t, logFile)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.NotContains(t, string(data), "filtered")
+	require.Contains(t, string(data), `"msg":"Service run failed","error":"boom"`)
+}
+
+func TestOnlyEventEmittingCommandsOpenSinks(t *testing.T) {
+	require.True(t, emitsEvents(knockCmd))
+	require.True(t, emitsEvents(runCmd))
+	for _, cmd := range []*cobra.Command{configShowCmd, eventsCmd, historyCmd, initCmd, doctorCmd} {
+		require.False(t, emitsEvents(cmd), cmd.Name())
+	}
+}
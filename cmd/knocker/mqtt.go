@@ -0,0 +1,34 @@
+package main
+
+import (
+	"github.com/FarisZR/knocker-cli/internal/config"
+	"github.com/FarisZR/knocker-cli/internal/events"
+	"github.com/FarisZR/knocker-cli/internal/mqtt"
+	"github.com/spf13/viper"
+)
+
+// startMQTT connects to the broker configured by the `mqtt` settings and
+// returns a sink publishing the service status and events to it, in the
+// schema selected by events.schema. It returns nil when no broker is set.
+func startMQTT(v *viper.Viper) (*events.SchemaSink, error) {
+	cfg, err := config.LoadMQTT(v)
+	if err != nil || cfg.Broker == "" {
+		return nil, err
+	}
+
+	publisher, err := mqtt.Connect(cfg, version, func(err error) {
+		logger.Warn("MQTT error", "error", err)
+	})
+	if err != nil {
+		return nil, err
+	}
+	logger.Info("Publishing status to MQTT", "broker", config.RedactURL(cfg.Broker), "topic_prefix", cfg.TopicPrefix)
+	return events.NewSchemaSink(publisher, singleSchema(v.GetString("events.schema"))), nil
+}
+
+// closeMQTT marks the service offline on the broker and disconnects.
+func closeMQTT(sink *events.SchemaSink) {
+	if err := sink.Close(); err != nil {
+		logger.Warn("Closing MQTT connection failed", "error", err)
+	}
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/FarisZR/knocker-cli/internal/api"
+	"github.com/spf13/cobra"
+)
+
+// Output formats accepted by --output.
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
+var outputFormat = outputText
+
+// Exit codes, one per failure class, so scripts can branch without parsing
+// output.
+const (
+	exitCodeFailure        = 1 // unclassified failure
+	exitCodeConfig         = 2 // missing or invalid configuration
+	exitCodeAPIUnreachable = 3 // network error talking to the Knocker API
+	exitCodeAuth           = 4 // the API rejected the API key
+	exitCodeAPIError       = 5 // the API returned another error status or payload
+	exitCodeService        = 6 // the service manager operation failed
+	exitCodeChecksFailed   = 7 // doctor or healthcheck found failing checks
+	exitCodeUsage          = 8 // unknown command or flag, or invalid arguments
+)
+
+// Error codes reported in JSON output, matching the exit code classes.
+const (
+	errorCodeFailure        = "failure"
+	errorCodeConfig         = "config_invalid"
+	errorCodeAPIUnreachable = "api_unreachable"
+	errorCodeAuth           = "auth_failed"
+	errorCodeAPIError       = "api_error"
+	errorCodeService        = "service_error"
+	errorCodeChecksFailed   = "checks_failed"
+	errorCodeUsage          = "usage_error"
+)
+
+// cliError attaches a failure class to an error.
+type cliError struct {
+	code     string
+	exitCode int
+	err      error
+}
+
+func (e *cliError) Error() string {
+	return e.err.Error()
+}
+
+func (e *cliError) Unwrap() error {
+	return e.err
+}
+
+func newConfigError(err error) error {
+	return &cliError{code: errorCodeConfig, exitCode: exitCodeConfig, err: err}
+}
+
+func newUsageError(err error) error {
+	return &cliError{code: errorCodeUsage, exitCode: exitCodeUsage, err: err}
+}
+
+func newServiceError(err error) error {
+	return &cliError{code: errorCodeService, exitCode: exitCodeService, err: err}
+}
+
+// newAPIError classifies an error returned by api.Client.
+func newAPIError(err error) error {
+	var statusErr *api.StatusError
+	if errors.As(err, &statusErr) {
+		if statusErr.Unauthorized() {
+			return &cliError{code: errorCodeAuth, exitCode: exitCodeAuth, err: err}
+		}
+		return &cliError{code: errorCodeAPIError, exitCode: exitCodeAPIError, err: err}
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return &cliError{code: errorCodeAPIUnreachable, exitCode: exitCodeAPIUnreachable, err: err}
+	}
+
+	return &cliError{code: errorCodeAPIError, exitCode: exitCodeAPIError, err: err}
+}
+
+// commandOutput is the stable JSON document printed by every command when
+// --output json is selected.
+type commandOutput struct {
+	Command string       `json:"command"`
+	OK      bool         `json:"ok"`
+	Result  interface{}  `json:"result,omitempty"`
+	Error   *outputError `json:"error,omitempty"`
+}
+
+type outputError struct {
+	Code     string `json:"code"`
+	Message  string `json:"message"`
+	ExitCode int    `json:"exit_code"`
+}
+
+func jsonOutput() bool {
+	return outputFormat == outputJSON
+}
+
+func validateOutputFormat() error {
+	switch outputFormat {
+	case outputText, outputJSON:
+		return nil
+	default:
+		return fmt.Errorf("invalid output format %q: must be %q or %q", outputFormat, outputText, outputJSON)
+	}
+}
+
+// outputFormatArg returns the --output value found in args. It is used for
+// errors cobra reports before, or instead of, parsing the flags.
+func outputFormatArg(args []string) string {
+	format := outputText
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--":
+			return format
+		case arg == "--output" || arg == "-o":
+			if i+1 < len(args) {
+				i++
+				format = args[i]
+			}
+		case strings.HasPrefix(arg, "--output="):
+			format = strings.TrimPrefix(arg, "--output=")
+		case strings.HasPrefix(arg, "-o"):
+			format = strings.TrimPrefix(strings.TrimPrefix(arg, "-o"), "=")
+		}
+	}
+	return format
+}
+
+func commandName(cmd *cobra.Command) string {
+	return strings.TrimPrefix(cmd.CommandPath(), cmd.Root().Name()+" ")
+}
+
+// emitResult prints the outcome of a successful command. In text mode text is
+// printed as-is (when non-empty); in JSON mode result is wrapped in the
+// command envelope.
+func emitResult(cmd *cobra.Command, result interface{}, text string) {
+	if !jsonOutput() {
+		if text != "" {
+			fmt.Println(text)
+		}
+		return
+	}
+
+	writeJSON(commandOutput{Command: commandName(cmd), OK: true, Result: result})
+}
+
+// exitWithError reports err and exits with the code of its failure class.
+func exitWithError(cmd *cobra.Command, err error) {
+	exitWithErrorResult(cmd, nil, err)
+}
+
+// exitWithErrorResult is exitWithError for commands that still have a result
+// worth reporting alongside the failure, such as a doctor report.
+func exitWithErrorResult(cmd *cobra.Command, result interface{}, err error) {
+	code, exitCode := errorCodeFailure, exitCodeFailure
+	var classified *cliError
+	if errors.As(err, &classified) {
+		code, exitCode = classified.code, classified.exitCode
+	}
+
+	if jsonOutput() {
+		writeJSON(commandOutput{
+			Command: commandName(cmd),
+			OK:      false,
+			Result:  result,
+			Error:   &outputError{Code: code, Message: err.Error(), ExitCode: exitCode},
+		})
+	} else {
+		logger.Error(err.Error())
+	}
+
+	closeEventSink()
+	shutdownTelemetry()
+	os.Exit(exitCode)
+}
+
+func writeJSON(doc commandOutput) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		fmt.Fprintf(os.Stderr, "unable to encode output: %v\n", err)
+		os.Exit(exitCodeFailure)
+	}
+}
+
+// serviceResult is the JSON result of the service management commands.
+type serviceResult struct {
+	Action  string `json:"action"`
+	Changed bool   `json:"changed"`
+	Hint    string `json:"hint,omitempty"`
+}
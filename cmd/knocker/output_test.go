@@ -0,0 +1,56 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/FarisZR/knocker-cli/internal/api"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewAPIErrorClassifiesFailures(t *testing.T) {
+	cases := []struct {
+		name     string
+		err      error
+		code     string
+		exitCode int
+	}{
+		{"unauthorized", &api.StatusError{Operation: "knock", StatusCode: http.StatusUnauthorized}, errorCodeAuth, exitCodeAuth},
+		{"server error", &api.StatusError{Operation: "knock", StatusCode: http.StatusBadGateway}, errorCodeAPIError, exitCodeAPIError},
+		{"network", &net.OpError{Op: "dial", Err: errors.New("connection refused")}, errorCodeAPIUnreachable, exitCodeAPIUnreachable},
+		{"decode", errors.New("unexpected EOF"), errorCodeAPIError, exitCodeAPIError},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := newAPIError(fmt.Errorf("failed to knock: %w", tc.err))
+
+			var classified *cliError
+			require.ErrorAs(t, err, &classified)
+			require.Equal(t, tc.code, classified.code)
+			require.Equal(t, tc.exitCode, classified.exitCode)
+		})
+	}
+}
+
+func TestOutputFormatArg(t *testing.T) {
+	cases := []struct {
+		args []string
+		want string
+	}{
+		{nil, outputText},
+		{[]string{"status", "--output", "json"}, outputJSON},
+		{[]string{"--output=json", "status", "--bogus"}, outputJSON},
+		{[]string{"-o", "json"}, outputJSON},
+		{[]string{"-ojson"}, outputJSON},
+		{[]string{"-o=json"}, outputJSON},
+		{[]string{"--output", "json", "--output", "text"}, outputText},
+		{[]string{"knock", "--", "-o", "json"}, outputText},
+	}
+	for _, tc := range cases {
+		require.Equal(t, tc.want, outputFormatArg(tc.args), "%q", tc.args)
+	}
+}
@@ -1,10 +1,15 @@
 package main
 
 import (
+	"os"
 	"sync"
 	"time"
 
 	"github.com/FarisZR/knocker-cli/internal/api"
+	"github.com/FarisZR/knocker-cli/internal/config"
+	"github.com/FarisZR/knocker-cli/internal/dbusapi"
+	"github.com/FarisZR/knocker-cli/internal/events"
+	"github.com/FarisZR/knocker-cli/internal/metrics"
 	internalService "github.com/FarisZR/knocker-cli/internal/service"
 	"github.com/FarisZR/knocker-cli/internal/util"
 	"github.com/kardianos/service"
@@ -18,7 +23,8 @@ type program struct {
 }
 
 func (p *program) Start(s service.Service) error {
-	logger.Println("Starting Knocker service...")
+	attachLogFile(viper.GetViper())
+	logger.Info("Starting Knocker service")
 	p.mu.Lock()
 	p.quit = make(chan struct{})
 	quit := p.quit
@@ -36,7 +42,7 @@ func (p *program) run(quit <-chan struct{}) {
 
 	checkInterval := internalService.NormalizeCheckInterval(configuredCheckInterval)
 	if checkInterval != configuredCheckInterval {
-		logger.Printf("Invalid check interval detected, defaulting to %v.", checkInterval)
+		logger.Warn("Invalid check interval; using the default", "check_interval", checkInterval)
 	}
 
 	knockCadence := internalService.KnockCadenceFromTTL(ttl)
@@ -48,11 +54,71 @@ func (p *program) run(quit <-chan struct{}) {
 
 	// Perform initial health check
 	if err := apiClient.HealthCheck(); err != nil {
-		logger.Fatalf("Initial health check failed: %v. Please check your API URL and key.", err)
+		fatal("Initial health check failed; please check your API URL and key", "error_code", internalService.ErrorCodeHealthCheck, "error_msg", err.Error())
+	}
+	logger.Info("API health check successful")
+
+	if breaker := config.LoadCircuitBreaker(viper.GetViper()); breaker.Enabled {
+		apiClient.Breaker = api.NewBreaker(breaker.FailureThreshold, breaker.Backoff, breaker.MaxBackoff)
 	}
-	logger.Println("API health check successful.")
 
 	knockerService := internalService.NewService(apiClient, ipGetter, knockCadence, ipCheckURL, ttl, cadenceSource, version, logger)
+	knockerService.ExtraEntries = viper.GetStringSlice("extra_entries")
+	knockerService.RefreshMargin = viper.GetDuration("refresh_margin")
+	knockerService.Jitter = viper.GetDuration("jitter")
+	if viper.GetBool("jitter_per_host") {
+		if host, err := os.Hostname(); err != nil {
+			logger.Warn("Unable to derive the per-host jitter offset; using random jitter", "error", err)
+		} else {
+			knockerService.JitterSeed = host
+		}
+	}
+	sinks := events.MultiSink{eventSink}
+	if notifications := config.LoadNotifications(viper.GetViper()); notifications.Enabled {
+		notifier, stop, err := startNotifier(notifications)
+		if err != nil {
+			logger.Warn("Desktop notifications disabled", "error", err)
+		} else {
+			sinks = append(sinks, notifier)
+			defer stop()
+		}
+	}
+	if publisher, err := startMQTT(viper.GetViper()); err != nil {
+		logger.Warn("MQTT publishing disabled", "error", err)
+	} else if publisher != nil {
+		sinks = append(sinks, publisher)
+		defer closeMQTT(publisher)
+	}
+	knockerService.Sink = sinks
+
+	store, err := openHistory(viper.GetViper())
+	if err != nil {
+		logger.Warn("Knock history disabled", "error", err)
+	}
+	knockerService.History = store
+
+	if viper.GetString("http.listen") != "" || viper.GetString("otel.endpoint") != "" {
+		knockerService.Metrics = metrics.New()
+		startMetricsExport(viper.GetViper(), knockerService.Metrics.Gatherer())
+	}
+	if addr := viper.GetString("http.listen"); addr != "" {
+		server, err := startHTTPServer(addr, knockerService.Metrics, knockerService)
+		if err != nil {
+			fatal("Unable to start the HTTP listener", "address", addr, "error", err)
+		}
+		logger.Info("Serving metrics and health endpoints", "url", "http://"+addr)
+		defer stopHTTPServer(server)
+	}
+
+	if viper.GetBool("dbus.enabled") {
+		stop, err := startDBusServer(knockerService)
+		if err != nil {
+			logger.Warn("D-Bus interface disabled", "error", err)
+		} else {
+			logger.Info("Exported the service on the session bus", "bus_name", dbusapi.BusName)
+			defer stop()
+		}
+	}
 
 	p.mu.Lock()
 	p.service = knockerService
@@ -66,7 +132,7 @@ func (p *program) run(quit <-chan struct{}) {
 	knockerService.Run(quit)
 }
 func (p *program) Stop(s service.Service) error {
-	logger.Println("Stopping Knocker service...")
+	logger.Info("Stopping Knocker service")
 	p.mu.RLock()
 	svc := p.service
 	p.mu.RUnlock()
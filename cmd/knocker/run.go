@@ -5,17 +5,18 @@ import (
 )
 
 var runCmd = &cobra.Command{
-	Use:   "run",
-	Short: "Run the Knocker service",
-	Long:  `This command starts the Knocker service, which will run in the foreground.`,
+	Use:         "run",
+	Short:       "Run the Knocker service",
+	Long:        `This command starts the Knocker service, which will run in the foreground.`,
+	Annotations: map[string]string{emitsEventsAnnotation: "true"},
 	Run: func(cmd *cobra.Command, args []string) {
 		s, err := newServiceInstance(false)
 		if err != nil {
-			logger.Fatal(err)
+			fatal(err.Error())
 		}
 
 		if err := s.Run(); err != nil {
-			logger.Fatal(err)
+			fatal(err.Error())
 		}
 	},
 }
@@ -59,12 +59,7 @@ func applyPlatformServiceOptions(cfg *service.Config) {
 		cfg.Option = service.KeyValue{}
 	}
 
-	ttl := viper.GetInt("ttl")
-	restartDelay := internalService.RestartDelay(ttl)
-	restartSeconds := int(restartDelay / time.Second)
-	if restartSeconds < 1 {
-		restartSeconds = 1
-	}
+	restartSeconds := restartSecondsFromConfig()
 
 	switch runtime.GOOS {
 	case "linux":
@@ -81,6 +76,15 @@ func applyPlatformServiceOptions(cfg *service.Config) {
 	}
 }
 
+func restartSecondsFromConfig() int {
+	restartDelay := internalService.RestartDelay(viper.GetInt("ttl"))
+	restartSeconds := int(restartDelay / time.Second)
+	if restartSeconds < 1 {
+		restartSeconds = 1
+	}
+	return restartSeconds
+}
+
 func systemdUserUnitTemplate(restartSec int) string {
 	if restartSec <= 0 {
 		restartSec = 30
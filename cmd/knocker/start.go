@@ -11,14 +11,15 @@ var startCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		s, err := newServiceInstance(false)
 		if err != nil {
-			logger.Fatal(err)
+			exitWithError(cmd, newServiceError(err))
 		}
 
 		if err := s.Start(); err != nil {
-			logger.Fatal(err)
+			exitWithError(cmd, newServiceError(err))
 		}
 
-		logger.Println("Service started successfully.")
+		logger.Info("Service started successfully")
+		emitResult(cmd, serviceResult{Action: "start", Changed: true}, "")
 	},
 }
 
@@ -1,8 +1,10 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 
+	"github.com/kardianos/service"
 	"github.com/spf13/cobra"
 )
 
@@ -13,18 +15,36 @@ var statusCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		s, err := newServiceInstance(false)
 		if err != nil {
-			logger.Fatal(err)
+			exitWithError(cmd, newServiceError(err))
 		}
 
 		status, err := s.Status()
 		if err != nil {
-			logger.Fatal(err)
+			if errors.Is(err, service.ErrNotInstalled) {
+				exitWithErrorResult(cmd, statusResult{State: "not_installed"}, newServiceError(err))
+			}
+			exitWithError(cmd, newServiceError(err))
 		}
 
-		fmt.Printf("Service status: %v\n", status)
+		emitResult(cmd, statusResult{State: serviceStatusName(status)}, fmt.Sprintf("Service status: %v", status))
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(statusCmd)
 }
+
+type statusResult struct {
+	State string `json:"state"`
+}
+
+func serviceStatusName(status service.Status) string {
+	switch status {
+	case service.StatusRunning:
+		return "running"
+	case service.StatusStopped:
+		return "stopped"
+	default:
+		return "unknown"
+	}
+}
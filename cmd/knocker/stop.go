@@ -11,14 +11,15 @@ var stopCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		s, err := newServiceInstance(false)
 		if err != nil {
-			logger.Fatal(err)
+			exitWithError(cmd, newServiceError(err))
 		}
 
 		if err := s.Stop(); err != nil {
-			logger.Fatal(err)
+			exitWithError(cmd, newServiceError(err))
 		}
 
-		logger.Println("Service stopped successfully.")
+		logger.Info("Service stopped successfully")
+		emitResult(cmd, serviceResult{Action: "stop", Changed: true}, "")
 	},
 }
 
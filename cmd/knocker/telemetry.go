@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/FarisZR/knocker-cli/internal/telemetry"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/spf13/viper"
+	"go.opentelemetry.io/otel"
+)
+
+const defaultMetricsExportInterval = time.Minute
+
+var tracer = otel.Tracer("github.com/FarisZR/knocker-cli/cmd/knocker")
+
+// telemetryShutdowns flush the OpenTelemetry exporters started for this
+// process.
+var telemetryShutdowns []telemetry.Shutdown
+
+func telemetryConfig(v *viper.Viper) telemetry.Config {
+	return telemetry.Config{
+		Endpoint:    v.GetString("otel.endpoint"),
+		Headers:     v.GetStringMapString("otel.headers"),
+		ServiceName: v.GetString("otel.service_name"),
+		Version:     version,
+	}
+}
+
+// setupTracing exports traces when otel.endpoint is set. Failures only
+// disable tracing.
+func setupTracing(v *viper.Viper) {
+	cfg := telemetryConfig(v)
+	if cfg.Endpoint == "" {
+		return
+	}
+
+	shutdown, err := telemetry.SetupTracing(context.Background(), cfg)
+	if err != nil {
+		logger.Warn("Tracing disabled", "error", err)
+		return
+	}
+	telemetryShutdowns = append(telemetryShutdowns, shutdown)
+}
+
+// startMetricsExport pushes the service metrics to otel.endpoint, when set.
+func startMetricsExport(v *viper.Viper, gatherer prometheus.Gatherer) {
+	cfg := telemetryConfig(v)
+	if cfg.Endpoint == "" {
+		return
+	}
+
+	interval := v.GetDuration("otel.metrics_interval")
+	if interval <= 0 {
+		interval = defaultMetricsExportInterval
+	}
+
+	shutdown, err := telemetry.StartMetricsExport(context.Background(), cfg, gatherer, interval)
+	if err != nil {
+		logger.Warn("Metrics export disabled", "error", err)
+		return
+	}
+	telemetryShutdowns = append(telemetryShutdowns, shutdown)
+}
+
+// shutdownTelemetry flushes pending spans and metrics. It must run before the
+// process exits.
+func shutdownTelemetry() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for _, shutdown := range telemetryShutdowns {
+		if err := shutdown(ctx); err != nil {
+			logger.Warn("Flushing telemetry failed", "error", err)
+		}
+	}
+	telemetryShutdowns = nil
+}
@@ -14,22 +14,24 @@ var uninstallCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		s, err := newServiceInstance(false)
 		if err != nil {
-			logger.Fatal(err)
+			exitWithError(cmd, newServiceError(err))
 		}
 
 		if stopErr := s.Stop(); stopErr != nil && !errors.Is(stopErr, service.ErrNotInstalled) {
-			logger.Printf("Warning: could not stop service prior to uninstall: %v", stopErr)
+			logger.Warn("Could not stop service prior to uninstall", "error", stopErr)
 		}
 
 		if err := s.Uninstall(); err != nil {
 			if errors.Is(err, service.ErrNotInstalled) {
-				logger.Println("Service not installed, nothing to uninstall.")
+				logger.Info("Service not installed, nothing to uninstall")
+				emitResult(cmd, serviceResult{Action: "uninstall", Changed: false}, "")
 				return
 			}
-			logger.Fatal(err)
+			exitWithError(cmd, newServiceError(err))
 		}
 
-		logger.Println("Service uninstalled successfully.")
+		logger.Info("Service uninstalled successfully")
+		emitResult(cmd, serviceResult{Action: "uninstall", Changed: true}, "")
 	},
 }
 
@@ -0,0 +1,165 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// BreakerState is the state of a Breaker.
+type BreakerState string
+
+const (
+	// BreakerClosed lets every request through.
+	BreakerClosed BreakerState = "closed"
+	// BreakerOpen refuses requests until the back-off is over.
+	BreakerOpen BreakerState = "open"
+	// BreakerHalfOpen lets a probe through to find out whether the API is
+	// back.
+	BreakerHalfOpen BreakerState = "half_open"
+)
+
+// Breaker defaults, used for zero or negative settings.
+const (
+	DefaultBreakerThreshold  = 3
+	DefaultBreakerBackoff    = 30 * time.Second
+	DefaultBreakerMaxBackoff = 10 * time.Minute
+)
+
+// ErrBreakerOpen is returned for requests refused while the breaker is open.
+var ErrBreakerOpen = errors.New("API unavailable: circuit breaker open")
+
+// Breaker is a circuit breaker guarding the API. Once Threshold requests in a
+// row found the API unavailable (see Unavailable), it opens and refuses
+// requests with ErrBreakerOpen for Backoff. The first request after that is
+// a probe: if it succeeds the breaker closes, otherwise it opens again for
+// twice as long, up to MaxBackoff.
+type Breaker struct {
+	Threshold  int
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+
+	now func() time.Time
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	backoff  time.Duration
+	retryAt  time.Time
+	lastErr  error
+}
+
+// BreakerSnapshot is a point-in-time view of a Breaker.
+type BreakerSnapshot struct {
+	State BreakerState
+	// Failures counts the consecutive requests that found the API
+	// unavailable.
+	Failures int
+	// RetryAt is when an open breaker lets the next probe through.
+	RetryAt time.Time
+	// LastError is the most recent failure, if any.
+	LastError error
+}
+
+// NewBreaker returns a closed breaker. Zero or negative settings fall back to
+// the defaults.
+func NewBreaker(threshold int, backoff, maxBackoff time.Duration) *Breaker {
+	if threshold <= 0 {
+		threshold = DefaultBreakerThreshold
+	}
+	if backoff <= 0 {
+		backoff = DefaultBreakerBackoff
+	}
+	if maxBackoff <= 0 {
+		maxBackoff = DefaultBreakerMaxBackoff
+	}
+	return &Breaker{
+		Threshold:  threshold,
+		Backoff:    backoff,
+		MaxBackoff: max(maxBackoff, backoff),
+		now:        time.Now,
+		state:      BreakerClosed,
+	}
+}
+
+// Snapshot returns the current state. A nil breaker reports the zero value.
+func (b *Breaker) Snapshot() BreakerSnapshot {
+	if b == nil {
+		return BreakerSnapshot{}
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return BreakerSnapshot{State: b.state, Failures: b.failures, RetryAt: b.retryAt, LastError: b.lastErr}
+}
+
+// allow reports whether a request may be sent, letting a probe through once
+// an open breaker's back-off is over.
+func (b *Breaker) allow() error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == BreakerOpen {
+		if b.now().Before(b.retryAt) {
+			return ErrBreakerOpen
+		}
+		b.state = BreakerHalfOpen
+	}
+	return nil
+}
+
+// record updates the breaker with the outcome of a request.
+func (b *Breaker) record(err error) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if errors.Is(err, context.Canceled) {
+		// The probe was abandoned; the next request probes again.
+		if b.state == BreakerHalfOpen {
+			b.state = BreakerOpen
+		}
+		return
+	}
+	if !Unavailable(err) {
+		b.state, b.failures, b.backoff, b.retryAt, b.lastErr = BreakerClosed, 0, 0, time.Time{}, nil
+		return
+	}
+
+	b.failures++
+	b.lastErr = err
+	switch b.state {
+	case BreakerHalfOpen:
+		b.open(min(b.backoff*2, b.MaxBackoff))
+	case BreakerClosed:
+		if b.failures >= b.Threshold {
+			b.open(b.Backoff)
+		}
+	}
+}
+
+// open refuses requests for backoff. b.mu must be held.
+func (b *Breaker) open(backoff time.Duration) {
+	b.state = BreakerOpen
+	b.backoff = backoff
+	b.retryAt = b.now().Add(backoff)
+}
+
+// Unavailable reports whether err means the API could not serve a request:
+// no response was received or it answered with a 5xx status. Rejections
+// such as a wrong API key show the API is up, and a cancelled request says
+// nothing about it.
+func Unavailable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= 500
+	}
+	return true
+}
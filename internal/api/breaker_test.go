@@ -0,0 +1,81 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBreakerOpensAndBacksOff(t *testing.T) {
+	clock := time.Unix(1_750_000_000, 0)
+	b := NewBreaker(2, 10*time.Second, 25*time.Second)
+	b.now = func() time.Time { return clock }
+	down := errors.New("connection refused")
+
+	require.NoError(t, b.allow())
+	b.record(down)
+	assert.Equal(t, BreakerClosed, b.Snapshot().State, "one failure is tolerated")
+	b.record(down)
+	snap := b.Snapshot()
+	assert.Equal(t, BreakerOpen, snap.State)
+	assert.Equal(t, 2, snap.Failures)
+	assert.Equal(t, clock.Add(10*time.Second), snap.RetryAt)
+	assert.Equal(t, down, snap.LastError)
+	assert.ErrorIs(t, b.allow(), ErrBreakerOpen)
+
+	// A failed probe doubles the wait, up to the maximum.
+	clock = clock.Add(10 * time.Second)
+	require.NoError(t, b.allow())
+	assert.Equal(t, BreakerHalfOpen, b.Snapshot().State)
+	b.record(&StatusError{Operation: "knock", StatusCode: http.StatusBadGateway})
+	assert.Equal(t, clock.Add(20*time.Second), b.Snapshot().RetryAt)
+	clock = clock.Add(20 * time.Second)
+	require.NoError(t, b.allow())
+	b.record(down)
+	assert.Equal(t, clock.Add(25*time.Second), b.Snapshot().RetryAt)
+
+	// An abandoned probe leaves the breaker open.
+	clock = clock.Add(25 * time.Second)
+	require.NoError(t, b.allow())
+	b.record(context.Canceled)
+	assert.Equal(t, BreakerOpen, b.Snapshot().State)
+
+	// Any answer that is not a server error closes it.
+	require.NoError(t, b.allow())
+	b.record(&StatusError{Operation: "knock", StatusCode: http.StatusUnauthorized})
+	assert.Equal(t, BreakerSnapshot{State: BreakerClosed}, b.Snapshot())
+}
+
+func TestNilBreakerAllowsEverything(t *testing.T) {
+	var b *Breaker
+	assert.NoError(t, b.allow())
+	b.record(errors.New("down"))
+	assert.Equal(t, BreakerSnapshot{}, b.Snapshot())
+}
+
+func TestClientBreakerStopsRequestsWhileOpen(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-api-key")
+	client.Breaker = NewBreaker(2, time.Hour, time.Hour)
+
+	assert.Error(t, client.HealthCheck())
+	_, err := client.Knock("", 0)
+	assert.Error(t, err)
+	_, err = client.Knock("", 0)
+	assert.ErrorIs(t, err, ErrBreakerOpen)
+	assert.Equal(t, int32(2), requests.Load())
+	assert.Equal(t, BreakerOpen, client.Breaker.Snapshot().State)
+}
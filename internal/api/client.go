@@ -2,22 +2,65 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = otel.Tracer("github.com/FarisZR/knocker-cli/internal/api")
+
 type Client struct {
 	BaseURL    string
 	APIKey     string
 	HTTPClient *http.Client
+	// Breaker, when set, guards every request: while it is open requests
+	// fail with ErrBreakerOpen without reaching the API.
+	Breaker *Breaker
 }
 
 type KnockResponse struct {
-	WhitelistedEntry string `json:"whitelisted_entry"`
-	ExpiresAt        int64  `json:"expires_at"`
-	ExpiresInSeconds int    `json:"expires_in_seconds"`
+	WhitelistedEntry   string   `json:"whitelisted_entry"`
+	WhitelistedEntries []string `json:"whitelisted_entries,omitempty"`
+	ExpiresAt          int64    `json:"expires_at"`
+	ExpiresInSeconds   int      `json:"expires_in_seconds"`
+}
+
+// Entries returns every entry the API whitelisted. Servers answering a single
+// address only fill WhitelistedEntry.
+func (r *KnockResponse) Entries() []string {
+	if r == nil {
+		return nil
+	}
+	if len(r.WhitelistedEntries) > 0 {
+		return r.WhitelistedEntries
+	}
+	if r.WhitelistedEntry != "" {
+		return []string{r.WhitelistedEntry}
+	}
+	return nil
+}
+
+// StatusError is returned when the API answers with an unexpected HTTP status.
+type StatusError struct {
+	Operation  string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s failed with status code: %d", e.Operation, e.StatusCode)
+}
+
+// Unauthorized reports whether the API rejected the API key.
+func (e *StatusError) Unauthorized() bool {
+	return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
 }
 
 func NewClient(baseURL string, apiKey string) *Client {
@@ -29,28 +72,49 @@ func NewClient(baseURL string, apiKey string) *Client {
 }
 
 func (c *Client) HealthCheck() error {
+	return c.HealthCheckContext(context.Background())
+}
+
+// HealthCheckContext is HealthCheck with a context, whose trace is propagated
+// to the server.
+func (c *Client) HealthCheckContext(ctx context.Context) error {
 	req, err := http.NewRequest("GET", fmt.Sprintf("%s/health", c.BaseURL), nil)
 	if err != nil {
 		return err
 	}
 
-	res, err := c.HTTPClient.Do(req)
+	res, err := c.do(ctx, "health check", req)
 	if err != nil {
 		return err
 	}
 	defer res.Body.Close()
 
 	if res.StatusCode != http.StatusOK {
-		return fmt.Errorf("health check failed with status code: %d", res.StatusCode)
+		return &StatusError{Operation: "health check", StatusCode: res.StatusCode}
 	}
 
 	return nil
 }
 
-func (c *Client) Knock(ipAddress string, ttl int) (*KnockResponse, error) {
-	requestBody := map[string]interface{}{}
+// NewKnockRequest builds the request Knock sends, without sending it.
+func (c *Client) NewKnockRequest(ipAddress string, ttl int) (*http.Request, error) {
+	var entries []string
 	if ipAddress != "" {
-		requestBody["ip_address"] = ipAddress
+		entries = []string{ipAddress}
+	}
+	return c.NewKnockEntriesRequest(entries, ttl)
+}
+
+// NewKnockEntriesRequest builds the request KnockEntries sends, without
+// sending it. A single entry is sent as ip_address, several as ip_addresses.
+func (c *Client) NewKnockEntriesRequest(entries []string, ttl int) (*http.Request, error) {
+	requestBody := map[string]interface{}{}
+	switch len(entries) {
+	case 0:
+	case 1:
+		requestBody["ip_address"] = entries[0]
+	default:
+		requestBody["ip_addresses"] = entries
 	}
 	if ttl > 0 {
 		requestBody["ttl"] = ttl
@@ -69,14 +133,48 @@ func (c *Client) Knock(ipAddress string, ttl int) (*KnockResponse, error) {
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("X-Api-Key", c.APIKey)
 
-	res, err := c.HTTPClient.Do(req)
+	return req, nil
+}
+
+func (c *Client) Knock(ipAddress string, ttl int) (*KnockResponse, error) {
+	return c.KnockContext(context.Background(), ipAddress, ttl)
+}
+
+// KnockContext is Knock with a context, whose trace is propagated to the
+// server.
+func (c *Client) KnockContext(ctx context.Context, ipAddress string, ttl int) (*KnockResponse, error) {
+	req, err := c.NewKnockRequest(ipAddress, ttl)
+	if err != nil {
+		return nil, err
+	}
+	return c.doKnock(ctx, req)
+}
+
+// KnockEntries whitelists several IP addresses or CIDR ranges in one request.
+// An empty list whitelists the caller's address, like Knock("", ttl).
+func (c *Client) KnockEntries(entries []string, ttl int) (*KnockResponse, error) {
+	return c.KnockEntriesContext(context.Background(), entries, ttl)
+}
+
+// KnockEntriesContext is KnockEntries with a context, whose trace is
+// propagated to the server.
+func (c *Client) KnockEntriesContext(ctx context.Context, entries []string, ttl int) (*KnockResponse, error) {
+	req, err := c.NewKnockEntriesRequest(entries, ttl)
+	if err != nil {
+		return nil, err
+	}
+	return c.doKnock(ctx, req)
+}
+
+func (c *Client) doKnock(ctx context.Context, req *http.Request) (*KnockResponse, error) {
+	res, err := c.do(ctx, "knock", req)
 	if err != nil {
 		return nil, err
 	}
 	defer res.Body.Close()
 
 	if res.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("knock failed with status code: %d", res.StatusCode)
+		return nil, &StatusError{Operation: "knock", StatusCode: res.StatusCode}
 	}
 
 	var knockResponse KnockResponse
@@ -85,4 +183,44 @@ func (c *Client) Knock(ipAddress string, ttl int) (*KnockResponse, error) {
 	}
 
 	return &knockResponse, nil
-}
\ No newline at end of file
+}
+
+// do sends req inside a client span named after operation and injects the
+// W3C trace context headers, so the server can join the trace.
+func (c *Client) do(ctx context.Context, operation string, req *http.Request) (*http.Response, error) {
+	if err := c.Breaker.allow(); err != nil {
+		return nil, err
+	}
+
+	ctx, span := tracer.Start(ctx, "knocker.api "+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(req.Method),
+			semconv.URLFull(req.URL.String()),
+			semconv.ServerAddress(req.URL.Hostname()),
+		),
+	)
+	defer span.End()
+
+	req = req.WithContext(ctx)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	res, err := c.HTTPClient.Do(req)
+	if err != nil {
+		c.Breaker.record(err)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	if res.StatusCode >= 500 {
+		c.Breaker.record(&StatusError{Operation: operation, StatusCode: res.StatusCode})
+	} else {
+		c.Breaker.record(nil)
+	}
+
+	span.SetAttributes(semconv.HTTPResponseStatusCode(res.StatusCode))
+	if res.StatusCode >= 400 {
+		span.SetStatus(codes.Error, http.StatusText(res.StatusCode))
+	}
+	return res, nil
+}
@@ -2,6 +2,7 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -105,4 +106,66 @@ func TestKnockWithTTL(t *testing.T) {
 	if err != nil {
 		t.Errorf("Knock with TTL failed: %v", err)
 	}
-}
\ No newline at end of file
+}
+
+func TestKnockReturnsStatusError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "wrong-key")
+	_, err := client.Knock("", 0)
+
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) {
+		t.Fatalf("expected *StatusError, got %T: %v", err, err)
+	}
+	if !statusErr.Unauthorized() {
+		t.Errorf("expected unauthorized status, got %d", statusErr.StatusCode)
+	}
+	if err.Error() != "knock failed with status code: 401" {
+		t.Errorf("unexpected error message: %q", err.Error())
+	}
+}
+
+func TestKnockEntriesSendsListAndParsesEntries(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var requestBody struct {
+			IPAddresses []string `json:"ip_addresses"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+		if len(requestBody.IPAddresses) != 2 || requestBody.IPAddresses[1] != "203.0.113.0/29" {
+			t.Errorf("Unexpected ip_addresses: %v", requestBody.IPAddresses)
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(KnockResponse{
+			WhitelistedEntries: requestBody.IPAddresses,
+			ExpiresAt:          time.Now().Unix() + 600,
+			ExpiresInSeconds:   600,
+		})
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "test-api-key")
+	knockResponse, err := client.KnockEntries([]string{"198.51.100.1", "203.0.113.0/29"}, 600)
+	if err != nil {
+		t.Fatalf("KnockEntries failed: %v", err)
+	}
+
+	entries := knockResponse.Entries()
+	if len(entries) != 2 || entries[0] != "198.51.100.1" {
+		t.Errorf("Unexpected entries: %v", entries)
+	}
+}
+
+func TestKnockResponseEntriesFallsBackToSingleEntry(t *testing.T) {
+	response := &KnockResponse{WhitelistedEntry: "127.0.0.1"}
+	entries := response.Entries()
+	if len(entries) != 1 || entries[0] != "127.0.0.1" {
+		t.Errorf("Unexpected entries: %v", entries)
+	}
+}
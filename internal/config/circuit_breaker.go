@@ -0,0 +1,34 @@
+package config
+
+import (
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// CircuitBreaker configures the circuit breaker the service puts around the
+// Knocker API:
+//
+//	circuit_breaker:
+//	  enabled: true         # default
+//	  failure_threshold: 3  # failed requests in a row before the API counts as down
+//	  backoff: 30s          # wait before the first probe; doubles after each failed probe
+//	  max_backoff: 10m      # longest wait between probes
+type CircuitBreaker struct {
+	Enabled          bool
+	FailureThreshold int
+	Backoff          time.Duration
+	MaxBackoff       time.Duration
+}
+
+// LoadCircuitBreaker decodes the `circuit_breaker` settings. The breaker is
+// enabled unless disabled; zero values are left for api.NewBreaker to
+// default.
+func LoadCircuitBreaker(v *viper.Viper) CircuitBreaker {
+	return CircuitBreaker{
+		Enabled:          !v.IsSet("circuit_breaker.enabled") || v.GetBool("circuit_breaker.enabled"),
+		FailureThreshold: v.GetInt("circuit_breaker.failure_threshold"),
+		Backoff:          v.GetDuration("circuit_breaker.backoff"),
+		MaxBackoff:       v.GetDuration("circuit_breaker.max_backoff"),
+	}
+}
@@ -0,0 +1,19 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadCircuitBreaker(t *testing.T) {
+	cb := LoadCircuitBreaker(newViperFromYAML(t, "circuit_breaker:\n  failure_threshold: 5\n  backoff: 1m\n"))
+	assert.True(t, cb.Enabled)
+	assert.Equal(t, 5, cb.FailureThreshold)
+	assert.Equal(t, time.Minute, cb.Backoff)
+	assert.Zero(t, cb.MaxBackoff)
+
+	assert.True(t, LoadCircuitBreaker(newViperFromYAML(t, "ttl: 60\n")).Enabled)
+	assert.False(t, LoadCircuitBreaker(newViperFromYAML(t, "circuit_breaker:\n  enabled: false\n")).Enabled)
+}
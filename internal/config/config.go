@@ -1,6 +1,7 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"strings"
@@ -10,8 +11,14 @@ import (
 
 var CfgFile string
 
+// loadErr records the last error encountered while reading the config file so
+// that commands such as `config validate` can report it.
+var loadErr error
+
 // InitConfig reads in config file and ENV variables if set.
 func InitConfig() {
+	loadErr = nil
+
 	if CfgFile != "" {
 		// Use config file from the flag.
 		viper.SetConfigFile(CfgFile)
@@ -34,7 +41,26 @@ func InitConfig() {
 	viper.AutomaticEnv() // read in environment variables that match
 
 	// If a config file is found, read it in.
-	if err := viper.ReadInConfig(); err == nil {
-		fmt.Println("Using config file:", viper.ConfigFileUsed())
+	err := viper.ReadInConfig()
+	if err == nil {
+		fmt.Fprintln(os.Stderr, "Using config file:", viper.ConfigFileUsed())
+		return
+	}
+
+	var notFound viper.ConfigFileNotFoundError
+	if !errors.As(err, &notFound) {
+		loadErr = err
+		fmt.Fprintf(os.Stderr, "Error reading config file: %v\n", err)
 	}
-}
\ No newline at end of file
+}
+
+// LoadError returns the error raised while reading the config file during the
+// last InitConfig call, or nil when the file was read (or simply absent).
+func LoadError() error {
+	return loadErr
+}
+
+// EnvVar returns the environment variable consulted for the given config key.
+func EnvVar(key string) string {
+	return "KNOCKER_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
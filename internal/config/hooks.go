@@ -0,0 +1,94 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+)
+
+const (
+	defaultHookTimeout     = 30 * time.Second
+	defaultHookConcurrency = 2
+)
+
+// Hooks maps event types to the shell commands run when they occur:
+//
+//	hooks:
+//	  WhitelistApplied: systemctl --user restart ssh-tunnel
+//	  WhitelistExpired:
+//	    - notify-send "Knocker" "Whitelist expired"
+//	    - ~/bin/vpn-refresh
+//	hook_timeout: 30s
+//	hook_concurrency: 2
+type Hooks struct {
+	// Commands is keyed by lower-cased event type.
+	Commands    map[string][]string
+	Timeout     time.Duration
+	Concurrency int
+}
+
+// LoadHooks decodes the `hooks`, `hook_timeout` and `hook_concurrency`
+// settings. Unset or zero limits fall back to the defaults.
+func LoadHooks(v *viper.Viper) (Hooks, error) {
+	hooks := Hooks{
+		Commands:    map[string][]string{},
+		Timeout:     defaultHookTimeout,
+		Concurrency: defaultHookConcurrency,
+	}
+
+	for eventType, value := range v.GetStringMap("hooks") {
+		var commands []string
+		switch typed := value.(type) {
+		case string:
+			commands = []string{typed}
+		case []interface{}:
+			for _, item := range typed {
+				command, err := cast.ToStringE(item)
+				if err != nil {
+					return Hooks{}, fmt.Errorf("hooks.%s: commands must be strings", eventType)
+				}
+				commands = append(commands, command)
+			}
+		default:
+			return Hooks{}, fmt.Errorf("hooks.%s: expected a command or a list of commands", eventType)
+		}
+
+		for _, command := range commands {
+			if strings.TrimSpace(command) == "" {
+				return Hooks{}, fmt.Errorf("hooks.%s: command must not be empty", eventType)
+			}
+		}
+		hooks.Commands[strings.ToLower(eventType)] = commands
+	}
+
+	if v.IsSet("hook_timeout") {
+		timeout, err := cast.ToDurationE(v.Get("hook_timeout"))
+		if err != nil || timeout < 0 {
+			return Hooks{}, fmt.Errorf("hook_timeout must be a duration such as 30s")
+		}
+		if timeout > 0 {
+			hooks.Timeout = timeout
+		}
+	}
+	if v.IsSet("hook_concurrency") {
+		concurrency, err := cast.ToIntE(v.Get("hook_concurrency"))
+		if err != nil || concurrency < 0 {
+			return Hooks{}, fmt.Errorf("hook_concurrency must be a positive integer")
+		}
+		if concurrency > 0 {
+			hooks.Concurrency = concurrency
+		}
+	}
+
+	return hooks, nil
+}
+
+func checkHooks(v *viper.Viper) []Issue {
+	if _, err := LoadHooks(v); err != nil {
+		return []Issue{{Key: "hooks", Severity: SeverityError, Message: err.Error()}}
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadHooksAcceptsCommandsAndLists(t *testing.T) {
+	v := newViperFromYAML(t, `hooks:
+  WhitelistApplied: systemctl --user restart ssh-tunnel
+  WhitelistExpired:
+    - notify-send "Knocker" "Whitelist expired"
+    - ~/bin/vpn-refresh
+hook_timeout: 5s
+hook_concurrency: 4
+`)
+
+	hooks, err := LoadHooks(v)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"systemctl --user restart ssh-tunnel"}, hooks.Commands["whitelistapplied"])
+	assert.Len(t, hooks.Commands["whitelistexpired"], 2)
+	assert.Equal(t, 5*time.Second, hooks.Timeout)
+	assert.Equal(t, 4, hooks.Concurrency)
+}
+
+func TestLoadHooksAppliesDefaults(t *testing.T) {
+	hooks, err := LoadHooks(newViperFromYAML(t, "hooks:\n  Error: logger knocker failed\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, 30*time.Second, hooks.Timeout)
+	assert.Equal(t, 2, hooks.Concurrency)
+}
+
+func TestValidateRejectsInvalidHooks(t *testing.T) {
+	v := newViperFromYAML(t, "api_url: https://knocker.example.com\napi_key: secret\nhooks:\n  Error: ''\n")
+	assert.NotNil(t, issueFor(Validate(v), "hooks"))
+
+	v = newViperFromYAML(t, "api_url: https://knocker.example.com\napi_key: secret\nhook_timeout: soon\n")
+	assert.NotNil(t, issueFor(Validate(v), "hook_timeout"))
+}
@@ -0,0 +1,63 @@
+package config
+
+import (
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	defaultLogMaxSizeMB  = 10
+	defaultLogMaxAge     = 7 * 24 * time.Hour
+	defaultLogMaxBackups = 5
+)
+
+// LogFile configures the log file written when Knocker runs under the
+// service manager:
+//
+//	log:
+//	  file: /Library/Logs/knocker.log
+//	  max_size_mb: 10   # rotate once the file reaches this size
+//	  max_age: 168h     # or once it is this old
+//	  max_backups: 5    # rotated files to keep
+//	  compress: true    # gzip rotated files
+//
+// A top-level log_file is accepted in place of log.file.
+type LogFile struct {
+	Path       string
+	MaxSize    int64
+	MaxAge     time.Duration
+	MaxBackups int
+	Compress   bool
+}
+
+// LoadLogFile decodes the `log` file settings. It returns false when no file
+// is set. Unset or zero values fall back to the defaults, and compression is
+// on unless disabled.
+func LoadLogFile(v *viper.Viper) (LogFile, bool) {
+	path := v.GetString("log.file")
+	if path == "" {
+		path = v.GetString("log_file")
+	}
+	if path == "" {
+		return LogFile{}, false
+	}
+
+	l := LogFile{
+		Path:       path,
+		MaxSize:    v.GetInt64("log.max_size_mb") * 1024 * 1024,
+		MaxAge:     v.GetDuration("log.max_age"),
+		MaxBackups: v.GetInt("log.max_backups"),
+		Compress:   !v.IsSet("log.compress") || v.GetBool("log.compress"),
+	}
+	if l.MaxSize <= 0 {
+		l.MaxSize = defaultLogMaxSizeMB * 1024 * 1024
+	}
+	if l.MaxAge <= 0 {
+		l.MaxAge = defaultLogMaxAge
+	}
+	if l.MaxBackups <= 0 {
+		l.MaxBackups = defaultLogMaxBackups
+	}
+	return l, true
+}
@@ -0,0 +1,30 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadLogFile(t *testing.T) {
+	l, ok := LoadLogFile(newViperFromYAML(t, "log:\n  file: /tmp/knocker.log\n  max_size_mb: 2\n  max_backups: 3\n"))
+	assert.True(t, ok)
+	assert.Equal(t, "/tmp/knocker.log", l.Path)
+	assert.Equal(t, int64(2*1024*1024), l.MaxSize)
+	assert.Equal(t, 7*24*time.Hour, l.MaxAge)
+	assert.Equal(t, 3, l.MaxBackups)
+	assert.True(t, l.Compress)
+
+	l, _ = LoadLogFile(newViperFromYAML(t, "log:\n  file: knocker.log\n  max_age: 24h\n  compress: false\n"))
+	assert.Equal(t, 24*time.Hour, l.MaxAge)
+	assert.False(t, l.Compress)
+
+	l, ok = LoadLogFile(newViperFromYAML(t, "log_file: /tmp/knocker.log\nlog:\n  max_backups: 3\n"))
+	assert.True(t, ok, "log_file is accepted in place of log.file")
+	assert.Equal(t, "/tmp/knocker.log", l.Path)
+	assert.Equal(t, 3, l.MaxBackups)
+
+	_, ok = LoadLogFile(newViperFromYAML(t, "log:\n  level: debug\n"))
+	assert.False(t, ok)
+}
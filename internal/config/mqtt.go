@@ -0,0 +1,136 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	defaultMQTTQoS             = 1
+	defaultMQTTDiscoveryPrefix = "homeassistant"
+)
+
+// MQTT configures publishing the service status and events to an MQTT
+// broker, typically for Home Assistant:
+//
+//	mqtt:
+//	  broker: mqtts://broker.lan:8883
+//	  username: knocker
+//	  password: secret
+//	  topic_prefix: knocker/laptop  # default knocker/<node_id>
+//	  events: [WhitelistExpired, Error]
+//	  tls:
+//	    ca_file: /etc/ssl/broker-ca.pem
+//	  discovery: true               # Home Assistant discovery, on by default
+type MQTT struct {
+	// Broker is the broker URL; publishing is disabled when it is empty.
+	Broker   string
+	ClientID string
+	Username string
+	Password string
+	// NodeID identifies this host in discovery topics and unique IDs. It
+	// defaults to the hostname, reduced to the characters Home Assistant
+	// accepts.
+	NodeID      string
+	TopicPrefix string
+	QoS         byte
+	// Events limits the published events to these types; empty means every
+	// event. The retained status is always published.
+	Events          []string
+	TLSCAFile       string
+	TLSCertFile     string
+	TLSKeyFile      string
+	TLSInsecure     bool
+	Discovery       bool
+	DiscoveryPrefix string
+}
+
+var invalidNodeIDChars = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// LoadMQTT decodes and validates the `mqtt` settings, applying defaults.
+func LoadMQTT(v *viper.Viper) (MQTT, error) {
+	m := MQTT{
+		Broker:          v.GetString("mqtt.broker"),
+		ClientID:        v.GetString("mqtt.client_id"),
+		Username:        v.GetString("mqtt.username"),
+		Password:        v.GetString("mqtt.password"),
+		NodeID:          v.GetString("mqtt.node_id"),
+		TopicPrefix:     strings.TrimSuffix(v.GetString("mqtt.topic_prefix"), "/"),
+		Events:          v.GetStringSlice("mqtt.events"),
+		TLSCAFile:       v.GetString("mqtt.tls.ca_file"),
+		TLSCertFile:     v.GetString("mqtt.tls.cert_file"),
+		TLSKeyFile:      v.GetString("mqtt.tls.key_file"),
+		TLSInsecure:     v.GetBool("mqtt.tls.insecure_skip_verify"),
+		Discovery:       !v.IsSet("mqtt.discovery") || v.GetBool("mqtt.discovery"),
+		DiscoveryPrefix: strings.TrimSuffix(v.GetString("mqtt.discovery_prefix"), "/"),
+	}
+	if m.Broker == "" {
+		return m, nil
+	}
+	if err := validateBrokerURL(m.Broker); err != nil {
+		return MQTT{}, err
+	}
+
+	qos := defaultMQTTQoS
+	if v.IsSet("mqtt.qos") {
+		qos = v.GetInt("mqtt.qos")
+	}
+	if qos < 0 || qos > 2 {
+		return MQTT{}, fmt.Errorf("mqtt.qos must be 0, 1 or 2, got %d", qos)
+	}
+	m.QoS = byte(qos)
+
+	if (m.TLSCertFile == "") != (m.TLSKeyFile == "") {
+		return MQTT{}, fmt.Errorf("mqtt.tls.cert_file and mqtt.tls.key_file must be set together")
+	}
+
+	if m.NodeID == "" {
+		host, _ := os.Hostname()
+		m.NodeID = host
+	}
+	m.NodeID = strings.Trim(invalidNodeIDChars.ReplaceAllString(m.NodeID, "_"), "_")
+	if m.NodeID == "" {
+		m.NodeID = "knocker"
+	}
+	if m.ClientID == "" {
+		m.ClientID = "knocker-" + m.NodeID
+	}
+	if m.TopicPrefix == "" {
+		m.TopicPrefix = "knocker/" + m.NodeID
+	}
+	if m.DiscoveryPrefix == "" {
+		m.DiscoveryPrefix = defaultMQTTDiscoveryPrefix
+	}
+	return m, nil
+}
+
+// validateBrokerURL accepts the schemes understood by the MQTT client:
+// tcp/mqtt for plain connections, ssl/tls/mqtts for TLS and ws/wss for
+// websockets.
+func validateBrokerURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid MQTT broker %q: %v", raw, err)
+	}
+	switch u.Scheme {
+	case "tcp", "mqtt", "ssl", "tls", "mqtts", "ws", "wss":
+	default:
+		return fmt.Errorf("invalid MQTT broker %q: scheme must be tcp, mqtt, ssl, tls, mqtts, ws or wss", raw)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("invalid MQTT broker %q: missing host", raw)
+	}
+	return nil
+}
+
+func checkMQTT(v *viper.Viper) []Issue {
+	if _, err := LoadMQTT(v); err != nil {
+		return []Issue{{Key: "mqtt", Severity: SeverityError, Message: err.Error()}}
+	}
+	return nil
+}
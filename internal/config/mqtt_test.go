@@ -0,0 +1,42 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadMQTTDefaults(t *testing.T) {
+	m, err := LoadMQTT(newViperFromYAML(t, "mqtt:\n  broker: tcp://broker.lan:1883\n  node_id: my.laptop\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "my_laptop", m.NodeID)
+	assert.Equal(t, "knocker-my_laptop", m.ClientID)
+	assert.Equal(t, "knocker/my_laptop", m.TopicPrefix)
+	assert.Equal(t, byte(1), m.QoS)
+	assert.True(t, m.Discovery)
+	assert.Equal(t, "homeassistant", m.DiscoveryPrefix)
+
+	m, err = LoadMQTT(newViperFromYAML(t, "ttl: 60\n"))
+	require.NoError(t, err)
+	assert.Empty(t, m.Broker)
+}
+
+func TestLoadMQTTRejectsInvalidSettings(t *testing.T) {
+	for name, content := range map[string]string{
+		"scheme":   "mqtt:\n  broker: https://broker.lan\n",
+		"qos":      "mqtt:\n  broker: tcp://broker.lan:1883\n  qos: 3\n",
+		"cert_key": "mqtt:\n  broker: mqtts://broker.lan:8883\n  tls:\n    cert_file: client.pem\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := LoadMQTT(newViperFromYAML(t, content))
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestValidateChecksMQTT(t *testing.T) {
+	issues := Validate(newViperFromYAML(t, "api_url: https://knock.example.com\napi_key: k\nmqtt:\n  broker: broker.lan\n"))
+	assert.True(t, HasErrors(issues))
+	assert.Equal(t, "mqtt", issues[0].Key)
+}
@@ -0,0 +1,48 @@
+package config
+
+import (
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	defaultExpiryWarning    = 10 * time.Minute
+	defaultFailureThreshold = 3
+	defaultNotifyInterval   = 15 * time.Minute
+)
+
+// Notifications configures the desktop notifications sent by the service:
+//
+//	notifications:
+//	  enabled: true
+//	  expiry_warning: 10m   # warn this long before the whitelist expires
+//	  failure_threshold: 3  # consecutive failed knocks before notifying
+//	  min_interval: 15m     # at most one notification of each kind per interval
+type Notifications struct {
+	Enabled          bool
+	ExpiryWarning    time.Duration
+	FailureThreshold int
+	MinInterval      time.Duration
+}
+
+// LoadNotifications decodes the `notifications` settings. Unset or zero
+// values fall back to the defaults.
+func LoadNotifications(v *viper.Viper) Notifications {
+	n := Notifications{
+		Enabled:          v.GetBool("notifications.enabled"),
+		ExpiryWarning:    v.GetDuration("notifications.expiry_warning"),
+		FailureThreshold: v.GetInt("notifications.failure_threshold"),
+		MinInterval:      v.GetDuration("notifications.min_interval"),
+	}
+	if n.ExpiryWarning <= 0 {
+		n.ExpiryWarning = defaultExpiryWarning
+	}
+	if n.FailureThreshold <= 0 {
+		n.FailureThreshold = defaultFailureThreshold
+	}
+	if n.MinInterval <= 0 {
+		n.MinInterval = defaultNotifyInterval
+	}
+	return n
+}
@@ -0,0 +1,18 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadNotifications(t *testing.T) {
+	n := LoadNotifications(newViperFromYAML(t, "notifications:\n  enabled: true\n  expiry_warning: 5m\n  failure_threshold: 2\n"))
+	assert.True(t, n.Enabled)
+	assert.Equal(t, 5*time.Minute, n.ExpiryWarning)
+	assert.Equal(t, 2, n.FailureThreshold)
+	assert.Equal(t, 15*time.Minute, n.MinInterval)
+
+	assert.False(t, LoadNotifications(newViperFromYAML(t, "ttl: 60\n")).Enabled)
+}
@@ -0,0 +1,74 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+)
+
+// Profile holds the connection settings used for a knock. Named profiles live
+// under the `profiles:` section of the config file:
+//
+//	profiles:
+//	  office:
+//	    api_url: https://knocker.office.example.com
+//	    api_key: office-key
+//	    ttl: 3600
+type Profile struct {
+	Name   string
+	APIURL string
+	APIKey string
+	TTL    int
+}
+
+// ProfileNames lists the configured profiles in alphabetical order.
+func ProfileNames(v *viper.Viper) []string {
+	profiles := v.GetStringMap("profiles")
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ResolveProfile returns the settings of the named profile, falling back to
+// the top-level api_url, api_key and ttl for anything the profile omits. An
+// empty name resolves the top-level settings.
+func ResolveProfile(v *viper.Viper, name string) (Profile, error) {
+	profile := Profile{
+		Name:   name,
+		APIURL: v.GetString("api_url"),
+		APIKey: v.GetString("api_key"),
+		TTL:    v.GetInt("ttl"),
+	}
+	if name == "" {
+		return profile, nil
+	}
+
+	prefix := "profiles." + name
+	if !v.IsSet(prefix) {
+		return Profile{}, fmt.Errorf("profile %q is not defined", name)
+	}
+
+	if v.IsSet(prefix + ".api_url") {
+		profile.APIURL = v.GetString(prefix + ".api_url")
+		if err := ValidateURL(profile.APIURL); err != nil {
+			return Profile{}, fmt.Errorf("profile %q: %w", name, err)
+		}
+	}
+	if v.IsSet(prefix + ".api_key") {
+		profile.APIKey = v.GetString(prefix + ".api_key")
+	}
+	if v.IsSet(prefix + ".ttl") {
+		ttl, err := cast.ToIntE(v.Get(prefix + ".ttl"))
+		if err != nil || ttl < 0 {
+			return Profile{}, fmt.Errorf("profile %q: ttl must be a non-negative integer", name)
+		}
+		profile.TTL = ttl
+	}
+
+	return profile, nil
+}
@@ -0,0 +1,51 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const profilesYAML = `api_url: https://knocker.example.com
+api_key: default-key
+ttl: 600
+profiles:
+  office:
+    api_url: https://knocker.office.example.com
+    api_key: office-key
+  ci:
+    ttl: 60
+  broken:
+    api_url: not-a-url
+`
+
+func TestResolveProfileFallsBackToTopLevel(t *testing.T) {
+	v := newViperFromYAML(t, profilesYAML)
+
+	office, err := ResolveProfile(v, "office")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://knocker.office.example.com", office.APIURL)
+	assert.Equal(t, "office-key", office.APIKey)
+	assert.Equal(t, 600, office.TTL)
+
+	ci, err := ResolveProfile(v, "ci")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://knocker.example.com", ci.APIURL)
+	assert.Equal(t, "default-key", ci.APIKey)
+	assert.Equal(t, 60, ci.TTL)
+
+	assert.Equal(t, []string{"broken", "ci", "office"}, ProfileNames(v))
+}
+
+func TestResolveProfileRejectsUnknownAndInvalidProfiles(t *testing.T) {
+	v := newViperFromYAML(t, profilesYAML)
+
+	_, err := ResolveProfile(v, "missing")
+	assert.Error(t, err)
+
+	_, err = ResolveProfile(v, "broken")
+	assert.Error(t, err)
+
+	issue := issueFor(Validate(v), "profiles.broken")
+	assert.NotNil(t, issue)
+}
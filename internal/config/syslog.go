@@ -0,0 +1,97 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// Syslog network names. SyslogLocal selects the local syslog socket
+// (/dev/log and its BSD/macOS equivalents).
+const (
+	SyslogLocal = ""
+	// SyslogUnix is a socket path, used as a datagram socket or, when it
+	// does not accept datagrams, as a stream socket.
+	SyslogUnix = "unix"
+	SyslogUDP  = "udp"
+	SyslogTCP  = "tcp"
+	SyslogTLS  = "tls"
+)
+
+// facilities maps syslog facility names to their codes.
+var facilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5,
+	"lpr": 6, "news": 7, "uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// Syslog configures the RFC 5424 syslog event sink:
+//
+//	events:
+//	  syslog:
+//	    address: tls://logs.example.com:6514  # or local, unix:///dev/log, udp://host:514, tcp://host:601
+//	    facility: daemon
+//	    ca_file: /etc/ssl/logs-ca.pem          # for tls://
+type Syslog struct {
+	// Network is one of the Syslog* network names.
+	Network string
+	// Address is host:port for udp, tcp and tls, or a socket path for
+	// unix. It is empty for the local socket.
+	Address  string
+	Facility int
+	CAFile   string
+}
+
+// LoadSyslog decodes and validates the `events.syslog` settings. It returns
+// false when no address is set.
+func LoadSyslog(v *viper.Viper) (Syslog, bool, error) {
+	raw := v.GetString("events.syslog.address")
+	if raw == "" {
+		return Syslog{}, false, nil
+	}
+
+	s := Syslog{CAFile: v.GetString("events.syslog.ca_file")}
+	if raw != "local" {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return Syslog{}, false, fmt.Errorf("invalid syslog address %q: %v", raw, err)
+		}
+		switch u.Scheme {
+		case "unix":
+			if u.Path == "" {
+				return Syslog{}, false, fmt.Errorf("invalid syslog address %q: missing socket path", raw)
+			}
+			s.Network, s.Address = SyslogUnix, u.Path
+		case SyslogUDP, SyslogTCP, SyslogTLS:
+			if _, _, err := net.SplitHostPort(u.Host); err != nil {
+				return Syslog{}, false, fmt.Errorf("invalid syslog address %q: must include host and port", raw)
+			}
+			s.Network, s.Address = u.Scheme, u.Host
+		default:
+			return Syslog{}, false, fmt.Errorf("invalid syslog address %q: must be local, unix://, udp://, tcp:// or tls://", raw)
+		}
+	}
+
+	name := strings.ToLower(v.GetString("events.syslog.facility"))
+	if name == "" {
+		name = "daemon"
+	}
+	facility, ok := facilities[name]
+	if !ok {
+		return Syslog{}, false, fmt.Errorf("unknown syslog facility %q", name)
+	}
+	s.Facility = facility
+
+	return s, true, nil
+}
+
+func checkSyslog(v *viper.Viper) []Issue {
+	if _, _, err := LoadSyslog(v); err != nil {
+		return []Issue{{Key: "events.syslog", Severity: SeverityError, Message: err.Error()}}
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadSyslog(t *testing.T) {
+	for content, want := range map[string]Syslog{
+		"events:\n  syslog:\n    address: local\n":                                    {Network: SyslogLocal, Facility: 3},
+		"events:\n  syslog:\n    address: unix:///run/log.sock\n":                     {Network: SyslogUnix, Address: "/run/log.sock", Facility: 3},
+		"events:\n  syslog:\n    address: udp://10.0.0.5:514\n    facility: local3\n": {Network: SyslogUDP, Address: "10.0.0.5:514", Facility: 19},
+		"events:\n  syslog:\n    address: tls://logs:6514\n    ca_file: ca.pem\n":     {Network: SyslogTLS, Address: "logs:6514", Facility: 3, CAFile: "ca.pem"},
+	} {
+		s, ok, err := LoadSyslog(newViperFromYAML(t, content))
+		require.NoError(t, err, content)
+		assert.True(t, ok)
+		assert.Equal(t, want, s, content)
+	}
+
+	_, ok, err := LoadSyslog(newViperFromYAML(t, "ttl: 60\n"))
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestLoadSyslogRejectsInvalidSettings(t *testing.T) {
+	for _, content := range []string{
+		"events:\n  syslog:\n    address: https://logs:514\n",
+		"events:\n  syslog:\n    address: tcp://logs\n",
+		"events:\n  syslog:\n    address: local\n    facility: nope\n",
+	} {
+		_, _, err := LoadSyslog(newViperFromYAML(t, content))
+		assert.Error(t, err, content)
+	}
+}
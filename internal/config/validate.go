@@ -0,0 +1,228 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+)
+
+// Severity grades a validation issue.
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// Issue describes a single problem found in the effective configuration.
+type Issue struct {
+	Key      string   `json:"key,omitempty"`
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+}
+
+func (i Issue) String() string {
+	if i.Key == "" {
+		return fmt.Sprintf("%s: %s", i.Severity, i.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", i.Severity, i.Key, i.Message)
+}
+
+// HasErrors reports whether any issue is an error rather than a warning.
+func HasErrors(issues []Issue) bool {
+	for _, issue := range issues {
+		if issue.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks the effective configuration held by v: only known keys may
+// be present, values must have the expected type, URLs must be absolute
+// http(s) URLs and numeric settings must not be negative. Errors reading the
+// config file itself are reported separately by LoadError.
+func Validate(v *viper.Viper) []Issue {
+	var issues []Issue
+
+	unknown := []string{}
+	for _, name := range v.AllKeys() {
+		if _, ok := LookupKey(name); !ok && v.InConfig(name) {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	for _, name := range unknown {
+		issues = append(issues, Issue{Key: name, Severity: SeverityWarning, Message: "unknown key"})
+	}
+
+	for _, key := range Keys {
+		issues = append(issues, validateKey(v, key)...)
+	}
+
+	return issues
+}
+
+func validateKey(v *viper.Viper, key Key) []Issue {
+	value := v.Get(key.Name)
+	if value == nil || value == "" {
+		if key.Required {
+			return []Issue{{Key: key.Name, Severity: SeverityError, Message: fmt.Sprintf("is required (set it in the config file or %s)", EnvVar(key.Name))}}
+		}
+		return nil
+	}
+
+	typeError := []Issue{{Key: key.Name, Severity: SeverityError, Message: fmt.Sprintf("expected %s, got %q", key.Kind, formatValue(value))}}
+
+	switch key.Kind {
+	case KindInt:
+		n, err := cast.ToIntE(value)
+		if err != nil {
+			return typeError
+		}
+		if n < 0 {
+			return []Issue{{Key: key.Name, Severity: SeverityError, Message: fmt.Sprintf("must not be negative, got %d", n)}}
+		}
+	case KindURL:
+		s, err := cast.ToStringE(value)
+		if err != nil {
+			return typeError
+		}
+		if err := ValidateURL(s); err != nil {
+			return []Issue{{Key: key.Name, Severity: SeverityError, Message: err.Error()}}
+		}
+	case KindBool:
+		if _, err := cast.ToBoolE(value); err != nil {
+			return typeError
+		}
+	case KindMap:
+		if _, err := cast.ToStringMapE(value); err != nil {
+			return typeError
+		}
+	case KindList:
+		if _, err := cast.ToStringSliceE(value); err != nil {
+			return typeError
+		}
+	case KindObjectList:
+		if _, ok := value.([]interface{}); !ok {
+			return typeError
+		}
+	case KindDuration:
+		d, err := cast.ToDurationE(value)
+		if err != nil {
+			return typeError
+		}
+		if d < 0 {
+			return []Issue{{Key: key.Name, Severity: SeverityError, Message: fmt.Sprintf("must not be negative, got %v", d)}}
+		}
+	default:
+		if _, err := cast.ToStringE(value); err != nil {
+			return typeError
+		}
+	}
+
+	if key.Check != nil {
+		return key.Check(v)
+	}
+	return nil
+}
+
+func checkCheckInterval(v *viper.Viper) []Issue {
+	if v.GetInt("check_interval") == 0 {
+		return []Issue{{Key: "check_interval", Severity: SeverityWarning, Message: "is zero; the default interval will be used"}}
+	}
+	return nil
+}
+
+func checkExtraEntries(v *viper.Viper) []Issue {
+	var issues []Issue
+	for _, entry := range v.GetStringSlice("extra_entries") {
+		if err := ValidateEntry(entry); err != nil {
+			issues = append(issues, Issue{Key: "extra_entries", Severity: SeverityError, Message: err.Error()})
+		}
+	}
+	return issues
+}
+
+func checkProfiles(v *viper.Viper) []Issue {
+	var issues []Issue
+	for _, name := range ProfileNames(v) {
+		if _, err := ResolveProfile(v, name); err != nil {
+			issues = append(issues, Issue{Key: "profiles." + name, Severity: SeverityError, Message: err.Error()})
+		}
+	}
+	return issues
+}
+
+func checkHTTPListen(v *viper.Viper) []Issue {
+	if _, _, err := net.SplitHostPort(v.GetString("http.listen")); err != nil {
+		return []Issue{{Key: "http.listen", Severity: SeverityError, Message: fmt.Sprintf("must be host:port, such as 127.0.0.1:9464: %v", err)}}
+	}
+	return nil
+}
+
+func checkEventSchema(v *viper.Viper) []Issue {
+	switch v.GetString("events.schema") {
+	case "1", "2", "both":
+		return nil
+	}
+	return []Issue{{Key: "events.schema", Severity: SeverityError, Message: fmt.Sprintf("must be 1, 2 or both, got %q", v.GetString("events.schema"))}}
+}
+
+func checkLogLevel(v *viper.Viper) []Issue {
+	switch strings.ToLower(v.GetString("log.level")) {
+	case "debug", "info", "warn", "warning", "error":
+		return nil
+	}
+	return []Issue{{Key: "log.level", Severity: SeverityError, Message: fmt.Sprintf("must be debug, info, warn or error, got %q", v.GetString("log.level"))}}
+}
+
+func checkLogFormat(v *viper.Viper) []Issue {
+	switch v.GetString("log.format") {
+	case "text", "json":
+		return nil
+	}
+	return []Issue{{Key: "log.format", Severity: SeverityError, Message: fmt.Sprintf("must be text or json, got %q", v.GetString("log.format"))}}
+}
+
+// ValidateEntry checks that entry is an IP address or a CIDR range.
+func ValidateEntry(entry string) error {
+	if net.ParseIP(entry) != nil {
+		return nil
+	}
+	if _, _, err := net.ParseCIDR(entry); err == nil {
+		return nil
+	}
+	return fmt.Errorf("invalid entry %q: must be an IP address or CIDR range", entry)
+}
+
+// ValidateURL checks that raw is an absolute http or https URL.
+func ValidateURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid URL %q: %v", raw, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("invalid URL %q: scheme must be http or https", raw)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("invalid URL %q: missing host", raw)
+	}
+	return nil
+}
+
+// RedactURL returns the scheme and host of raw for logging. Webhook URLs
+// often carry an access token in the path or query (Slack, ntfy, Matrix),
+// so the rest is left out.
+func RedactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return "<invalid URL>"
+	}
+	return u.Scheme + "://" + u.Host
+}
@@ -0,0 +1,118 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"text/template"
+	"time"
+
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+)
+
+const (
+	defaultWebhookRetries = 3
+	defaultWebhookTimeout = 10 * time.Second
+)
+
+// Webhook configures an HTTP endpoint that receives structured events:
+//
+//	webhooks:
+//	  - url: https://ntfy.sh/my-knocker-alerts
+//	    events: [WhitelistExpired, Error]
+//	    template: "{{.Message}}"
+//	    content_type: text/plain
+//	    secret: shared-hmac-secret
+type Webhook struct {
+	URL string `mapstructure:"url"`
+	// Events limits delivery to these event types; empty means every event.
+	Events []string `mapstructure:"events"`
+	// Template is a text/template rendering the request body. When empty the
+	// event is sent as a JSON object with the KNOCKER_* fields.
+	Template    string            `mapstructure:"template"`
+	ContentType string            `mapstructure:"content_type"`
+	Headers     map[string]string `mapstructure:"headers"`
+	// Secret, when set, signs each body with HMAC-SHA256.
+	Secret string `mapstructure:"secret"`
+	// Retries is the number of retries after a failed delivery, 3 when not
+	// set; 0 disables them.
+	Retries int           `mapstructure:"retries"`
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+// Webhooks decodes and validates the `webhooks` list, applying defaults.
+// Each entry is checked on its own: an invalid one is left out and reported
+// in errs, with its 1-based position, so one mistake does not disable the
+// other webhooks.
+func Webhooks(v *viper.Viper) (webhooks []Webhook, errs []error) {
+	raw := v.Get("webhooks")
+	if raw == nil {
+		return nil, nil
+	}
+	entries, err := cast.ToSliceE(raw)
+	if err != nil {
+		return nil, []error{errors.New("invalid webhooks: must be a list")}
+	}
+
+	for i, entry := range entries {
+		webhook, err := decodeWebhook(entry)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("webhook %d: %w", i+1, err))
+			continue
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	return webhooks, errs
+}
+
+// decodeWebhook decodes and validates one `webhooks` entry.
+func decodeWebhook(entry interface{}) (Webhook, error) {
+	// A scratch viper decodes the entry with the same hooks, such as
+	// durations, as the rest of the config.
+	sub := viper.New()
+	sub.Set("webhook", entry)
+
+	var webhook Webhook
+	if err := sub.UnmarshalKey("webhook", &webhook); err != nil {
+		return Webhook{}, err
+	}
+	// Decoded again with a pointer to tell an explicit "retries: 0" from an
+	// unset value.
+	var explicit struct {
+		Retries *int `mapstructure:"retries"`
+	}
+	if err := sub.UnmarshalKey("webhook", &explicit); err != nil {
+		return Webhook{}, err
+	}
+
+	if err := ValidateURL(webhook.URL); err != nil {
+		return Webhook{}, err
+	}
+	if webhook.Template != "" {
+		if _, err := template.New("webhook").Parse(webhook.Template); err != nil {
+			return Webhook{}, fmt.Errorf("invalid template: %w", err)
+		}
+	}
+	if webhook.Retries < 0 {
+		return Webhook{}, errors.New("retries must not be negative")
+	}
+	if explicit.Retries == nil {
+		webhook.Retries = defaultWebhookRetries
+	}
+	if webhook.Timeout <= 0 {
+		webhook.Timeout = defaultWebhookTimeout
+	}
+	if webhook.ContentType == "" {
+		webhook.ContentType = "application/json"
+	}
+	return webhook, nil
+}
+
+func checkWebhooks(v *viper.Viper) []Issue {
+	_, errs := Webhooks(v)
+	var issues []Issue
+	for _, err := range errs {
+		issues = append(issues, Issue{Key: "webhooks", Severity: SeverityError, Message: err.Error()})
+	}
+	return issues
+}
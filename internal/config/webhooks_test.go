@@ -0,0 +1,84 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebhooksAppliesDefaults(t *testing.T) {
+	v := newViperFromYAML(t, `webhooks:
+  - url: https://ntfy.sh/knocker
+    events: [WhitelistExpired, Error]
+    secret: s3cret
+  - url: https://hooks.slack.com/services/T000/B000/XXX
+    template: '{"text": "{{.Message}}"}'
+    retries: 5
+    timeout: 2s
+`)
+
+	webhooks, errs := Webhooks(v)
+	assert.Empty(t, errs)
+	assert.Len(t, webhooks, 2)
+
+	assert.Equal(t, []string{"WhitelistExpired", "Error"}, webhooks[0].Events)
+	assert.Equal(t, "s3cret", webhooks[0].Secret)
+	assert.Equal(t, 3, webhooks[0].Retries)
+	assert.Equal(t, 10*time.Second, webhooks[0].Timeout)
+	assert.Equal(t, "application/json", webhooks[0].ContentType)
+
+	assert.Equal(t, 5, webhooks[1].Retries)
+	assert.Equal(t, 2*time.Second, webhooks[1].Timeout)
+}
+
+func TestWebhooksKeepsExplicitZeroRetries(t *testing.T) {
+	v := newViperFromYAML(t, "webhooks:\n  - url: https://ntfy.sh/knocker\n    retries: 0\n")
+
+	webhooks, errs := Webhooks(v)
+	assert.Empty(t, errs)
+	assert.Equal(t, 0, webhooks[0].Retries)
+}
+
+func TestWebhooksSkipsOnlyInvalidEntries(t *testing.T) {
+	v := newViperFromYAML(t, `webhooks:
+  - url: https://ntfy.sh/knocker
+  - url: https://hooks.slack.com/services/T000/B000/XXX
+    template: '{{.Message'
+  - url: ntfy
+  - url: https://example.com/hook
+    timeout: 5s
+`)
+
+	webhooks, errs := Webhooks(v)
+	assert.Len(t, webhooks, 2)
+	assert.Equal(t, "https://ntfy.sh/knocker", webhooks[0].URL)
+	assert.Equal(t, 5*time.Second, webhooks[1].Timeout)
+	if assert.Len(t, errs, 2) {
+		assert.Contains(t, errs[0].Error(), "webhook 2: invalid template")
+		assert.Contains(t, errs[1].Error(), "webhook 3:")
+	}
+
+	var issues int
+	for _, issue := range Validate(v) {
+		if issue.Key == "webhooks" {
+			issues++
+		}
+	}
+	assert.Equal(t, 2, issues, "every invalid entry is reported")
+}
+
+func TestValidateRejectsInvalidWebhooks(t *testing.T) {
+	v := newViperFromYAML(t, "api_url: https://knocker.example.com\napi_key: secret\nwebhooks:\n  - url: ntfy\n")
+	assert.NotNil(t, issueFor(Validate(v), "webhooks"))
+
+	v = newViperFromYAML(t, "api_url: https://knocker.example.com\napi_key: secret\nwebhooks:\n  - url: https://ntfy.sh/knocker\n    template: '{{.Message'\n")
+	assert.NotNil(t, issueFor(Validate(v), "webhooks"))
+}
+
+func TestDescribeDoesNotPrintWebhookSecrets(t *testing.T) {
+	v := newViperFromYAML(t, "webhooks:\n  - url: https://ntfy.sh/knocker\n    secret: s3cret\n")
+
+	setting := settingFor(Describe(v, nil), "webhooks")
+	assert.Equal(t, "1 configured", setting.Value)
+}
@@ -0,0 +1,111 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configFileMode keeps written configuration private to the user since it
+// contains the API key.
+const configFileMode = 0o600
+
+// DefaultConfigPath returns the config file InitConfig searches for when no
+// --config flag is given.
+func DefaultConfigPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".knocker.yaml"), nil
+}
+
+// DefaultEnvFilePath returns the environment file referenced by the systemd
+// user unit (EnvironmentFile=-%h/.config/knocker/env).
+func DefaultEnvFilePath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "knocker", "env"), nil
+}
+
+// DefaultStateDir returns the directory for Knocker's local state, such as
+// the knock history: $XDG_STATE_HOME/knocker, or ~/.local/state/knocker.
+func DefaultStateDir() (string, error) {
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		return filepath.Join(dir, "knocker"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "state", "knocker"), nil
+}
+
+// WriteFile writes values as a YAML config file readable only by the owner.
+func WriteFile(path string, values map[string]interface{}) error {
+	data, err := yaml.Marshal(values)
+	if err != nil {
+		return fmt.Errorf("could not encode config: %w", err)
+	}
+	return writePrivate(path, data)
+}
+
+// WriteEnvFile writes values as KEY=value lines using the KNOCKER_* variable
+// names understood by InitConfig, readable only by the owner.
+func WriteEnvFile(path string, values map[string]interface{}) error {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, key := range keys {
+		value := formatValue(values[key])
+		if strings.ContainsAny(value, "\n\r") {
+			return fmt.Errorf("value for %s must not contain newlines", key)
+		}
+		fmt.Fprintf(&b, "%s=%s\n", EnvVar(key), value)
+	}
+
+	return writePrivate(path, []byte(b.String()))
+}
+
+// writePrivate replaces path with data. The data goes to a private temporary
+// file in the same directory that is then renamed over path, so the API key
+// is never written into an existing file that others may be able to read.
+func writePrivate(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(configFileMode); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
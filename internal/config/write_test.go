@@ -0,0 +1,61 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteFileIsPrivateAndReadable(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".knocker.yaml")
+
+	err := WriteFile(path, map[string]interface{}{"api_url": "https://knocker.example.com", "api_key": "secret", "ttl": 600})
+	assert.NoError(t, err)
+
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	v := viper.New()
+	v.SetConfigFile(path)
+	assert.NoError(t, v.ReadInConfig())
+	assert.Equal(t, "secret", v.GetString("api_key"))
+	assert.Equal(t, 600, v.GetInt("ttl"))
+}
+
+func TestWriteEnvFileUsesKnockerVariables(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "knocker", "env")
+
+	err := WriteEnvFile(path, map[string]interface{}{"api_url": "https://knocker.example.com", "api_key": "secret"})
+	assert.NoError(t, err)
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "KNOCKER_API_KEY=secret\nKNOCKER_API_URL=https://knocker.example.com\n", string(data))
+
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+}
+
+func TestWriteFileReplacesReadableFileWithPrivateOne(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, ".knocker.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte("api_url: https://old.example.com\n"), 0o644))
+
+	assert.NoError(t, WriteFile(path, map[string]interface{}{"api_key": "secret"}))
+
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "api_key: secret\n", string(data))
+
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1, "the temporary file is renamed into place")
+}
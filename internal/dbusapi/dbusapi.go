@@ -0,0 +1,160 @@
+// Package dbusapi exposes the running service on the D-Bus session bus so
+// desktop integrations can read its state and control it without scraping
+// journald.
+package dbusapi
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	internalService "github.com/FarisZR/knocker-cli/internal/service"
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/godbus/dbus/v5/prop"
+)
+
+const (
+	// BusName is the well-known name the service owns on the session bus.
+	BusName = "io.knocker.Service"
+	// Interface is the D-Bus interface carrying the methods and properties.
+	Interface = "io.knocker.Service"
+	// ObjectPath is the path of the exported service object.
+	ObjectPath = dbus.ObjectPath("/io/knocker/Service")
+)
+
+// callTimeout bounds how long a method call waits for the service loop.
+const callTimeout = time.Minute
+
+// Controller is the part of the service driven over D-Bus.
+type Controller interface {
+	Status() internalService.Status
+	Knock(ctx context.Context) error
+	Pause(ctx context.Context) error
+	Resume(ctx context.Context) error
+}
+
+// Server is the exported io.knocker.Service object.
+type Server struct {
+	conn  *dbus.Conn
+	props *prop.Properties
+	// mu serialises Update, which the service may call from several
+	// goroutines.
+	mu sync.Mutex
+}
+
+// Export publishes ctrl on conn at ObjectPath and claims BusName. The
+// properties start from ctrl.Status(); call Update whenever it changes.
+func Export(conn *dbus.Conn, ctrl Controller) (*Server, error) {
+	if err := conn.Export(methods{ctrl: ctrl}, ObjectPath, Interface); err != nil {
+		return nil, err
+	}
+
+	props, err := prop.Export(conn, ObjectPath, prop.Map{Interface: propertySpec(ctrl.Status())})
+	if err != nil {
+		return nil, err
+	}
+
+	node := &introspect.Node{
+		Name: string(ObjectPath),
+		Interfaces: []introspect.Interface{
+			introspect.IntrospectData,
+			prop.IntrospectData,
+			{
+				Name:       Interface,
+				Methods:    introspect.Methods(methods{}),
+				Properties: props.Introspection(Interface),
+			},
+		},
+	}
+	if err := conn.Export(introspect.NewIntrospectable(node), ObjectPath, "org.freedesktop.DBus.Introspectable"); err != nil {
+		return nil, err
+	}
+
+	reply, err := conn.RequestName(BusName, dbus.NameFlagDoNotQueue)
+	if err != nil {
+		return nil, err
+	}
+	if reply != dbus.RequestNameReplyPrimaryOwner {
+		return nil, fmt.Errorf("bus name %s is already taken", BusName)
+	}
+
+	return &Server{conn: conn, props: props}, nil
+}
+
+// Update sets the properties from st, emitting PropertiesChanged for those
+// that changed.
+func (s *Server) Update(st internalService.Status) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for name, value := range propertyValues(st) {
+		if !reflect.DeepEqual(s.props.GetMust(Interface, name), value) {
+			s.props.SetMust(Interface, name, value)
+		}
+	}
+}
+
+// Close releases the bus name and stops serving the object.
+func (s *Server) Close() error {
+	_, err := s.conn.ReleaseName(BusName)
+	_ = s.conn.Export(nil, ObjectPath, Interface)
+	return err
+}
+
+func propertySpec(st internalService.Status) map[string]*prop.Prop {
+	spec := make(map[string]*prop.Prop)
+	for name, value := range propertyValues(st) {
+		spec[name] = &prop.Prop{Value: value, Emit: prop.EmitTrue}
+	}
+	return spec
+}
+
+// propertyValues maps a status onto the D-Bus properties. Absent times are
+// reported as 0 and an empty list means no extra entries are tracked.
+func propertyValues(st internalService.Status) map[string]interface{} {
+	ips := st.WhitelistIPs
+	if ips == nil {
+		ips = []string{}
+	}
+	return map[string]interface{}{
+		"State":         st.State,
+		"Whitelisted":   st.Whitelisted,
+		"WhitelistIP":   st.WhitelistIP,
+		"WhitelistIPs":  ips,
+		"ExpiresUnix":   st.ExpiresUnix,
+		"NextKnockUnix": st.NextKnockUnix,
+		"CadenceSource": st.CadenceSource,
+	}
+}
+
+// methods holds the D-Bus methods of the interface. godbus exports every
+// exported method of the type.
+type methods struct {
+	ctrl Controller
+}
+
+// Knock whitelists the host now, even when paused.
+func (m methods) Knock() *dbus.Error {
+	return m.call(m.ctrl.Knock)
+}
+
+// Pause stops scheduled knocks.
+func (m methods) Pause() *dbus.Error {
+	return m.call(m.ctrl.Pause)
+}
+
+// Resume restarts scheduled knocks, knocking immediately.
+func (m methods) Resume() *dbus.Error {
+	return m.call(m.ctrl.Resume)
+}
+
+func (m methods) call(fn func(context.Context) error) *dbus.Error {
+	ctx, cancel := context.WithTimeout(context.Background(), callTimeout)
+	defer cancel()
+	if err := fn(ctx); err != nil {
+		return dbus.MakeFailedError(err)
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package dbusapi
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/FarisZR/knocker-cli/internal/dbustest"
+	internalService "github.com/FarisZR/knocker-cli/internal/service"
+	"github.com/godbus/dbus/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeController struct {
+	mu     sync.Mutex
+	calls  []string
+	status internalService.Status
+	err    error
+}
+
+func (f *fakeController) Status() internalService.Status { return f.status }
+
+func (f *fakeController) record(name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, name)
+	return f.err
+}
+
+func (f *fakeController) setErr(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.err = err
+}
+
+func (f *fakeController) recorded() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.calls...)
+}
+
+func (f *fakeController) Knock(context.Context) error  { return f.record("Knock") }
+func (f *fakeController) Pause(context.Context) error  { return f.record("Pause") }
+func (f *fakeController) Resume(context.Context) error { return f.record("Resume") }
+
+func TestServerExposesStatusAndMethods(t *testing.T) {
+	address := dbustest.StartBus(t)
+	ctrl := &fakeController{status: internalService.Status{
+		State:       internalService.ServiceStateStarted,
+		Whitelisted: true,
+		WhitelistIP: "1.2.3.4",
+		ExpiresUnix: 1750202500,
+	}}
+	server, err := Export(dbustest.Connect(t, address), ctrl)
+	require.NoError(t, err)
+	defer server.Close()
+
+	client := dbustest.Connect(t, address)
+	obj := client.Object(BusName, ObjectPath)
+
+	ip, err := obj.GetProperty(Interface + ".WhitelistIP")
+	require.NoError(t, err)
+	assert.Equal(t, "1.2.3.4", ip.Value())
+	expires, err := obj.GetProperty(Interface + ".ExpiresUnix")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1750202500), expires.Value())
+
+	require.NoError(t, obj.Call(Interface+".Pause", 0).Err)
+	require.NoError(t, obj.Call(Interface+".Resume", 0).Err)
+	require.NoError(t, obj.Call(Interface+".Knock", 0).Err)
+	assert.Equal(t, []string{"Pause", "Resume", "Knock"}, ctrl.recorded())
+
+	ctrl.setErr(errors.New("service is not running"))
+	err = obj.Call(Interface+".Knock", 0).Err
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "service is not running")
+
+	var introspection string
+	require.NoError(t, obj.Call("org.freedesktop.DBus.Introspectable.Introspect", 0).Store(&introspection))
+	assert.Contains(t, introspection, `<method name="Knock">`)
+	assert.Contains(t, introspection, `<property name="NextKnockUnix" type="x" access="read">`)
+}
+
+func TestServerEmitsPropertiesChanged(t *testing.T) {
+	address := dbustest.StartBus(t)
+	server, err := Export(dbustest.Connect(t, address), &fakeController{status: internalService.Status{State: internalService.ServiceStateStarted}})
+	require.NoError(t, err)
+	defer server.Close()
+
+	client := dbustest.Connect(t, address)
+	require.NoError(t, client.AddMatchSignal(
+		dbus.WithMatchObjectPath(ObjectPath),
+		dbus.WithMatchInterface("org.freedesktop.DBus.Properties"),
+		dbus.WithMatchMember("PropertiesChanged"),
+	))
+	signals := make(chan *dbus.Signal, 16)
+	client.Signal(signals)
+
+	server.Update(internalService.Status{State: internalService.ServiceStatePaused})
+
+	changed := map[string]dbus.Variant{}
+	timeout := time.After(5 * time.Second)
+	for len(changed) == 0 {
+		select {
+		case signal := <-signals:
+			require.Equal(t, Interface, signal.Body[0])
+			for name, value := range signal.Body[1].(map[string]dbus.Variant) {
+				changed[name] = value
+			}
+		case <-timeout:
+			t.Fatal("no PropertiesChanged signal received")
+		}
+	}
+	assert.Equal(t, map[string]dbus.Variant{"State": dbus.MakeVariant(internalService.ServiceStatePaused)}, changed)
+
+	// Nothing changed, so no further signal is sent.
+	server.Update(internalService.Status{State: internalService.ServiceStatePaused})
+	select {
+	case signal := <-signals:
+		t.Fatalf("unexpected signal %v", signal.Body)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
@@ -0,0 +1,235 @@
+// Package doctor implements the individual diagnostics run by `knocker doctor`.
+package doctor
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+	"os/exec"
+	"os/user"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/FarisZR/knocker-cli/internal/api"
+	"github.com/FarisZR/knocker-cli/internal/journald"
+	"github.com/FarisZR/knocker-cli/internal/util"
+)
+
+// Status is the outcome of a single check.
+type Status string
+
+const (
+	StatusPass Status = "pass"
+	StatusWarn Status = "warn"
+	StatusFail Status = "fail"
+	StatusSkip Status = "skip"
+)
+
+// Result is the outcome of one diagnostic check.
+type Result struct {
+	Name   string `json:"name"`
+	Status Status `json:"status"`
+	Detail string `json:"detail"`
+}
+
+// Report collects the results of a doctor run.
+type Report struct {
+	Checks []Result `json:"checks"`
+}
+
+// Add appends a result to the report.
+func (r *Report) Add(result Result) {
+	r.Checks = append(r.Checks, result)
+}
+
+// Failed reports whether any check failed.
+func (r *Report) Failed() bool {
+	for _, check := range r.Checks {
+		if check.Status == StatusFail {
+			return true
+		}
+	}
+	return false
+}
+
+// Counts returns the number of checks per status.
+func (r *Report) Counts() map[Status]int {
+	counts := map[Status]int{}
+	for _, check := range r.Checks {
+		counts[check.Status]++
+	}
+	return counts
+}
+
+const (
+	dialTimeout = 10 * time.Second
+	// CertExpiryWarning is how close to expiry a server certificate must be
+	// before the TLS check warns.
+	CertExpiryWarning = 14 * 24 * time.Hour
+)
+
+func pass(name, format string, args ...interface{}) Result {
+	return Result{Name: name, Status: StatusPass, Detail: fmt.Sprintf(format, args...)}
+}
+
+func warn(name, format string, args ...interface{}) Result {
+	return Result{Name: name, Status: StatusWarn, Detail: fmt.Sprintf(format, args...)}
+}
+
+func fail(name, format string, args ...interface{}) Result {
+	return Result{Name: name, Status: StatusFail, Detail: fmt.Sprintf(format, args...)}
+}
+
+func skip(name, format string, args ...interface{}) Result {
+	return Result{Name: name, Status: StatusSkip, Detail: fmt.Sprintf(format, args...)}
+}
+
+// CheckDNS resolves the host of apiURL.
+func CheckDNS(apiURL string) Result {
+	const name = "dns"
+
+	u, err := url.Parse(apiURL)
+	if err != nil || u.Hostname() == "" {
+		return skip(name, "no valid API URL configured")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), dialTimeout)
+	defer cancel()
+
+	addrs, err := net.DefaultResolver.LookupHost(ctx, u.Hostname())
+	if err != nil {
+		return fail(name, "unable to resolve %s: %v", u.Hostname(), err)
+	}
+	return pass(name, "%s resolves to %s", u.Hostname(), strings.Join(addrs, ", "))
+}
+
+// CheckTLS performs a TLS handshake with the API host and inspects the
+// certificate expiry. tlsConfig may be nil to use the system roots.
+func CheckTLS(apiURL string, tlsConfig *tls.Config, now time.Time) Result {
+	const name = "tls"
+
+	u, err := url.Parse(apiURL)
+	if err != nil || u.Hostname() == "" {
+		return skip(name, "no valid API URL configured")
+	}
+	if u.Scheme != "https" {
+		return warn(name, "API URL uses %s; the API key is sent unencrypted", u.Scheme)
+	}
+
+	cfg := &tls.Config{}
+	if tlsConfig != nil {
+		cfg = tlsConfig.Clone()
+	}
+	if cfg.ServerName == "" {
+		cfg.ServerName = u.Hostname()
+	}
+
+	port := u.Port()
+	if port == "" {
+		port = "443"
+	}
+
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: dialTimeout}, "tcp", net.JoinHostPort(u.Hostname(), port), cfg)
+	if err != nil {
+		return fail(name, "TLS handshake with %s failed: %v", u.Host, err)
+	}
+	defer conn.Close()
+
+	certs := conn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return fail(name, "%s presented no certificate", u.Host)
+	}
+
+	expiry := certs[0].NotAfter
+	remaining := expiry.Sub(now)
+	if remaining <= 0 {
+		return fail(name, "certificate expired at %s", expiry.UTC().Format(time.RFC3339))
+	}
+	if remaining < CertExpiryWarning {
+		return warn(name, "certificate expires soon, at %s", expiry.UTC().Format(time.RFC3339))
+	}
+	return pass(name, "handshake ok, certificate valid until %s", expiry.UTC().Format(time.RFC3339))
+}
+
+// CheckHealth calls the API's /health endpoint.
+func CheckHealth(client *api.Client) Result {
+	const name = "health"
+
+	if err := client.HealthCheck(); err != nil {
+		return fail(name, "%v", err)
+	}
+	return pass(name, "%s/health returned 200", client.BaseURL)
+}
+
+// CheckKnock performs an authenticated knock, which refreshes the whitelist
+// for the current address exactly as the service would. The API offers no
+// authenticated request without that side effect, so `knocker doctor` only
+// runs this check with --knock.
+func CheckKnock(client *api.Client, ttl int) Result {
+	const name = "knock"
+
+	knockResponse, err := client.Knock("", ttl)
+	if err != nil {
+		return fail(name, "%v", err)
+	}
+	return pass(name, "whitelisted %s for %ds", knockResponse.WhitelistedEntry, knockResponse.ExpiresInSeconds)
+}
+
+// CheckIPLookup queries the configured IP checker, if any.
+func CheckIPLookup(getter util.IPGetter, ipCheckURL string) Result {
+	const name = "ip_check"
+
+	if ipCheckURL == "" {
+		return skip(name, "ip_check_url not configured (simple mode)")
+	}
+
+	ip, err := getter.GetPublicIP(ipCheckURL)
+	if err != nil {
+		return fail(name, "%v", err)
+	}
+	if net.ParseIP(ip) == nil {
+		return fail(name, "%s returned %q, which is not an IP address", ipCheckURL, ip)
+	}
+	return pass(name, "public IP is %s", ip)
+}
+
+// CheckJournald reports whether structured journald events can be written.
+func CheckJournald() Result {
+	const name = "journald"
+
+	if runtime.GOOS != "linux" {
+		return skip(name, "journald is only available on Linux")
+	}
+	if !journald.Enabled() {
+		return warn(name, "journald socket unavailable; structured events will not be recorded")
+	}
+	return pass(name, "structured events are written to journald")
+}
+
+// CheckLinger reports whether systemd keeps the user's services running after
+// logout, which the per-user knocker unit relies on.
+func CheckLinger() Result {
+	const name = "linger"
+
+	if runtime.GOOS != "linux" {
+		return skip(name, "linger only applies to systemd user services")
+	}
+
+	current, err := user.Current()
+	if err != nil {
+		return skip(name, "unable to determine current user: %v", err)
+	}
+
+	out, err := exec.Command("loginctl", "show-user", current.Username, "--property=Linger", "--value").Output()
+	if err != nil {
+		return skip(name, "unable to query loginctl: %v", err)
+	}
+
+	if strings.TrimSpace(string(out)) != "yes" {
+		return warn(name, "lingering is disabled; the service stops when %s logs out (enable with `loginctl enable-linger %s`)", current.Username, current.Username)
+	}
+	return pass(name, "lingering enabled for %s", current.Username)
+}
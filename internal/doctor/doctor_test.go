@@ -0,0 +1,98 @@
+package doctor
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/FarisZR/knocker-cli/internal/api"
+	"github.com/stretchr/testify/assert"
+)
+
+type stubIPGetter struct {
+	ip  string
+	err error
+}
+
+func (s stubIPGetter) GetPublicIP(url string) (string, error) {
+	return s.ip, s.err
+}
+
+func newAPIServer(t *testing.T, status int) *httptest.Server {
+	t.Helper()
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/health":
+			w.WriteHeader(status)
+		case "/knock":
+			w.WriteHeader(status)
+			json.NewEncoder(w).Encode(api.KnockResponse{WhitelistedEntry: "1.2.3.4", ExpiresInSeconds: 600})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestCheckTLSReportsCertificateExpiry(t *testing.T) {
+	server := newAPIServer(t, http.StatusOK)
+	tlsConfig := server.Client().Transport.(*http.Transport).TLSClientConfig
+
+	result := CheckTLS(server.URL, tlsConfig, time.Now())
+	assert.Equal(t, StatusPass, result.Status, result.Detail)
+
+	cert := server.Certificate()
+	result = CheckTLS(server.URL, tlsConfig, cert.NotAfter.Add(-time.Hour))
+	assert.Equal(t, StatusWarn, result.Status, result.Detail)
+}
+
+func TestCheckTLSWarnsOnPlainHTTP(t *testing.T) {
+	result := CheckTLS("http://knocker.example.com", nil, time.Now())
+	assert.Equal(t, StatusWarn, result.Status)
+}
+
+func TestCheckTLSFailsOnUntrustedCertificate(t *testing.T) {
+	server := newAPIServer(t, http.StatusOK)
+
+	result := CheckTLS(server.URL, &tls.Config{}, time.Now())
+	assert.Equal(t, StatusFail, result.Status)
+}
+
+func TestCheckHealthAndKnock(t *testing.T) {
+	server := newAPIServer(t, http.StatusOK)
+	client := api.NewClient(server.URL, "test-key")
+	client.HTTPClient = server.Client()
+
+	assert.Equal(t, StatusPass, CheckHealth(client).Status)
+	assert.Equal(t, StatusPass, CheckKnock(client, 0).Status)
+
+	failing := newAPIServer(t, http.StatusUnauthorized)
+	client = api.NewClient(failing.URL, "bad-key")
+	client.HTTPClient = failing.Client()
+
+	assert.Equal(t, StatusFail, CheckHealth(client).Status)
+	assert.Equal(t, StatusFail, CheckKnock(client, 0).Status)
+}
+
+func TestCheckIPLookup(t *testing.T) {
+	assert.Equal(t, StatusSkip, CheckIPLookup(stubIPGetter{}, "").Status)
+	assert.Equal(t, StatusPass, CheckIPLookup(stubIPGetter{ip: "8.8.8.8"}, "https://ifconfig.me").Status)
+	assert.Equal(t, StatusFail, CheckIPLookup(stubIPGetter{ip: "<html>"}, "https://ifconfig.me").Status)
+	assert.Equal(t, StatusFail, CheckIPLookup(stubIPGetter{err: errors.New("boom")}, "https://ifconfig.me").Status)
+}
+
+func TestReportFailed(t *testing.T) {
+	report := &Report{}
+	report.Add(Result{Name: "a", Status: StatusPass})
+	report.Add(Result{Name: "b", Status: StatusWarn})
+	assert.False(t, report.Failed())
+
+	report.Add(Result{Name: "c", Status: StatusFail})
+	assert.True(t, report.Failed())
+	assert.Equal(t, 1, report.Counts()[StatusFail])
+}
@@ -0,0 +1,185 @@
+package events
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/FarisZR/knocker-cli/internal/config"
+)
+
+// maxHookOutput caps how much of a hook's output is copied into the log.
+const maxHookOutput = 4096
+
+// hookQueueSize is how many hook commands may wait for a free slot. Commands
+// beyond it are dropped, so a slow hook or a burst of events cannot pile up
+// processes without limit.
+const hookQueueSize = 32
+
+// HookFailure describes a hook command that exited non-zero, timed out or
+// could not be started.
+type HookFailure struct {
+	Event   Event
+	Command string
+	Err     error
+	Output  string
+}
+
+// HookSink runs the user commands configured for an event type. Commands run
+// in the background, at most cfg.Concurrency at a time, each bounded by
+// cfg.Timeout; up to hookQueueSize more wait their turn and any further ones
+// are dropped. Close waits for running and queued hooks to finish.
+type HookSink struct {
+	cfg       config.Hooks
+	logger    *slog.Logger
+	onFailure func(HookFailure)
+	sem       chan struct{}
+	// queue holds a token for every running or waiting command.
+	queue chan struct{}
+	wg        sync.WaitGroup
+	closeMu   sync.Mutex
+	closed    bool
+}
+
+// NewHookSink returns a sink for cfg. Hook output is written to logger and
+// onFailure, when non-nil, is called for every failed command. onFailure must
+// not route back into this sink, or a failing Error hook would loop.
+func NewHookSink(cfg config.Hooks, logger *slog.Logger, onFailure func(HookFailure)) *HookSink {
+	concurrency := cfg.Concurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	return &HookSink{
+		cfg:       cfg,
+		logger:    logger,
+		onFailure: onFailure,
+		sem:       make(chan struct{}, concurrency),
+		queue:     make(chan struct{}, concurrency+hookQueueSize),
+	}
+}
+
+// Emit starts the commands configured for the event's type. It reports the
+// commands dropped because the queue is full.
+func (s *HookSink) Emit(event Event) error {
+	commands := s.cfg.Commands[strings.ToLower(event.Type)]
+	if len(commands) == 0 {
+		return nil
+	}
+
+	s.closeMu.Lock()
+	defer s.closeMu.Unlock()
+	if s.closed {
+		return errors.New("hook sink closed")
+	}
+
+	var dropped []string
+	for _, command := range commands {
+		select {
+		case s.queue <- struct{}{}:
+		default:
+			dropped = append(dropped, command)
+			continue
+		}
+		s.wg.Add(1)
+		go func(command string) {
+			defer s.wg.Done()
+			defer func() { <-s.queue }()
+			s.sem <- struct{}{}
+			defer func() { <-s.sem }()
+			s.run(event, command)
+		}(command)
+	}
+	if len(dropped) > 0 {
+		return fmt.Errorf("hook queue full, dropped %d %s hook(s): %s", len(dropped), event.Type, strings.Join(dropped, "; "))
+	}
+	return nil
+}
+
+// Close stops accepting events and waits for running hooks.
+func (s *HookSink) Close() error {
+	s.closeMu.Lock()
+	s.closed = true
+	s.closeMu.Unlock()
+
+	s.wg.Wait()
+	return nil
+}
+
+func (s *HookSink) run(event Event, command string) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.cfg.Timeout)
+	defer cancel()
+
+	cmd := shellCommand(ctx, command)
+	cmd.Env = append(os.Environ(), HookEnv(event)...)
+	// Don't wait forever on pipes held open by processes the hook left behind.
+	cmd.WaitDelay = time.Second
+
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	err := cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("timed out after %v", s.cfg.Timeout)
+	}
+
+	out := output.String()
+	if len(out) > maxHookOutput {
+		out = out[:maxHookOutput] + "... (truncated)"
+	}
+	s.logOutput(event.Type, command, out)
+
+	if err == nil {
+		return
+	}
+	if s.logger != nil {
+		s.logger.Warn("Hook failed", "event", event.Type, "hook_command", command, "error", err)
+	}
+	if s.onFailure != nil {
+		s.onFailure(HookFailure{Event: event, Command: command, Err: err, Output: out})
+	}
+}
+
+func (s *HookSink) logOutput(eventType, command, output string) {
+	if s.logger == nil {
+		return
+	}
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		if line := strings.TrimRight(scanner.Text(), "\r"); line != "" {
+			s.logger.Info("Hook output", "event", eventType, "hook_command", command, "output", line)
+		}
+	}
+}
+
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", command)
+	}
+	return exec.CommandContext(ctx, "/bin/sh", "-c", command)
+}
+
+// HookEnv returns the environment variables describing event: every KNOCKER_*
+// field plus KNOCKER_EVENT and KNOCKER_MESSAGE, sorted by name.
+func HookEnv(event Event) []string {
+	record := Record(event)
+	env := make([]string, 0, len(record)+1)
+	for name, value := range record {
+		if strings.HasPrefix(name, "KNOCKER_") {
+			env = append(env, name+"="+value)
+		}
+	}
+	env = append(env, "KNOCKER_MESSAGE="+event.Message)
+	sort.Strings(env)
+	return env
+}
@@ -0,0 +1,162 @@
+package events
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/FarisZR/knocker-cli/internal/config"
+	"github.com/FarisZR/knocker-cli/internal/journald"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func skipWithoutShell(t *testing.T) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("hook tests use /bin/sh")
+	}
+}
+
+func TestHookEnvIncludesKnockerFields(t *testing.T) {
+	event := New("WhitelistApplied", "Whitelisted 203.0.113.10", journald.PriInfo, journald.Fields{
+		"KNOCKER_WHITELIST_IP": "203.0.113.10",
+	})
+
+	env := HookEnv(event)
+
+	assert.Contains(t, env, "KNOCKER_EVENT=WhitelistApplied")
+	assert.Contains(t, env, "KNOCKER_WHITELIST_IP=203.0.113.10")
+	assert.Contains(t, env, "KNOCKER_MESSAGE=Whitelisted 203.0.113.10")
+	for _, entry := range env {
+		assert.True(t, strings.HasPrefix(entry, "KNOCKER_"), entry)
+	}
+}
+
+func TestHookSinkRunsCommandsForMatchingEvents(t *testing.T) {
+	skipWithoutShell(t)
+	out := filepath.Join(t.TempDir(), "hook.out")
+
+	var logs syncBuffer
+	sink := NewHookSink(config.Hooks{
+		Commands: map[string][]string{
+			"whitelistapplied": {`echo "$KNOCKER_WHITELIST_IP" > ` + out + `; echo done`},
+		},
+		Timeout:     5 * time.Second,
+		Concurrency: 1,
+	}, slog.New(slog.NewTextHandler(&logs, nil)), nil)
+
+	require.NoError(t, sink.Emit(New("ServiceState", "ignored", journald.PriInfo, nil)))
+	require.NoError(t, sink.Emit(New("WhitelistApplied", "applied", journald.PriInfo, journald.Fields{
+		"KNOCKER_WHITELIST_IP": "203.0.113.10",
+	})))
+	require.NoError(t, sink.Close())
+
+	data, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Equal(t, "203.0.113.10\n", string(data))
+	assert.Contains(t, logs.String(), "event=WhitelistApplied")
+	assert.Contains(t, logs.String(), "output=done")
+}
+
+func TestHookSinkReportsFailuresAndTimeouts(t *testing.T) {
+	skipWithoutShell(t)
+
+	var mu sync.Mutex
+	var failures []HookFailure
+	sink := NewHookSink(config.Hooks{
+		Commands: map[string][]string{
+			"error": {"echo broken >&2; exit 3", "sleep 5"},
+		},
+		Timeout:     200 * time.Millisecond,
+		Concurrency: 2,
+	}, nil, func(failure HookFailure) {
+		mu.Lock()
+		failures = append(failures, failure)
+		mu.Unlock()
+	})
+
+	require.NoError(t, sink.Emit(New("Error", "boom", journald.PriErr, nil)))
+	require.NoError(t, sink.Close())
+
+	require.Len(t, failures, 2)
+	byCommand := map[string]HookFailure{}
+	for _, failure := range failures {
+		byCommand[failure.Command] = failure
+	}
+	assert.Contains(t, byCommand["echo broken >&2; exit 3"].Output, "broken")
+	assert.Contains(t, byCommand["sleep 5"].Err.Error(), "timed out")
+}
+
+func TestHookSinkLimitsConcurrency(t *testing.T) {
+	skipWithoutShell(t)
+
+	sink := NewHookSink(config.Hooks{
+		Commands: map[string][]string{
+			"whitelistapplied": {"sleep 0.1", "sleep 0.1", "sleep 0.1"},
+		},
+		Timeout:     5 * time.Second,
+		Concurrency: 1,
+	}, nil, nil)
+
+	start := time.Now()
+	require.NoError(t, sink.Emit(New("WhitelistApplied", "applied", journald.PriInfo, nil)))
+	require.NoError(t, sink.Close())
+
+	// With one slot the three hooks run back to back.
+	assert.GreaterOrEqual(t, time.Since(start), 300*time.Millisecond)
+}
+
+func TestHookSinkDropsHooksWhenQueueIsFull(t *testing.T) {
+	skipWithoutShell(t)
+
+	sink := NewHookSink(config.Hooks{
+		Commands: map[string][]string{
+			"error": {"sleep 0.1", "sleep 0.1", "sleep 0.1", "echo late"},
+		},
+		Timeout:     5 * time.Second,
+		Concurrency: 1,
+	}, nil, nil)
+	// One running and one waiting.
+	sink.queue = make(chan struct{}, 2)
+
+	err := sink.Emit(New("Error", "boom", journald.PriErr, nil))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "dropped 2 Error hook(s)")
+	assert.Contains(t, err.Error(), "echo late")
+	require.NoError(t, sink.Close())
+	assert.Empty(t, sink.queue, "finished hooks free their queue slot")
+}
+
+func TestHookSinkRejectsEventsAfterClose(t *testing.T) {
+	sink := NewHookSink(config.Hooks{
+		Commands: map[string][]string{"error": {"true"}},
+		Timeout:  time.Second,
+	}, nil, nil)
+	require.NoError(t, sink.Close())
+
+	assert.Error(t, sink.Emit(New("Error", "boom", journald.PriErr, nil)))
+}
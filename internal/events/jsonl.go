@@ -0,0 +1,79 @@
+package events
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/FarisZR/knocker-cli/internal/journald"
+)
+
+// JSONLinesSink writes one JSON object per event. The objects mirror the
+// output of `journalctl -o json`: MESSAGE, PRIORITY, __REALTIME_TIMESTAMP
+// (microseconds since the epoch) and the KNOCKER_* fields, all as strings.
+type JSONLinesSink struct {
+	mu     sync.Mutex
+	w      io.Writer
+	closer io.Closer
+}
+
+// NewJSONLinesSink writes events to w.
+func NewJSONLinesSink(w io.Writer) *JSONLinesSink {
+	return &JSONLinesSink{w: w}
+}
+
+// OpenJSONLinesFile appends events to the file at path, creating it (and its
+// directory) when needed.
+func OpenJSONLinesFile(path string) (*JSONLinesSink, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	return &JSONLinesSink{w: f, closer: f}, nil
+}
+
+func (s *JSONLinesSink) Emit(event Event) error {
+	line, err := json.Marshal(Record(event))
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.w.Write(line)
+	return err
+}
+
+// Close closes the underlying file, if the sink owns one.
+func (s *JSONLinesSink) Close() error {
+	if s.closer == nil {
+		return nil
+	}
+	return s.closer.Close()
+}
+
+// Record flattens an event into the journald-style record written by the
+// JSON-lines sink.
+func Record(event Event) map[string]string {
+	record := journald.EntryFields(event.Type, event.Fields)
+	record["MESSAGE"] = event.Message
+	record["PRIORITY"] = strconv.Itoa(int(event.Priority))
+
+	ts := event.Time
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+	record["__REALTIME_TIMESTAMP"] = strconv.FormatInt(ts.UnixMicro(), 10)
+
+	return record
+}
@@ -0,0 +1,77 @@
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/FarisZR/knocker-cli/internal/journald"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSONLinesSinkWritesJournaldSchema(t *testing.T) {
+	var buf bytes.Buffer
+	sink := NewJSONLinesSink(&buf)
+
+	ts := time.Unix(1750202500, 0)
+	err := sink.Emit(Event{
+		Type:     "WhitelistApplied",
+		Message:  "Whitelisted 1.2.3.4",
+		Priority: journald.PriInfo,
+		Fields:   journald.Fields{"KNOCKER_WHITELIST_IP": "1.2.3.4"},
+		Time:     ts,
+	})
+	assert.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 1)
+
+	var record map[string]string
+	assert.NoError(t, json.Unmarshal([]byte(lines[0]), &record))
+	assert.Equal(t, "WhitelistApplied", record["KNOCKER_EVENT"])
+	assert.Equal(t, journald.SchemaVersion, record["KNOCKER_SCHEMA_VERSION"])
+	assert.Equal(t, "knocker", record["SYSLOG_IDENTIFIER"])
+	assert.Equal(t, "1.2.3.4", record["KNOCKER_WHITELIST_IP"])
+	assert.Equal(t, "Whitelisted 1.2.3.4", record["MESSAGE"])
+	assert.Equal(t, "6", record["PRIORITY"])
+	assert.Equal(t, "1750202500000000", record["__REALTIME_TIMESTAMP"])
+}
+
+func TestOpenJSONLinesFileAppends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events", "knocker.jsonl")
+
+	for i := 0; i < 2; i++ {
+		sink, err := OpenJSONLinesFile(path)
+		assert.NoError(t, err)
+		assert.NoError(t, sink.Emit(New("ServiceState", "Service state: started", journald.PriInfo, nil)))
+		assert.NoError(t, sink.Close())
+	}
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, strings.Count(string(data), "\n"))
+}
+
+type failingSink struct{ calls int }
+
+func (f *failingSink) Emit(Event) error {
+	f.calls++
+	return errors.New("unavailable")
+}
+
+func TestMultiSinkEmitsToEverySink(t *testing.T) {
+	var buf bytes.Buffer
+	failing := &failingSink{}
+	sink := MultiSink{failing, NewJSONLinesSink(&buf)}
+
+	err := sink.Emit(New("Error", "boom", journald.PriErr, nil))
+
+	assert.Error(t, err)
+	assert.Equal(t, 1, failing.calls)
+	assert.Contains(t, buf.String(), `"KNOCKER_EVENT":"Error"`)
+}
@@ -0,0 +1,167 @@
+package events
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/FarisZR/knocker-cli/internal/journald"
+)
+
+// maxRecordSize bounds a single JSON record when reading events back.
+const maxRecordSize = 1 << 20
+
+// Filter selects events read back from the journal or a JSON-lines file.
+type Filter struct {
+	// Types lists the event types to keep, compared case-insensitively.
+	// Empty keeps every type.
+	Types []string
+	// Since drops events older than this time when non-zero.
+	Since time.Time
+	// SchemaVersion keeps only events of this schema version when set, so
+	// the duplicates written with events.schema set to "both" are skipped.
+	// Events without KNOCKER_SCHEMA_VERSION count as version 1.
+	SchemaVersion string
+}
+
+// Match reports whether event passes the filter.
+func (f Filter) Match(event Event) bool {
+	if !f.Since.IsZero() && event.Time.Before(f.Since) {
+		return false
+	}
+	if f.SchemaVersion != "" {
+		version := event.Fields["KNOCKER_SCHEMA_VERSION"]
+		if version == "" {
+			version = SchemaV1
+		}
+		if version != f.SchemaVersion {
+			return false
+		}
+	}
+	if len(f.Types) == 0 {
+		return true
+	}
+	for _, eventType := range f.Types {
+		if strings.EqualFold(eventType, event.Type) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseRecord turns a journald-style record, as written by the JSON-lines
+// sink or printed by `journalctl -o json`, back into an Event. It reports
+// false for records that are not structured Knocker events, such as plain
+// log lines captured by journald.
+func ParseRecord(record map[string]string) (Event, bool) {
+	eventType := record["KNOCKER_EVENT"]
+	if eventType == "" {
+		return Event{}, false
+	}
+
+	event := Event{
+		Type:    eventType,
+		Message: record["MESSAGE"],
+		Fields:  journald.Fields{},
+	}
+	if priority, err := strconv.Atoi(record["PRIORITY"]); err == nil {
+		event.Priority = journald.Priority(priority)
+	}
+	if micros, err := strconv.ParseInt(record["__REALTIME_TIMESTAMP"], 10, 64); err == nil {
+		event.Time = time.UnixMicro(micros)
+	}
+	for name, value := range record {
+		if strings.HasPrefix(name, "KNOCKER_") {
+			event.Fields[name] = value
+		}
+	}
+	return event, true
+}
+
+// DecodeRecord parses one JSON record. journalctl encodes binary or repeated
+// fields as arrays; those are skipped since Knocker fields are always strings.
+func DecodeRecord(line []byte) (map[string]string, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(line, &raw); err != nil {
+		return nil, err
+	}
+
+	record := make(map[string]string, len(raw))
+	for name, value := range raw {
+		var s string
+		if err := json.Unmarshal(value, &s); err == nil {
+			record[name] = s
+		}
+	}
+	return record, nil
+}
+
+// ReadRecords calls fn for every Knocker event in r that passes filter.
+// Malformed lines are skipped. Reading stops at the first error from fn.
+func ReadRecords(r io.Reader, filter Filter, fn func(Event) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxRecordSize)
+	for scanner.Scan() {
+		if err := handleLine(scanner.Bytes(), filter, fn); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// FollowFile behaves like ReadRecords on the file at path, then keeps polling
+// for appended events until ctx is cancelled.
+func FollowFile(ctx context.Context, path string, filter Filter, poll time.Duration, fn func(Event) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	reader := bufio.NewReaderSize(f, 64*1024)
+	var partial []byte
+	for {
+		line, err := reader.ReadBytes('\n')
+		partial = append(partial, line...)
+
+		if err == nil {
+			if err := handleLine(partial, filter, fn); err != nil {
+				return err
+			}
+			partial = partial[:0]
+			continue
+		}
+		if !errors.Is(err, io.EOF) {
+			return err
+		}
+
+		// Wait for the writer to append more; keep any incomplete line.
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(poll):
+		}
+	}
+}
+
+func handleLine(line []byte, filter Filter, fn func(Event) error) error {
+	if len(strings.TrimSpace(string(line))) == 0 {
+		return nil
+	}
+
+	record, err := DecodeRecord(line)
+	if err != nil {
+		return nil
+	}
+	event, ok := ParseRecord(record)
+	if !ok || !filter.Match(event) {
+		return nil
+	}
+	return fn(event)
+}
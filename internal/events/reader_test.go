@@ -0,0 +1,91 @@
+package events
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/FarisZR/knocker-cli/internal/journald"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRecordRoundTripsRecord(t *testing.T) {
+	ts := time.UnixMicro(1750202500123456)
+	event := Event{
+		Type:     "WhitelistApplied",
+		Message:  "Whitelisted 1.2.3.4",
+		Priority: journald.PriInfo,
+		Fields:   journald.Fields{"KNOCKER_WHITELIST_IP": "1.2.3.4"},
+		Time:     ts,
+	}
+
+	parsed, ok := ParseRecord(Record(event))
+	require.True(t, ok)
+	assert.Equal(t, "WhitelistApplied", parsed.Type)
+	assert.Equal(t, "Whitelisted 1.2.3.4", parsed.Message)
+	assert.Equal(t, journald.PriInfo, parsed.Priority)
+	assert.True(t, ts.Equal(parsed.Time))
+	assert.Equal(t, "1.2.3.4", parsed.Fields["KNOCKER_WHITELIST_IP"])
+	assert.Equal(t, journald.SchemaVersion, parsed.Fields["KNOCKER_SCHEMA_VERSION"])
+	assert.NotContains(t, parsed.Fields, "SYSLOG_IDENTIFIER")
+}
+
+func TestReadRecordsFiltersAndSkipsNonEvents(t *testing.T) {
+	input := strings.Join([]string{
+		`{"MESSAGE":"knocker: Service running.","SYSLOG_IDENTIFIER":"knocker","__REALTIME_TIMESTAMP":"1750202400000000"}`,
+		`{"KNOCKER_EVENT":"KnockTriggered","MESSAGE":"Knock triggered via schedule: success","PRIORITY":"6","__REALTIME_TIMESTAMP":"1750202400000000"}`,
+		`not json`,
+		`{"KNOCKER_EVENT":"WhitelistApplied","MESSAGE":"Whitelisted 1.2.3.4","PRIORITY":"6","__REALTIME_TIMESTAMP":"1750202500000000","_BINARY":[1,2,3]}`,
+		`{"KNOCKER_EVENT":"WhitelistApplied","MESSAGE":"Whitelisted 5.6.7.8","PRIORITY":"6","__REALTIME_TIMESTAMP":"1750202300000000"}`,
+	}, "\n")
+
+	var got []string
+	err := ReadRecords(strings.NewReader(input), Filter{
+		Types: []string{"whitelistapplied"},
+		Since: time.Unix(1750202400, 0),
+	}, func(event Event) error {
+		got = append(got, event.Message)
+		return nil
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Whitelisted 1.2.3.4"}, got)
+}
+
+func TestFollowFileReadsAppendedEvents(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	sink, err := OpenJSONLinesFile(path)
+	require.NoError(t, err)
+	defer sink.Close()
+	require.NoError(t, sink.Emit(New("ServiceState", "Service state: started", journald.PriInfo, nil)))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var mu sync.Mutex
+	var got []string
+	done := make(chan error, 1)
+	go func() {
+		done <- FollowFile(ctx, path, Filter{}, 5*time.Millisecond, func(event Event) error {
+			mu.Lock()
+			defer mu.Unlock()
+			got = append(got, event.Type)
+			return nil
+		})
+	}()
+
+	require.NoError(t, sink.Emit(New("WhitelistApplied", "Whitelisted 1.2.3.4", journald.PriInfo, nil)))
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(got) == 2
+	}, time.Second, 5*time.Millisecond)
+
+	cancel()
+	require.NoError(t, <-done)
+	assert.Equal(t, []string{"ServiceState", "WhitelistApplied"}, got)
+}
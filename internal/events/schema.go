@@ -0,0 +1,105 @@
+package events
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/FarisZR/knocker-cli/internal/journald"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Schema versions an event can be emitted in. SchemaBoth emits every event
+// twice, once per version, while consumers migrate from v1 to v2.
+const (
+	SchemaV1   = "1"
+	SchemaV2   = "2"
+	SchemaBoth = "both"
+)
+
+// DefaultSchema is the schema emitted when events.schema is not set.
+const DefaultSchema = SchemaV1
+
+// V2Fields are the fields introduced by schema v2. They are removed from
+// events emitted as v1.
+var V2Fields = []string{
+	"KNOCKER_CYCLE_ID",
+	"KNOCKER_ATTEMPT",
+	"KNOCKER_LATENCY_MS",
+	"KNOCKER_PROFILE",
+	"KNOCKER_HTTP_STATUS",
+}
+
+// ValidateSchema checks that mode is one of the supported schema settings.
+func ValidateSchema(mode string) error {
+	switch mode {
+	case SchemaV1, SchemaV2, SchemaBoth:
+		return nil
+	}
+	return fmt.Errorf("unsupported event schema %q: must be %q, %q or %q", mode, SchemaV1, SchemaV2, SchemaBoth)
+}
+
+// AsSchema returns a copy of event stamped with the given schema version.
+// Version 1 copies drop the v2-only fields.
+func AsSchema(event Event, version string) Event {
+	fields := make(journald.Fields, len(event.Fields)+1)
+	for name, value := range event.Fields {
+		fields[name] = value
+	}
+	if version == SchemaV1 {
+		for _, name := range V2Fields {
+			delete(fields, name)
+		}
+	}
+	fields["KNOCKER_SCHEMA_VERSION"] = version
+	event.Fields = fields
+	return event
+}
+
+// SchemaSink emits events to Next in the schema versions selected by Mode.
+type SchemaSink struct {
+	Next EventSink
+	Mode string
+}
+
+// NewSchemaSink returns a sink emitting to next in the given mode. An empty
+// or unknown mode selects DefaultSchema.
+func NewSchemaSink(next EventSink, mode string) *SchemaSink {
+	if ValidateSchema(mode) != nil {
+		mode = DefaultSchema
+	}
+	return &SchemaSink{Next: next, Mode: mode}
+}
+
+func (s *SchemaSink) Emit(event Event) error {
+	if s.Mode != SchemaBoth {
+		return s.Next.Emit(AsSchema(event, s.Mode))
+	}
+	return errors.Join(
+		s.Next.Emit(AsSchema(event, SchemaV1)),
+		s.Next.Emit(AsSchema(event, SchemaV2)),
+	)
+}
+
+// Close closes Next when it holds resources.
+func (s *SchemaSink) Close() error {
+	if closer, ok := s.Next.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// NewCycleID returns an identifier for one knock cycle. When ctx carries a
+// valid span the trace ID is reused, so events and traces correlate;
+// otherwise a random ID of the same format is generated.
+func NewCycleID(ctx context.Context) string {
+	if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+		return sc.TraceID().String()
+	}
+	var id [16]byte
+	_, _ = rand.Read(id[:])
+	return hex.EncodeToString(id[:])
+}
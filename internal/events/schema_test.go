@@ -0,0 +1,77 @@
+package events
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/FarisZR/knocker-cli/internal/journald"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+type collectingSink struct{ events []Event }
+
+func (c *collectingSink) Emit(event Event) error {
+	c.events = append(c.events, event)
+	return nil
+}
+
+func v2Event() Event {
+	return New("KnockTriggered", "Knock triggered via cli: success", journald.PriInfo, journald.Fields{
+		"KNOCKER_RESULT":      "success",
+		"KNOCKER_CYCLE_ID":    "0123456789abcdef0123456789abcdef",
+		"KNOCKER_ATTEMPT":     "1",
+		"KNOCKER_LATENCY_MS":  "42",
+		"KNOCKER_HTTP_STATUS": "200",
+	})
+}
+
+func TestAsSchemaStripsV2FieldsFromV1(t *testing.T) {
+	event := v2Event()
+
+	v1 := AsSchema(event, SchemaV1)
+	assert.Equal(t, SchemaV1, v1.Fields["KNOCKER_SCHEMA_VERSION"])
+	assert.Equal(t, "success", v1.Fields["KNOCKER_RESULT"])
+	for _, name := range V2Fields {
+		assert.NotContains(t, v1.Fields, name)
+	}
+
+	v2 := AsSchema(event, SchemaV2)
+	assert.Equal(t, SchemaV2, v2.Fields["KNOCKER_SCHEMA_VERSION"])
+	assert.Equal(t, "42", v2.Fields["KNOCKER_LATENCY_MS"])
+
+	// The original event is left untouched.
+	assert.NotContains(t, event.Fields, "KNOCKER_SCHEMA_VERSION")
+}
+
+func TestSchemaSinkEmitsSelectedVersions(t *testing.T) {
+	both := &collectingSink{}
+	require.NoError(t, NewSchemaSink(both, SchemaBoth).Emit(v2Event()))
+	require.Len(t, both.events, 2)
+	assert.Equal(t, SchemaV1, both.events[0].Fields["KNOCKER_SCHEMA_VERSION"])
+	assert.Equal(t, SchemaV2, both.events[1].Fields["KNOCKER_SCHEMA_VERSION"])
+
+	fallback := &collectingSink{}
+	require.NoError(t, NewSchemaSink(fallback, "3").Emit(v2Event()))
+	require.Len(t, fallback.events, 1)
+	assert.Equal(t, DefaultSchema, fallback.events[0].Fields["KNOCKER_SCHEMA_VERSION"])
+}
+
+func TestNewCycleIDReusesTraceID(t *testing.T) {
+	assert.Regexp(t, regexp.MustCompile(`^[0-9a-f]{32}$`), NewCycleID(context.Background()))
+	assert.NotEqual(t, NewCycleID(context.Background()), NewCycleID(context.Background()))
+
+	ctx, span := sdktrace.NewTracerProvider().Tracer("test").Start(context.Background(), "cycle")
+	defer span.End()
+	assert.Equal(t, span.SpanContext().TraceID().String(), NewCycleID(ctx))
+}
+
+func TestFilterMatchesSchemaVersion(t *testing.T) {
+	filter := Filter{SchemaVersion: SchemaV2}
+
+	assert.True(t, filter.Match(AsSchema(v2Event(), SchemaV2)))
+	assert.False(t, filter.Match(AsSchema(v2Event(), SchemaV1)))
+	assert.False(t, filter.Match(New("KnockTriggered", "legacy", journald.PriInfo, journald.Fields{})))
+}
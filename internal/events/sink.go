@@ -0,0 +1,74 @@
+// Package events routes Knocker's structured events to one or more sinks.
+// Every sink receives the same KNOCKER_* schema documented in
+// docs/logging.md, whether it ends up in journald, a JSON-lines stream or
+// elsewhere.
+package events
+
+import (
+	"errors"
+	"io"
+	"time"
+
+	"github.com/FarisZR/knocker-cli/internal/journald"
+)
+
+// Event is a single structured Knocker event.
+type Event struct {
+	Type     string
+	Message  string
+	Priority journald.Priority
+	Fields   journald.Fields
+	Time     time.Time
+}
+
+// EventSink receives structured events.
+type EventSink interface {
+	Emit(event Event) error
+}
+
+// JournaldSink forwards events to journald. It is a no-op where journald is
+// unavailable.
+type JournaldSink struct{}
+
+func (JournaldSink) Emit(event Event) error {
+	return journald.Emit(event.Type, event.Message, event.Priority, event.Fields)
+}
+
+// MultiSink fans events out to several sinks, attempting every sink even when
+// one fails.
+type MultiSink []EventSink
+
+func (m MultiSink) Emit(event Event) error {
+	var errs []error
+	for _, sink := range m {
+		if err := sink.Emit(event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close closes every sink that holds resources, such as open files or
+// pending webhook deliveries.
+func (m MultiSink) Close() error {
+	var errs []error
+	for _, sink := range m {
+		if closer, ok := sink.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// New builds an Event stamped with the current time.
+func New(eventType, message string, priority journald.Priority, fields journald.Fields) Event {
+	return Event{
+		Type:     eventType,
+		Message:  message,
+		Priority: priority,
+		Fields:   fields,
+		Time:     time.Now(),
+	}
+}
@@ -0,0 +1,358 @@
+package events
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/FarisZR/knocker-cli/internal/config"
+	"github.com/FarisZR/knocker-cli/internal/journald"
+)
+
+const (
+	// SyslogSDID is the RFC 5424 structured data ID carrying the KNOCKER_*
+	// fields. 32473 is the private enterprise number reserved for
+	// documentation, as Knocker has no number of its own.
+	SyslogSDID = "knocker@32473"
+
+	syslogAppName     = "knocker"
+	syslogDialTimeout = 10 * time.Second
+	// syslogRedialTimeout bounds the immediate redial after a failed write,
+	// which runs while Emit holds the sink.
+	syslogRedialTimeout     = time.Second
+	syslogReconnectMaxDelay = 30 * time.Second
+	syslogTimeFormat        = "2006-01-02T15:04:05.000000Z07:00"
+	// sdNameMaxLen is the longest parameter name RFC 5424 allows.
+	sdNameMaxLen = 32
+)
+
+// localSyslogPaths are the usual locations of the local syslog socket.
+var localSyslogPaths = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+// SyslogSink writes events as RFC 5424 messages. The event type is the
+// MSGID and the KNOCKER_* fields travel as structured data under SyslogSDID.
+// Datagram transports carry one message per packet, TCP and TLS use octet
+// counting framing (RFC 6587, RFC 5425) and a local stream socket gets one
+// message per line. A failed write is retried once on a fresh connection,
+// dialled with a short deadline. If that fails too the event is dropped and
+// the sink reconnects in the background, dropping events until it succeeds,
+// so an unreachable server never stalls the caller.
+type SyslogSink struct {
+	cfg       config.Syslog
+	hostname  string
+	pid       string
+	tlsConfig *tls.Config
+	stop      chan struct{}
+
+	mu           sync.Mutex
+	conn         net.Conn
+	octetCounted bool
+	lineFramed   bool
+	reconnecting bool
+	closed       bool
+}
+
+// syslogReconnectDelay is the wait before the first background reconnection
+// attempt; it doubles up to syslogReconnectMaxDelay.
+var syslogReconnectDelay = time.Second
+
+// errNoLocalSyslog is returned when none of localSyslogPaths accepts a
+// connection.
+var errNoLocalSyslog = errors.New("no local syslog socket found")
+
+// NewSyslogSink connects to the syslog server described by cfg. When the
+// server cannot be reached the sink is returned anyway and connects in the
+// background, so a syslog daemon that starts after Knocker is picked up;
+// onError, when non-nil, receives that failed attempt. Only errors retrying
+// cannot fix, such as an unreadable CA file or an untrusted certificate, are
+// returned.
+func NewSyslogSink(cfg config.Syslog, onError func(error)) (*SyslogSink, error) {
+	s := &SyslogSink{
+		cfg:      cfg,
+		hostname: "-",
+		pid:      strconv.Itoa(os.Getpid()),
+		stop:     make(chan struct{}),
+	}
+	if host, err := os.Hostname(); err == nil && host != "" {
+		s.hostname = host
+	}
+
+	if cfg.Network == config.SyslogTLS {
+		s.tlsConfig = &tls.Config{}
+		if cfg.CAFile != "" {
+			pem, err := os.ReadFile(cfg.CAFile)
+			if err != nil {
+				return nil, fmt.Errorf("reading syslog CA file: %w", err)
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("no certificates found in %s", cfg.CAFile)
+			}
+			s.tlsConfig.RootCAs = pool
+		}
+	}
+
+	conn, err := s.dial(syslogDialTimeout)
+	switch {
+	case err == nil:
+		s.use(conn)
+	case retryableDial(err):
+		if onError != nil {
+			onError(fmt.Errorf("%w; retrying in the background", err))
+		}
+		s.mu.Lock()
+		s.startReconnect()
+		s.mu.Unlock()
+	default:
+		return nil, err
+	}
+	return s, nil
+}
+
+// retryableDial reports whether a failed dial may succeed later, such as when
+// the server is not up yet.
+func retryableDial(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, errNoLocalSyslog)
+}
+
+func (s *SyslogSink) Emit(event Event) error {
+	msg := s.format(event)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return errors.New("syslog sink closed")
+	}
+	if s.conn == nil {
+		return fmt.Errorf("syslog: not connected, dropping %s event", event.Type)
+	}
+	if err := s.write(msg); err == nil {
+		return nil
+	}
+	s.conn.Close()
+	s.conn = nil
+
+	conn, err := s.dial(syslogRedialTimeout)
+	if err == nil {
+		s.use(conn)
+		if err = s.write(msg); err == nil {
+			return nil
+		}
+		s.conn.Close()
+		s.conn = nil
+	}
+	s.startReconnect()
+	return fmt.Errorf("syslog: dropping %s event: %w", event.Type, err)
+}
+
+// Close closes the connection to the syslog server and stops reconnecting.
+func (s *SyslogSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	close(s.stop)
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// startReconnect starts the background reconnection unless it is already
+// running. s.mu must be held.
+func (s *SyslogSink) startReconnect() {
+	if s.reconnecting {
+		return
+	}
+	s.reconnecting = true
+	go s.reconnect()
+}
+
+// reconnect dials the server with exponential back-off until it succeeds or
+// the sink is closed. The dial runs without holding s.mu.
+func (s *SyslogSink) reconnect() {
+	delay := syslogReconnectDelay
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-time.After(delay):
+		}
+
+		conn, err := s.dial(syslogDialTimeout)
+		s.mu.Lock()
+		switch {
+		case s.closed:
+			if conn != nil {
+				conn.Close()
+			}
+		case err == nil:
+			s.use(conn)
+		}
+		done := s.closed || err == nil
+		if done {
+			s.reconnecting = false
+		}
+		s.mu.Unlock()
+		if done {
+			return
+		}
+		delay = min(delay*2, syslogReconnectMaxDelay)
+	}
+}
+
+// dial connects to the configured server within timeout.
+func (s *SyslogSink) dial(timeout time.Duration) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: timeout}
+	switch s.cfg.Network {
+	case config.SyslogLocal:
+		return dialLocalSyslog(dialer)
+	case config.SyslogUnix:
+		return dialUnixSyslog(dialer, s.cfg.Address)
+	case config.SyslogTLS:
+		return tls.DialWithDialer(dialer, "tcp", s.cfg.Address, s.tlsConfig)
+	default:
+		return dialer.Dial(s.cfg.Network, s.cfg.Address)
+	}
+}
+
+// use makes conn the current connection. s.mu must be held, or s not yet
+// shared.
+func (s *SyslogSink) use(conn net.Conn) {
+	s.conn = conn
+	s.octetCounted = s.cfg.Network == config.SyslogTCP || s.cfg.Network == config.SyslogTLS
+	s.lineFramed = conn.RemoteAddr().Network() == "unix"
+}
+
+// dialLocalSyslog connects to the first local syslog socket accepting a
+// connection.
+func dialLocalSyslog(dialer *net.Dialer) (net.Conn, error) {
+	for _, path := range localSyslogPaths {
+		if conn, err := dialUnixSyslog(dialer, path); err == nil {
+			return conn, nil
+		}
+	}
+	return nil, errNoLocalSyslog
+}
+
+// dialUnixSyslog connects to the syslog socket at path, preferring datagrams
+// as syslog daemons do and falling back to a stream socket.
+func dialUnixSyslog(dialer *net.Dialer, path string) (net.Conn, error) {
+	conn, err := dialer.Dial("unixgram", path)
+	if err == nil {
+		return conn, nil
+	}
+	if conn, streamErr := dialer.Dial("unix", path); streamErr == nil {
+		return conn, nil
+	}
+	return nil, err
+}
+
+// write sends msg with the framing of the current transport. s.mu must be
+// held.
+func (s *SyslogSink) write(msg string) error {
+	switch {
+	case s.octetCounted:
+		msg = strconv.Itoa(len(msg)) + " " + msg
+	case s.lineFramed:
+		msg += "\n"
+	}
+	_, err := s.conn.Write([]byte(msg))
+	return err
+}
+
+// format renders event as an RFC 5424 message.
+func (s *SyslogSink) format(event Event) string {
+	ts := event.Time
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "<%d>1 %s %s %s %s %s ",
+		s.cfg.Facility*8+SyslogSeverity(event.Priority),
+		ts.Format(syslogTimeFormat),
+		syslogHeaderField(s.hostname, 255),
+		syslogAppName,
+		s.pid,
+		syslogHeaderField(event.Type, 32),
+	)
+	b.WriteString(syslogStructuredData(event))
+	if event.Message != "" {
+		b.WriteByte(' ')
+		b.WriteString(event.Message)
+	}
+	return b.String()
+}
+
+// SyslogSeverity maps a journald priority onto the syslog severity. Both use
+// the same 0 (emergency) to 7 (debug) scale; out of range values are clamped.
+func SyslogSeverity(priority journald.Priority) int {
+	return int(min(max(priority, journald.PriEmerg), journald.PriDebug))
+}
+
+// syslogStructuredData renders the KNOCKER_* fields, together with
+// KNOCKER_EVENT and KNOCKER_SCHEMA_VERSION, as one SD-ELEMENT sorted by name.
+func syslogStructuredData(event Event) string {
+	fields := journald.EntryFields(event.Type, event.Fields)
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		if strings.HasPrefix(name, "KNOCKER_") {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return "-"
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString("[" + SyslogSDID)
+	for _, name := range names {
+		b.WriteString(" " + sdName(name) + `="` + sdValueEscaper.Replace(fields[name]) + `"`)
+	}
+	b.WriteString("]")
+	return b.String()
+}
+
+// sdValueEscaper escapes the characters RFC 5424 reserves in PARAM-VALUE.
+var sdValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+
+// sdName truncates name to the length RFC 5424 allows for an SD-NAME.
+func sdName(name string) string {
+	if len(name) > sdNameMaxLen {
+		return name[:sdNameMaxLen]
+	}
+	return name
+}
+
+// syslogHeaderField returns value reduced to the printable ASCII RFC 5424
+// allows in header fields, truncated to maxLen, or "-" when empty.
+func syslogHeaderField(value string, maxLen int) string {
+	cleaned := strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 {
+			return -1
+		}
+		return r
+	}, value)
+	if len(cleaned) > maxLen {
+		cleaned = cleaned[:maxLen]
+	}
+	if cleaned == "" {
+		return "-"
+	}
+	return cleaned
+}
@@ -0,0 +1,307 @@
+package events
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/pem"
+	"io"
+	"net"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/FarisZR/knocker-cli/internal/config"
+	"github.com/FarisZR/knocker-cli/internal/journald"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func syslogEvent() Event {
+	return Event{
+		Type:     "Error",
+		Message:  "Knock failed",
+		Priority: journald.PriErr,
+		Fields: journald.Fields{
+			"KNOCKER_ERROR_CODE": "knock_failed",
+			"KNOCKER_ERROR_MSG":  `bad "key" [401] \ denied`,
+		},
+		Time: time.Date(2025, 3, 1, 12, 0, 0, 123456000, time.UTC),
+	}
+}
+
+func TestSyslogSinkWritesRFC5424OverUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	sink, err := NewSyslogSink(config.Syslog{Network: config.SyslogUDP, Address: conn.LocalAddr().String(), Facility: 16}, nil)
+	require.NoError(t, err)
+	defer sink.Close()
+	require.NoError(t, sink.Emit(syslogEvent()))
+
+	buf := make([]byte, 4096)
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	n, _, err := conn.ReadFrom(buf)
+	require.NoError(t, err)
+	msg := string(buf[:n])
+
+	// local0 (16) * 8 + err (3) = 131
+	header := regexp.MustCompile(`^<131>1 2025-03-01T12:00:00\.123456Z \S+ knocker \d+ Error \[`)
+	assert.Regexp(t, header, msg)
+	assert.Contains(t, msg, `[knocker@32473 KNOCKER_ERROR_CODE="knock_failed" KNOCKER_ERROR_MSG="bad \"key\" [401\] \\ denied" KNOCKER_EVENT="Error" KNOCKER_SCHEMA_VERSION="1"]`)
+	assert.True(t, strings.HasSuffix(msg, "] Knock failed"))
+	assert.NotContains(t, msg, "SYSLOG_IDENTIFIER")
+}
+
+func TestSyslogSinkUnixDatagram(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log.sock")
+	conn, err := net.ListenPacket("unixgram", path)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	sink, err := NewSyslogSink(config.Syslog{Network: config.SyslogUnix, Address: path, Facility: 3}, nil)
+	require.NoError(t, err)
+	defer sink.Close()
+	require.NoError(t, sink.Emit(syslogEvent()))
+
+	buf := make([]byte, 4096)
+	n, _, err := conn.ReadFrom(buf)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(buf[:n]), "<27>1 "))
+}
+
+// readOctetCounted reads one RFC 6587 octet-counted frame.
+func readOctetCounted(r *bufio.Reader) (string, error) {
+	length, err := r.ReadString(' ')
+	if err != nil {
+		return "", err
+	}
+	n, err := strconv.Atoi(strings.TrimSuffix(length, " "))
+	if err != nil {
+		return "", err
+	}
+	msg := make([]byte, n)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return "", err
+	}
+	return string(msg), nil
+}
+
+func TestSyslogSinkTCPFramingAndReconnect(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	frames := make(chan string, 4)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				for {
+					msg, err := readOctetCounted(r)
+					if err != nil {
+						return
+					}
+					frames <- msg
+				}
+			}()
+		}
+	}()
+
+	sink, err := NewSyslogSink(config.Syslog{Network: config.SyslogTCP, Address: listener.Addr().String(), Facility: 3}, nil)
+	require.NoError(t, err)
+	defer sink.Close()
+
+	require.NoError(t, sink.Emit(syslogEvent()))
+	assert.Contains(t, <-frames, "Knock failed")
+
+	// A broken connection is replaced on the next event.
+	sink.mu.Lock()
+	sink.conn.Close()
+	sink.mu.Unlock()
+	require.NoError(t, sink.Emit(syslogEvent()))
+	assert.Contains(t, <-frames, "KNOCKER_ERROR_CODE=\"knock_failed\"")
+}
+
+func TestSyslogSinkUnixStreamFallback(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log.sock")
+	listener, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	defer listener.Close()
+	received := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		if line, err := bufio.NewReader(conn).ReadString('\n'); err == nil {
+			received <- line
+		}
+	}()
+
+	sink, err := NewSyslogSink(config.Syslog{Network: config.SyslogUnix, Address: path, Facility: 3}, nil)
+	require.NoError(t, err)
+	defer sink.Close()
+	require.NoError(t, sink.Emit(syslogEvent()))
+
+	select {
+	case line := <-received:
+		assert.True(t, strings.HasPrefix(line, "<27>1 "))
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received over the stream socket")
+	}
+}
+
+// shortSyslogReconnectDelay speeds up background reconnection for a test.
+func shortSyslogReconnectDelay(t *testing.T) {
+	t.Helper()
+	saved := syslogReconnectDelay
+	syslogReconnectDelay = 10 * time.Millisecond
+	t.Cleanup(func() { syslogReconnectDelay = saved })
+}
+
+// acceptOctetCounted accepts one connection on listener and sends the frames
+// read from it to frames.
+func acceptOctetCounted(listener net.Listener, frames chan<- string) {
+	conn, err := listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		msg, err := readOctetCounted(r)
+		if err != nil {
+			return
+		}
+		frames <- msg
+	}
+}
+
+func TestSyslogSinkDropsWhileServerDown(t *testing.T) {
+	shortSyslogReconnectDelay(t)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := listener.Addr().String()
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		if conn, err := listener.Accept(); err == nil {
+			accepted <- conn
+		}
+	}()
+
+	sink, err := NewSyslogSink(config.Syslog{Network: config.SyslogTCP, Address: addr, Facility: 3}, nil)
+	require.NoError(t, err)
+	defer sink.Close()
+
+	// Take the server down and break the connection: the event is dropped
+	// without waiting out the full dial timeout.
+	listener.Close()
+	(<-accepted).Close()
+	sink.mu.Lock()
+	sink.conn.Close()
+	sink.mu.Unlock()
+	start := time.Now()
+	assert.Error(t, sink.Emit(syslogEvent()))
+	assert.Less(t, time.Since(start), syslogDialTimeout)
+
+	// Once the server is back the sink reconnects in the background.
+	listener, err = net.Listen("tcp", addr)
+	require.NoError(t, err)
+	defer listener.Close()
+	frames := make(chan string, 4)
+	go acceptOctetCounted(listener, frames)
+	require.Eventually(t, func() bool {
+		return sink.Emit(syslogEvent()) == nil
+	}, 5*time.Second, 20*time.Millisecond)
+	assert.Contains(t, <-frames, "Knock failed")
+}
+
+func TestSyslogSinkConnectsWhenServerStartsLater(t *testing.T) {
+	shortSyslogReconnectDelay(t)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := listener.Addr().String()
+	listener.Close()
+
+	var reported error
+	sink, err := NewSyslogSink(config.Syslog{Network: config.SyslogTCP, Address: addr, Facility: 3}, func(err error) {
+		reported = err
+	})
+	require.NoError(t, err, "an unreachable server is retried, not fatal")
+	defer sink.Close()
+	assert.Error(t, reported)
+	assert.Error(t, sink.Emit(syslogEvent()), "events are dropped until connected")
+
+	listener, err = net.Listen("tcp", addr)
+	require.NoError(t, err)
+	defer listener.Close()
+	frames := make(chan string, 4)
+	go acceptOctetCounted(listener, frames)
+	require.Eventually(t, func() bool {
+		return sink.Emit(syslogEvent()) == nil
+	}, 5*time.Second, 20*time.Millisecond)
+	assert.Contains(t, <-frames, "Knock failed")
+}
+
+func TestSyslogSinkTLS(t *testing.T) {
+	// Borrow httptest's certificate for 127.0.0.1.
+	server := httptest.NewTLSServer(nil)
+	cert := server.TLS.Certificates[0]
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0o600))
+	server.Close()
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	require.NoError(t, err)
+	defer listener.Close()
+	received := make(chan string, 1)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				if msg, err := readOctetCounted(bufio.NewReader(conn)); err == nil {
+					received <- msg
+				}
+			}()
+		}
+	}()
+
+	cfg := config.Syslog{Network: config.SyslogTLS, Address: listener.Addr().String(), Facility: 3}
+	_, err = NewSyslogSink(cfg, nil)
+	assert.Error(t, err, "the test CA is not trusted by default")
+
+	cfg.CAFile = caFile
+	sink, err := NewSyslogSink(cfg, nil)
+	require.NoError(t, err)
+	defer sink.Close()
+	require.NoError(t, sink.Emit(syslogEvent()))
+
+	select {
+	case msg := <-received:
+		assert.True(t, strings.HasPrefix(msg, "<27>1 "))
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received over TLS")
+	}
+}
+
+func TestSyslogSeverity(t *testing.T) {
+	assert.Equal(t, 3, SyslogSeverity(journald.PriErr))
+	assert.Equal(t, 7, SyslogSeverity(journald.PriDebug))
+	assert.Equal(t, 0, SyslogSeverity(-1))
+	assert.Equal(t, 7, SyslogSeverity(12))
+}
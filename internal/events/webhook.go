@@ -0,0 +1,260 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/FarisZR/knocker-cli/internal/config"
+)
+
+const (
+	webhookQueueSize      = 64
+	defaultWebhookBackoff = time.Second
+	// webhookFlushTimeout bounds how long Close waits for queued deliveries,
+	// so a one-shot command does not sit out every retry of an endpoint
+	// that is down.
+	webhookFlushTimeout = 5 * time.Second
+
+	// SignatureHeader carries the HMAC-SHA256 of the request body, formatted
+	// as "sha256=<hex>", when the webhook has a secret.
+	SignatureHeader = "X-Knocker-Signature"
+	// EventHeader carries the event type of the delivered event.
+	EventHeader = "X-Knocker-Event"
+)
+
+// WebhookSink delivers events to an HTTP endpoint. Deliveries run on a
+// background goroutine so a slow endpoint never stalls the knock loop; Close
+// waits for queued events to be delivered, for up to webhookFlushTimeout.
+type WebhookSink struct {
+	cfg          config.Webhook
+	target       string
+	tmpl         *template.Template
+	client       *http.Client
+	filter       map[string]bool
+	backoff      time.Duration
+	flushTimeout time.Duration
+	onError      func(error)
+	queue        chan Event
+	done         chan struct{}
+	ctx          context.Context
+	cancel       context.CancelFunc
+	dropped      int
+	closeMu      sync.Mutex
+	closed       bool
+}
+
+// WebhookData is the value templates are executed with.
+type WebhookData struct {
+	Type     string
+	Message  string
+	Priority int
+	Time     time.Time
+	Fields   map[string]string
+	// JSON is the default JSON body, handy for embedding in a template.
+	JSON string
+}
+
+// NewWebhookSink starts a sink for cfg. onError, when non-nil, is called for
+// every delivery that still fails after all retries.
+func NewWebhookSink(cfg config.Webhook, onError func(error)) (*WebhookSink, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &WebhookSink{
+		cfg:          cfg,
+		target:       config.RedactURL(cfg.URL),
+		client:       &http.Client{Timeout: cfg.Timeout},
+		filter:       map[string]bool{},
+		backoff:      defaultWebhookBackoff,
+		flushTimeout: webhookFlushTimeout,
+		onError:      onError,
+		queue:        make(chan Event, webhookQueueSize),
+		done:         make(chan struct{}),
+		ctx:          ctx,
+		cancel:       cancel,
+	}
+
+	if cfg.Template != "" {
+		tmpl, err := template.New("webhook").Parse(cfg.Template)
+		if err != nil {
+			cancel()
+			return nil, fmt.Errorf("invalid webhook template: %w", err)
+		}
+		s.tmpl = tmpl
+	}
+	for _, eventType := range cfg.Events {
+		s.filter[strings.ToLower(eventType)] = true
+	}
+
+	go s.run()
+	return s, nil
+}
+
+// Emit queues the event for delivery if it passes the webhook's filter.
+func (s *WebhookSink) Emit(event Event) error {
+	if len(s.filter) > 0 && !s.filter[strings.ToLower(event.Type)] {
+		return nil
+	}
+
+	s.closeMu.Lock()
+	defer s.closeMu.Unlock()
+	if s.closed {
+		return errors.New("webhook sink closed")
+	}
+
+	select {
+	case s.queue <- event:
+		return nil
+	default:
+		return fmt.Errorf("webhook %s: queue full, dropping %s event", s.target, event.Type)
+	}
+}
+
+// Close stops accepting events and waits for the queued ones to be
+// delivered. Deliveries still pending after the flush timeout, including
+// retries, are abandoned and reported in the returned error.
+func (s *WebhookSink) Close() error {
+	s.closeMu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.queue)
+	}
+	s.closeMu.Unlock()
+
+	timer := time.NewTimer(s.flushTimeout)
+	defer timer.Stop()
+	select {
+	case <-s.done:
+	case <-timer.C:
+		s.cancel()
+		<-s.done
+	}
+	s.cancel()
+
+	if s.dropped > 0 {
+		return fmt.Errorf("webhook %s: gave up on %d undelivered event(s) after %s", s.target, s.dropped, s.flushTimeout)
+	}
+	return nil
+}
+
+func (s *WebhookSink) run() {
+	defer close(s.done)
+	for event := range s.queue {
+		if s.ctx.Err() != nil {
+			s.dropped++
+			continue
+		}
+		err := s.deliver(event)
+		if err != nil && s.ctx.Err() != nil {
+			s.dropped++
+			continue
+		}
+		if err != nil && s.onError != nil {
+			s.onError(err)
+		}
+	}
+}
+
+func (s *WebhookSink) deliver(event Event) error {
+	body, err := s.render(event)
+	if err != nil {
+		return fmt.Errorf("webhook %s: %w", s.target, err)
+	}
+
+	backoff := s.backoff
+	for attempt := 0; ; attempt++ {
+		retry, err := s.post(event, body)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= s.cfg.Retries {
+			return fmt.Errorf("webhook %s: delivering %s event failed after %d attempt(s): %w", s.target, event.Type, attempt+1, err)
+		}
+		select {
+		case <-time.After(backoff):
+		case <-s.ctx.Done():
+			return s.ctx.Err()
+		}
+		backoff *= 2
+	}
+}
+
+// post sends one delivery attempt and reports whether a failure is worth
+// retrying (network errors, 429 and 5xx responses).
+func (s *WebhookSink) post(event Event, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(s.ctx, http.MethodPost, s.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+
+	req.Header.Set("Content-Type", s.cfg.ContentType)
+	req.Header.Set("User-Agent", "knocker")
+	req.Header.Set(EventHeader, event.Type)
+	for name, value := range s.cfg.Headers {
+		req.Header.Set(name, value)
+	}
+	if s.cfg.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign(s.cfg.Secret, body))
+	}
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		// The client's error quotes the full URL, token included.
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			urlErr.URL = s.target
+		}
+		return true, err
+	}
+	res.Body.Close()
+
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return false, nil
+	}
+
+	retry := res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500
+	return retry, fmt.Errorf("unexpected status code: %d", res.StatusCode)
+}
+
+func (s *WebhookSink) render(event Event) ([]byte, error) {
+	record, err := json.Marshal(Record(event))
+	if err != nil {
+		return nil, err
+	}
+	if s.tmpl == nil {
+		return record, nil
+	}
+
+	data := WebhookData{
+		Type:     event.Type,
+		Message:  event.Message,
+		Priority: int(event.Priority),
+		Time:     event.Time,
+		Fields:   event.Fields,
+		JSON:     string(record),
+	}
+
+	var buf bytes.Buffer
+	if err := s.tmpl.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("rendering template: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// Sign returns the signature header value for body: "sha256=" followed by the
+// hex-encoded HMAC-SHA256 keyed with secret.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,152 @@
+package events
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/FarisZR/knocker-cli/internal/config"
+	"github.com/FarisZR/knocker-cli/internal/journald"
+	"github.com/stretchr/testify/assert"
+)
+
+type receivedRequest struct {
+	header http.Header
+	body   []byte
+}
+
+type webhookReceiver struct {
+	mu       sync.Mutex
+	requests []receivedRequest
+	statuses []int
+}
+
+func (r *webhookReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+
+	r.mu.Lock()
+	r.requests = append(r.requests, receivedRequest{header: req.Header.Clone(), body: body})
+	status := http.StatusOK
+	if len(r.statuses) > 0 {
+		status, r.statuses = r.statuses[0], r.statuses[1:]
+	}
+	r.mu.Unlock()
+
+	w.WriteHeader(status)
+}
+
+func newWebhookReceiver(t *testing.T, statuses ...int) (*webhookReceiver, *httptest.Server) {
+	t.Helper()
+	receiver := &webhookReceiver{statuses: statuses}
+	server := httptest.NewServer(receiver)
+	t.Cleanup(server.Close)
+	return receiver, server
+}
+
+func newTestWebhookSink(t *testing.T, cfg config.Webhook) (*WebhookSink, *[]error) {
+	t.Helper()
+	if cfg.Timeout == 0 {
+		cfg.Timeout = time.Second
+	}
+	if cfg.ContentType == "" {
+		cfg.ContentType = "application/json"
+	}
+
+	var failures []error
+	sink, err := NewWebhookSink(cfg, func(err error) { failures = append(failures, err) })
+	assert.NoError(t, err)
+	sink.backoff = time.Millisecond
+	return sink, &failures
+}
+
+func TestWebhookSinkSendsSignedJSON(t *testing.T) {
+	receiver, server := newWebhookReceiver(t)
+	sink, failures := newTestWebhookSink(t, config.Webhook{URL: server.URL, Secret: "s3cret"})
+
+	assert.NoError(t, sink.Emit(New("WhitelistApplied", "Whitelisted 1.2.3.4", journald.PriInfo, journald.Fields{"KNOCKER_WHITELIST_IP": "1.2.3.4"})))
+	assert.NoError(t, sink.Close())
+	assert.Empty(t, *failures)
+
+	assert.Len(t, receiver.requests, 1)
+	request := receiver.requests[0]
+	assert.Equal(t, "WhitelistApplied", request.header.Get(EventHeader))
+	assert.Equal(t, Sign("s3cret", request.body), request.header.Get(SignatureHeader))
+
+	var record map[string]string
+	assert.NoError(t, json.Unmarshal(request.body, &record))
+	assert.Equal(t, "1.2.3.4", record["KNOCKER_WHITELIST_IP"])
+	assert.Equal(t, "WhitelistApplied", record["KNOCKER_EVENT"])
+}
+
+func TestWebhookSinkFiltersEventsAndRendersTemplate(t *testing.T) {
+	receiver, server := newWebhookReceiver(t)
+	sink, _ := newTestWebhookSink(t, config.Webhook{
+		URL:         server.URL,
+		Events:      []string{"whitelistexpired"},
+		Template:    `{"text": "{{.Message}} ({{.Fields.KNOCKER_WHITELIST_IP}})"}`,
+		ContentType: "application/json",
+	})
+
+	assert.NoError(t, sink.Emit(New("WhitelistApplied", "Whitelisted 1.2.3.4", journald.PriInfo, nil)))
+	assert.NoError(t, sink.Emit(New("WhitelistExpired", "Whitelist expired", journald.PriNotice, journald.Fields{"KNOCKER_WHITELIST_IP": "1.2.3.4"})))
+	assert.NoError(t, sink.Close())
+
+	assert.Len(t, receiver.requests, 1)
+	assert.JSONEq(t, `{"text": "Whitelist expired (1.2.3.4)"}`, string(receiver.requests[0].body))
+	assert.Empty(t, receiver.requests[0].header.Get(SignatureHeader))
+}
+
+func TestWebhookSinkRetriesServerErrors(t *testing.T) {
+	receiver, server := newWebhookReceiver(t, http.StatusBadGateway, http.StatusTooManyRequests)
+	sink, failures := newTestWebhookSink(t, config.Webhook{URL: server.URL, Retries: 3})
+
+	assert.NoError(t, sink.Emit(New("Error", "Knock failed", journald.PriErr, nil)))
+	assert.NoError(t, sink.Close())
+
+	assert.Len(t, receiver.requests, 3)
+	assert.Empty(t, *failures)
+}
+
+func TestWebhookSinkGivesUpOnClientErrors(t *testing.T) {
+	receiver, server := newWebhookReceiver(t, http.StatusBadRequest)
+	sink, failures := newTestWebhookSink(t, config.Webhook{URL: server.URL, Retries: 3})
+
+	assert.NoError(t, sink.Emit(New("Error", "Knock failed", journald.PriErr, nil)))
+	assert.NoError(t, sink.Close())
+
+	assert.Len(t, receiver.requests, 1)
+	assert.Len(t, *failures, 1)
+}
+
+func TestWebhookSinkCloseGivesUpAfterFlushTimeout(t *testing.T) {
+	_, server := newWebhookReceiver(t)
+	server.Close()
+	sink, failures := newTestWebhookSink(t, config.Webhook{URL: server.URL + "/secret-token", Retries: 3})
+	sink.backoff = time.Hour
+	sink.flushTimeout = 50 * time.Millisecond
+
+	assert.NoError(t, sink.Emit(New("Error", "Knock failed", journald.PriErr, nil)))
+	start := time.Now()
+	err := sink.Close()
+	assert.Less(t, time.Since(start), time.Second)
+	assert.ErrorContains(t, err, "gave up on 1 undelivered event")
+	assert.NotContains(t, err.Error(), "secret-token")
+	assert.Empty(t, *failures)
+}
+
+func TestWebhookSinkErrorsDoNotLeakTheURL(t *testing.T) {
+	_, server := newWebhookReceiver(t)
+	server.Close()
+	sink, failures := newTestWebhookSink(t, config.Webhook{URL: server.URL + "/secret-token?auth=tk"})
+
+	assert.NoError(t, sink.Emit(New("Error", "Knock failed", journald.PriErr, nil)))
+	assert.NoError(t, sink.Close())
+
+	assert.Len(t, *failures, 1)
+	assert.NotContains(t, (*failures)[0].Error(), "secret-token")
+	assert.NotContains(t, (*failures)[0].Error(), "auth=tk")
+}
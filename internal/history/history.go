@@ -0,0 +1,199 @@
+// Package history keeps a bounded local record of knock attempts, so audits
+// do not depend on journald retention.
+package history
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultMaxRecords is how many attempts a store keeps when no limit is set.
+const DefaultMaxRecords = 1000
+
+// Record describes one knock attempt.
+type Record struct {
+	Time       time.Time `json:"time"`
+	Source     string    `json:"source"`
+	Result     string    `json:"result"`
+	IP         string    `json:"ip,omitempty"`
+	TTLSeconds int       `json:"ttl_sec,omitempty"`
+	LatencyMS  int64     `json:"latency_ms"`
+	ErrorCode  string    `json:"error_code,omitempty"`
+}
+
+// Store appends records to a JSON-lines file, compacting it to the newest
+// max records once it grows past twice that. A nil *Store records nothing.
+//
+// The service and CLI commands share the file, so every access holds an
+// exclusive lock on a ".lock" file next to it; the file itself cannot carry
+// the lock because compaction replaces it.
+type Store struct {
+	path  string
+	max   int
+	mu    sync.Mutex
+	lines int // -1 until counted
+	// seen is the file as of the last count, so appends by other processes
+	// are noticed and the count redone.
+	seen os.FileInfo
+}
+
+// Open returns a store backed by the file at path. The file and its
+// directory are created on the first Append.
+func Open(path string, max int) *Store {
+	if max <= 0 {
+		max = DefaultMaxRecords
+	}
+	return &Store{path: path, max: max, lines: -1}
+}
+
+// Path returns the file backing the store.
+func (s *Store) Path() string {
+	return s.path
+}
+
+// Append records an attempt.
+func (s *Store) Append(record Record) error {
+	if s == nil {
+		return nil
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return err
+	}
+	unlock, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if info, err := os.Stat(s.path); err != nil || s.seen == nil || !os.SameFile(info, s.seen) || info.Size() != s.seen.Size() {
+		records, err := s.load()
+		if err != nil {
+			return err
+		}
+		s.lines = len(records)
+	}
+
+	f, err := os.OpenFile(s.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(line); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	s.lines++
+
+	if s.lines > 2*s.max {
+		if err := s.compact(); err != nil {
+			return err
+		}
+	}
+	s.seen, _ = os.Stat(s.path)
+	return nil
+}
+
+// Load returns the stored records, oldest first.
+func (s *Store) Load() ([]Record, error) {
+	if s == nil {
+		return nil, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := os.Stat(filepath.Dir(s.path)); errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	unlock, err := s.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	return s.load()
+}
+
+// lock takes the cross-process lock guarding the file and returns the
+// function releasing it.
+func (s *Store) lock() (func(), error) {
+	f, err := os.OpenFile(s.path+".lock", os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFile(f); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return func() {
+		unlockFile(f)
+		f.Close()
+	}, nil
+}
+
+func (s *Store) load() ([]Record, error) {
+	f, err := os.Open(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []Record
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var record Record
+		// Skip lines torn by a crash mid-write.
+		if err := json.Unmarshal(scanner.Bytes(), &record); err == nil {
+			records = append(records, record)
+		}
+	}
+	sort.SliceStable(records, func(i, j int) bool { return records[i].Time.Before(records[j].Time) })
+	return records, scanner.Err()
+}
+
+// compact rewrites the file with the newest max records.
+func (s *Store) compact() error {
+	records, err := s.load()
+	if err != nil {
+		return err
+	}
+	if len(records) > s.max {
+		records = records[len(records)-s.max:]
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, record := range records {
+		if err := enc.Encode(record); err != nil {
+			return err
+		}
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0o600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return err
+	}
+	s.lines = len(records)
+	return nil
+}
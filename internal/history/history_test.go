@@ -0,0 +1,88 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoreAppendsAndLoads(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "history.jsonl")
+	store := Open(path, 10)
+
+	records, err := store.Load()
+	require.NoError(t, err)
+	assert.Empty(t, records)
+
+	base := time.Unix(1750000000, 0).UTC()
+	require.NoError(t, store.Append(Record{Time: base, Source: "schedule", Result: "success", IP: "1.2.3.4", TTLSeconds: 600, LatencyMS: 42}))
+	require.NoError(t, store.Append(Record{Time: base.Add(time.Minute), Source: "cli", Result: "failure", ErrorCode: "knock_failed"}))
+
+	records, err = Open(path, 10).Load()
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, "1.2.3.4", records[0].IP)
+	assert.Equal(t, int64(42), records[0].LatencyMS)
+	assert.Equal(t, "knock_failed", records[1].ErrorCode)
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+}
+
+func TestStoreStaysBounded(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	store := Open(path, 3)
+
+	base := time.Unix(1750000000, 0).UTC()
+	for i := 0; i < 10; i++ {
+		require.NoError(t, store.Append(Record{Time: base.Add(time.Duration(i) * time.Minute), Result: "success"}))
+	}
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.LessOrEqual(t, strings.Count(string(data), "\n"), 6)
+
+	records, err := store.Load()
+	require.NoError(t, err)
+	assert.Equal(t, base.Add(9*time.Minute), records[len(records)-1].Time)
+}
+
+func TestNilStoreIsNoOp(t *testing.T) {
+	var store *Store
+	assert.NoError(t, store.Append(Record{}))
+	records, err := store.Load()
+	assert.NoError(t, err)
+	assert.Empty(t, records)
+}
+
+func TestStoresSharingAFileStayBounded(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	service, cli := Open(path, 2), Open(path, 2)
+	lines := func() int {
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		return strings.Count(string(data), "\n")
+	}
+
+	base := time.Unix(1750000000, 0).UTC()
+	require.NoError(t, service.Append(Record{Time: base, Result: "success"}))
+	for i := 1; i <= 4; i++ {
+		require.NoError(t, cli.Append(Record{Time: base.Add(time.Duration(i) * time.Minute), Result: "success"}))
+	}
+	// The CLI compacted the file behind the service's back; the service
+	// notices instead of trusting its own count.
+	for i := 5; i <= 8; i++ {
+		require.NoError(t, service.Append(Record{Time: base.Add(time.Duration(i) * time.Minute), Result: "success"}))
+		assert.LessOrEqual(t, lines(), 4)
+	}
+
+	records, err := cli.Load()
+	require.NoError(t, err)
+	assert.Equal(t, base.Add(8*time.Minute), records[len(records)-1].Time)
+}
@@ -0,0 +1,143 @@
+package history
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+)
+
+const resultSuccess = "success"
+
+// Filter selects records for display.
+type Filter struct {
+	Since  time.Time
+	Source string
+	Result string
+	IP     string
+}
+
+// Apply returns the records matching f, preserving order.
+func (f Filter) Apply(records []Record) []Record {
+	var matched []Record
+	for _, record := range records {
+		if !f.Since.IsZero() && record.Time.Before(f.Since) {
+			continue
+		}
+		if f.Source != "" && !strings.EqualFold(f.Source, record.Source) {
+			continue
+		}
+		if f.Result != "" && !strings.EqualFold(f.Result, record.Result) {
+			continue
+		}
+		if f.IP != "" && f.IP != record.IP {
+			continue
+		}
+		matched = append(matched, record)
+	}
+	return matched
+}
+
+// Stats summarises a set of knock attempts.
+type Stats struct {
+	Attempts    int     `json:"attempts"`
+	Successes   int     `json:"successes"`
+	Failures    int     `json:"failures"`
+	SuccessRate float64 `json:"success_rate"`
+	// IPChanges counts successful knocks whitelisting a different IP than
+	// the previous successful knock.
+	IPChanges int `json:"ip_changes"`
+	// MeanTimeBetweenIPChanges is zero until at least one change is seen.
+	MeanTimeBetweenIPChanges time.Duration `json:"-"`
+	// TotalWhitelisted is the time covered by at least one successful
+	// knock's TTL, up to now.
+	TotalWhitelisted time.Duration `json:"-"`
+	First            time.Time     `json:"first"`
+	Last             time.Time     `json:"last"`
+}
+
+// MarshalJSON reports the durations in whole seconds.
+func (s Stats) MarshalJSON() ([]byte, error) {
+	type plain Stats
+	return json.Marshal(struct {
+		plain
+		MeanTimeBetweenIPChangesSec int64 `json:"mean_time_between_ip_changes_sec"`
+		TotalWhitelistedSec         int64 `json:"total_whitelisted_sec"`
+	}{
+		plain:                       plain(s),
+		MeanTimeBetweenIPChangesSec: int64(s.MeanTimeBetweenIPChanges / time.Second),
+		TotalWhitelistedSec:         int64(s.TotalWhitelisted / time.Second),
+	})
+}
+
+// Summarize computes statistics over records, which must be oldest first.
+func Summarize(records []Record, now time.Time) Stats {
+	var stats Stats
+	if len(records) == 0 {
+		return stats
+	}
+	stats.Attempts = len(records)
+	stats.First = records[0].Time
+	stats.Last = records[len(records)-1].Time
+
+	type interval struct{ start, end time.Time }
+	var covered []interval
+	var lastIP string
+	var changeTimes []time.Time
+
+	for _, record := range records {
+		if record.Result != resultSuccess {
+			stats.Failures++
+			continue
+		}
+		stats.Successes++
+
+		if record.IP != "" {
+			if lastIP == "" {
+				changeTimes = append(changeTimes, record.Time)
+			} else if record.IP != lastIP {
+				stats.IPChanges++
+				changeTimes = append(changeTimes, record.Time)
+			}
+			lastIP = record.IP
+		}
+
+		if record.TTLSeconds > 0 {
+			end := record.Time.Add(time.Duration(record.TTLSeconds) * time.Second)
+			if end.After(now) {
+				end = now
+			}
+			if end.After(record.Time) {
+				covered = append(covered, interval{record.Time, end})
+			}
+		}
+	}
+
+	stats.SuccessRate = float64(stats.Successes) / float64(stats.Attempts)
+	if len(changeTimes) > 1 {
+		span := changeTimes[len(changeTimes)-1].Sub(changeTimes[0])
+		stats.MeanTimeBetweenIPChanges = span / time.Duration(len(changeTimes)-1)
+	}
+
+	// Merge overlapping TTL windows so refreshes are not double counted.
+	sort.Slice(covered, func(i, j int) bool { return covered[i].start.Before(covered[j].start) })
+	var current *interval
+	for i := range covered {
+		next := covered[i]
+		if current != nil && !next.start.After(current.end) {
+			if next.end.After(current.end) {
+				current.end = next.end
+			}
+			continue
+		}
+		if current != nil {
+			stats.TotalWhitelisted += current.end.Sub(current.start)
+		}
+		current = &next
+	}
+	if current != nil {
+		stats.TotalWhitelisted += current.end.Sub(current.start)
+	}
+
+	return stats
+}
@@ -0,0 +1,51 @@
+package history
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSummarize(t *testing.T) {
+	base := time.Unix(1750000000, 0).UTC()
+	records := []Record{
+		{Time: base, Source: "schedule", Result: "success", IP: "1.2.3.4", TTLSeconds: 600},
+		// Refresh inside the first window: coverage must not be double counted.
+		{Time: base.Add(5 * time.Minute), Source: "schedule", Result: "success", IP: "1.2.3.4", TTLSeconds: 600},
+		{Time: base.Add(30 * time.Minute), Source: "schedule", Result: "failure", ErrorCode: "knock_failed"},
+		{Time: base.Add(60 * time.Minute), Source: "schedule", Result: "success", IP: "5.6.7.8", TTLSeconds: 600},
+		{Time: base.Add(120 * time.Minute), Source: "cli", Result: "success", IP: "9.9.9.9", TTLSeconds: 600},
+	}
+
+	stats := Summarize(records, base.Add(125*time.Minute))
+
+	assert.Equal(t, 5, stats.Attempts)
+	assert.Equal(t, 4, stats.Successes)
+	assert.Equal(t, 1, stats.Failures)
+	assert.InDelta(t, 0.8, stats.SuccessRate, 0.0001)
+	assert.Equal(t, 2, stats.IPChanges)
+	assert.Equal(t, 60*time.Minute, stats.MeanTimeBetweenIPChanges)
+	// 0-15m, 60-70m and 120-125m (clipped at now).
+	assert.Equal(t, 30*time.Minute, stats.TotalWhitelisted)
+
+	encoded, err := json.Marshal(stats)
+	assert.NoError(t, err)
+	assert.Contains(t, string(encoded), `"total_whitelisted_sec":1800`)
+	assert.Contains(t, string(encoded), `"mean_time_between_ip_changes_sec":3600`)
+}
+
+func TestFilterApply(t *testing.T) {
+	base := time.Unix(1750000000, 0).UTC()
+	records := []Record{
+		{Time: base, Source: "schedule", Result: "success", IP: "1.2.3.4"},
+		{Time: base.Add(time.Hour), Source: "cli", Result: "failure"},
+		{Time: base.Add(2 * time.Hour), Source: "cli", Result: "success", IP: "1.2.3.4"},
+	}
+
+	assert.Len(t, Filter{Source: "CLI"}.Apply(records), 2)
+	assert.Len(t, Filter{Result: "success", IP: "1.2.3.4"}.Apply(records), 2)
+	assert.Len(t, Filter{Since: base.Add(90 * time.Minute)}.Apply(records), 1)
+	assert.Empty(t, Summarize(nil, base).Attempts)
+}
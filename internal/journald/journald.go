@@ -55,6 +55,23 @@ func Emit(eventType, message string, priority Priority, fields Fields) error {
 		return fmt.Errorf("too many fields in journald entry: %d > %d", len(fields), math.MaxInt-3)
 	}
 
+	payload := EntryFields(eventType, fields)
+
+	if err := emit(message, priority, payload); err != nil {
+		if disableOn(err) {
+			journaldDisabled.Store(true)
+			return nil
+		}
+		return err
+	}
+
+	return nil
+}
+
+// EntryFields returns a copy of fields completed with the standard Knocker
+// entry fields: KNOCKER_EVENT, SYSLOG_IDENTIFIER and KNOCKER_SCHEMA_VERSION.
+// Every event sink uses it so the same schema is produced everywhere.
+func EntryFields(eventType string, fields Fields) Fields {
 	payload := make(Fields, len(fields)+3)
 	for k, v := range fields {
 		payload[k] = v
@@ -70,15 +87,7 @@ func Emit(eventType, message string, priority Priority, fields Fields) error {
 		payload["KNOCKER_SCHEMA_VERSION"] = SchemaVersion
 	}
 
-	if err := emit(message, priority, payload); err != nil {
-		if disableOn(err) {
-			journaldDisabled.Store(true)
-			return nil
-		}
-		return err
-	}
-
-	return nil
+	return payload
 }
 
 func disableOn(err error) bool {
@@ -0,0 +1,83 @@
+// Package logging builds the log/slog loggers used by the CLI and the
+// service, and maps the journald KNOCKER_* fields onto log attributes so the
+// runtime log and the structured events use the same vocabulary.
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"sort"
+	"strings"
+
+	"github.com/FarisZR/knocker-cli/internal/journald"
+)
+
+// Log formats accepted by --log-format.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// ParseLevel parses a --log-level value: debug, info, warn (or warning) or
+// error, case-insensitively.
+func ParseLevel(value string) (slog.Level, error) {
+	switch strings.ToLower(value) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return slog.LevelInfo, fmt.Errorf("unsupported log level %q: must be debug, info, warn or error", value)
+}
+
+// ValidateFormat checks that format is FormatText or FormatJSON.
+func ValidateFormat(format string) error {
+	switch format {
+	case FormatText, FormatJSON:
+		return nil
+	}
+	return fmt.Errorf("unsupported log format %q: must be %q or %q", format, FormatText, FormatJSON)
+}
+
+// New returns a logger writing records at level and above to w. format
+// selects slog's text or JSON handler; anything but FormatJSON is text.
+func New(w io.Writer, level slog.Level, format string) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: level}
+	if format == FormatJSON {
+		return slog.New(slog.NewJSONHandler(w, opts))
+	}
+	return slog.New(slog.NewTextHandler(w, opts))
+}
+
+// Discard returns a logger dropping every record.
+func Discard() *slog.Logger {
+	return slog.New(slog.DiscardHandler)
+}
+
+// AttrKey returns the log attribute name for a journald field: the field
+// name without the KNOCKER_ prefix, in lower case. KNOCKER_WHITELIST_IP
+// becomes whitelist_ip.
+func AttrKey(field string) string {
+	return strings.ToLower(strings.TrimPrefix(field, "KNOCKER_"))
+}
+
+// FieldAttrs converts journald fields into log attributes named by AttrKey,
+// sorted by name, ready to pass to a slog logging call.
+func FieldAttrs(fields journald.Fields) []any {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	attrs := make([]any, 0, len(names))
+	for _, name := range names {
+		attrs = append(attrs, slog.String(AttrKey(name), fields[name]))
+	}
+	return attrs
+}
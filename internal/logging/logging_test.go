@@ -0,0 +1,58 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/FarisZR/knocker-cli/internal/journald"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLevel(t *testing.T) {
+	for value, want := range map[string]slog.Level{
+		"debug":   slog.LevelDebug,
+		"INFO":    slog.LevelInfo,
+		"":        slog.LevelInfo,
+		"warning": slog.LevelWarn,
+		"error":   slog.LevelError,
+	} {
+		level, err := ParseLevel(value)
+		require.NoError(t, err, value)
+		assert.Equal(t, want, level, value)
+	}
+
+	_, err := ParseLevel("verbose")
+	assert.Error(t, err)
+}
+
+func TestValidateFormat(t *testing.T) {
+	assert.NoError(t, ValidateFormat(FormatText))
+	assert.NoError(t, ValidateFormat(FormatJSON))
+	assert.Error(t, ValidateFormat("logfmt"))
+}
+
+func TestNewJSONWithFieldAttrs(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, slog.LevelInfo, FormatJSON)
+	logger.Debug("hidden")
+	logger.Info("Knocked", FieldAttrs(journald.Fields{
+		"KNOCKER_WHITELIST_IP": "203.0.113.7",
+		"KNOCKER_TTL_SEC":      "600",
+	})...)
+
+	var record map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	assert.Equal(t, "INFO", record["level"])
+	assert.Equal(t, "Knocked", record["msg"])
+	assert.Equal(t, "203.0.113.7", record["whitelist_ip"])
+	assert.Equal(t, "600", record["ttl_sec"])
+}
+
+func TestNewText(t *testing.T) {
+	var buf bytes.Buffer
+	New(&buf, slog.LevelDebug, FormatText).Debug("Knocking", "trigger_source", "schedule")
+	assert.Contains(t, buf.String(), `level=DEBUG msg=Knocking trigger_source=schedule`)
+}
@@ -0,0 +1,213 @@
+package logging
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/FarisZR/knocker-cli/internal/config"
+)
+
+// backupTimeFormat names rotated files; it sorts chronologically and avoids
+// characters Windows does not allow in file names.
+const backupTimeFormat = "20060102T150405.000"
+
+// RotatingFile appends to a log file and rotates it once it would grow past
+// MaxSize or has been open longer than MaxAge. Rotated files are renamed to
+// <name>-<timestamp><ext>, gzipped in the background when Compress is set,
+// and only the newest MaxBackups are kept. A file left over from an earlier
+// run is as old as its last modification.
+type RotatingFile struct {
+	cfg config.LogFile
+	now func() time.Time
+
+	// backupsMu serializes compressing and pruning rotated files, which runs
+	// without holding mu.
+	backupsMu   sync.Mutex
+	compressing sync.WaitGroup
+
+	mu       sync.Mutex
+	file     *os.File
+	size     int64
+	openedAt time.Time
+}
+
+// OpenRotatingFile opens, or creates along with its directory, the log file
+// described by cfg.
+func OpenRotatingFile(cfg config.LogFile) (*RotatingFile, error) {
+	if err := os.MkdirAll(filepath.Dir(cfg.Path), 0o755); err != nil {
+		return nil, err
+	}
+	r := &RotatingFile{cfg: cfg, now: time.Now}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Path returns the path of the active log file.
+func (r *RotatingFile) Path() string {
+	return r.cfg.Path
+}
+
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return 0, os.ErrClosed
+	}
+
+	if r.size > 0 && (r.size+int64(len(p)) > r.cfg.MaxSize || r.now().Sub(r.openedAt) >= r.cfg.MaxAge) {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// Close closes the active log file and waits for rotated files still being
+// compressed. Later writes fail with os.ErrClosed.
+func (r *RotatingFile) Close() error {
+	r.mu.Lock()
+	var err error
+	if r.file != nil {
+		err = r.file.Close()
+		r.file = nil
+	}
+	r.mu.Unlock()
+	r.compressing.Wait()
+	return err
+}
+
+// open opens the active log file for appending. r.mu must be held, or r not
+// yet shared.
+func (r *RotatingFile) open() error {
+	file, err := os.OpenFile(r.cfg.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	r.file, r.size, r.openedAt = file, info.Size(), r.now()
+	if info.Size() > 0 && info.ModTime().Before(r.openedAt) {
+		r.openedAt = info.ModTime()
+	}
+	return nil
+}
+
+// rotate moves the active file aside and starts a new one. The rotated file
+// is compressed, and old files pruned after it, in the background so writers
+// are not held up. Failing to rename, compress or prune old files does not
+// stop logging; only failing to reopen the log file is reported. r.mu must be
+// held.
+func (r *RotatingFile) rotate() error {
+	r.file.Close()
+	r.file = nil
+
+	backup := r.backupName()
+	renamed := os.Rename(r.cfg.Path, backup) == nil
+	if err := r.open(); err != nil {
+		return err
+	}
+	if !renamed || !r.cfg.Compress {
+		r.backupsMu.Lock()
+		r.prune()
+		r.backupsMu.Unlock()
+		return nil
+	}
+	r.compressing.Add(1)
+	go func() {
+		defer r.compressing.Done()
+		r.backupsMu.Lock()
+		defer r.backupsMu.Unlock()
+		_ = compressFile(backup)
+		r.prune()
+	}()
+	return nil
+}
+
+// backupName returns an unused name for a rotated file, stamped with the
+// current time.
+func (r *RotatingFile) backupName() string {
+	stem, ext := splitExt(r.cfg.Path)
+	ts := r.now().UTC()
+	for {
+		name := stem + "-" + ts.Format(backupTimeFormat) + ext
+		if !exists(name) && !exists(name+".gz") {
+			return name
+		}
+		ts = ts.Add(time.Millisecond)
+	}
+}
+
+// Backups returns the rotated files belonging to the log file, oldest first.
+func (r *RotatingFile) Backups() []string {
+	stem, ext := splitExt(r.cfg.Path)
+	matches, _ := filepath.Glob(stem + "-*" + ext + "*")
+	backups := matches[:0]
+	for _, name := range matches {
+		stamp := strings.TrimSuffix(strings.TrimSuffix(name, ".gz"), ext)
+		stamp = strings.TrimPrefix(stamp, stem+"-")
+		if _, err := time.Parse(backupTimeFormat, stamp); err == nil {
+			backups = append(backups, name)
+		}
+	}
+	sort.Strings(backups)
+	return backups
+}
+
+// prune removes the oldest rotated files beyond MaxBackups. r.backupsMu must
+// be held.
+func (r *RotatingFile) prune() {
+	backups := r.Backups()
+	for len(backups) > r.cfg.MaxBackups {
+		_ = os.Remove(backups[0])
+		backups = backups[1:]
+	}
+}
+
+// compressFile gzips path into path.gz and removes the original.
+func compressFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(dst)
+	_, err = io.Copy(gz, src)
+	err = errors.Join(err, gz.Close(), dst.Close())
+	if err != nil {
+		os.Remove(path + ".gz")
+		return err
+	}
+	src.Close()
+	return os.Remove(path)
+}
+
+// splitExt splits path into everything before its extension and the
+// extension itself.
+func splitExt(path string) (string, string) {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext), ext
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,101 @@
+package logging
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/FarisZR/knocker-cli/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func openTestRotatingFile(t *testing.T, cfg config.LogFile, clock *time.Time) *RotatingFile {
+	t.Helper()
+	r, err := OpenRotatingFile(cfg)
+	require.NoError(t, err)
+	r.now = func() time.Time { return *clock }
+	r.openedAt = *clock
+	t.Cleanup(func() { r.Close() })
+	return r
+}
+
+func readGzip(t *testing.T, path string) string {
+	t.Helper()
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	require.NoError(t, err)
+	data, err := io.ReadAll(gz)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestRotatingFileRotatesBySizeAndKeepsBackups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "knocker.log")
+	clock := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	r := openTestRotatingFile(t, config.LogFile{Path: path, MaxSize: 20, MaxAge: time.Hour, MaxBackups: 2, Compress: true}, &clock)
+
+	for _, line := range []string{"first line\n", "second line\n", "third line\n", "fourth line\n"} {
+		_, err := r.Write([]byte(line))
+		require.NoError(t, err)
+		clock = clock.Add(time.Second)
+	}
+	r.compressing.Wait()
+
+	active, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "fourth line\n", string(active))
+
+	backups := r.Backups()
+	require.Len(t, backups, 2, "the oldest backup is pruned")
+	assert.Equal(t, filepath.Join(filepath.Dir(path), "knocker-20250301T120002.000.log.gz"), backups[0])
+	assert.Equal(t, "second line\n", readGzip(t, backups[0]))
+	assert.Equal(t, "third line\n", readGzip(t, backups[1]))
+}
+
+func TestRotatingFileRotatesByAge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "knocker.log")
+	clock := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	r := openTestRotatingFile(t, config.LogFile{Path: path, MaxSize: 1 << 20, MaxAge: time.Hour, MaxBackups: 5}, &clock)
+
+	_, err := r.Write([]byte("monday\n"))
+	require.NoError(t, err)
+	clock = clock.Add(30 * time.Minute)
+	_, err = r.Write([]byte("still monday\n"))
+	require.NoError(t, err)
+	assert.Empty(t, r.Backups())
+
+	clock = clock.Add(time.Hour)
+	_, err = r.Write([]byte("later\n"))
+	require.NoError(t, err)
+
+	backups := r.Backups()
+	require.Len(t, backups, 1)
+	assert.False(t, strings.HasSuffix(backups[0], ".gz"))
+	rotated, err := os.ReadFile(backups[0])
+	require.NoError(t, err)
+	assert.Equal(t, "monday\nstill monday\n", string(rotated))
+}
+
+func TestRotatingFileAppendsAndCloses(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "knocker.log")
+	require.NoError(t, os.WriteFile(path, []byte("earlier run\n"), 0o644))
+
+	r, err := OpenRotatingFile(config.LogFile{Path: path, MaxSize: 1 << 20, MaxAge: 24 * time.Hour, MaxBackups: 5})
+	require.NoError(t, err)
+	_, err = r.Write([]byte("this run\n"))
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+
+	_, err = r.Write([]byte("too late\n"))
+	assert.ErrorIs(t, err, os.ErrClosed)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "earlier run\nthis run\n", string(data))
+}
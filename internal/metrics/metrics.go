@@ -0,0 +1,164 @@
+// Package metrics exposes the service's activity as Prometheus metrics. The
+// service feeds them from the same places it emits its structured events, so
+// metrics and events always agree.
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "knocker"
+
+// Metrics holds the collectors updated by the service. A nil *Metrics is
+// valid and records nothing, so callers never need to check for it.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	knocks             *prometheus.CounterVec
+	ipLookups          *prometheus.CounterVec
+	healthCheckFailure prometheus.Counter
+	whitelistExpiry    prometheus.Gauge
+	nextKnock          prometheus.Gauge
+	cadence            prometheus.Gauge
+	apiLatency         *prometheus.HistogramVec
+}
+
+// New registers the Knocker collectors, along with the Go runtime and process
+// collectors, on a dedicated registry.
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		knocks: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "knocks_total",
+			Help:      "Knock attempts by result and trigger source.",
+		}, []string{"result", "source"}),
+		ipLookups: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "ip_lookups_total",
+			Help:      "Public IP lookups by result.",
+		}, []string{"result"}),
+		healthCheckFailure: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "health_check_failures_total",
+			Help:      "Failed API health checks.",
+		}),
+		whitelistExpiry: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "whitelist_expiry_timestamp_seconds",
+			Help:      "Unix time at which the current whitelist expires, or 0 when none is active.",
+		}),
+		nextKnock: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "next_knock_timestamp_seconds",
+			Help:      "Unix time of the next scheduled knock, or 0 when none is scheduled.",
+		}),
+		cadence: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "cadence_seconds",
+			Help:      "Current interval between scheduled knocks or IP checks.",
+		}),
+		apiLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "api_request_duration_seconds",
+			Help:      "Latency of requests to the Knocker API by operation and result.",
+			Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+		}, []string{"operation", "result"}),
+	}
+
+	m.registry.MustRegister(
+		m.knocks,
+		m.ipLookups,
+		m.healthCheckFailure,
+		m.whitelistExpiry,
+		m.nextKnock,
+		m.cadence,
+		m.apiLatency,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return m
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// Gatherer exposes the registry, for exporters other than the /metrics
+// handler.
+func (m *Metrics) Gatherer() prometheus.Gatherer {
+	return m.registry
+}
+
+// KnockTriggered counts a knock attempt.
+func (m *Metrics) KnockTriggered(source, result string) {
+	if m == nil {
+		return
+	}
+	m.knocks.WithLabelValues(result, source).Inc()
+}
+
+// IPLookup counts a public IP lookup.
+func (m *Metrics) IPLookup(err error) {
+	if m == nil {
+		return
+	}
+	m.ipLookups.WithLabelValues(resultLabel(err)).Inc()
+}
+
+// HealthCheckFailed counts a failed API health check.
+func (m *Metrics) HealthCheckFailed() {
+	if m == nil {
+		return
+	}
+	m.healthCheckFailure.Inc()
+}
+
+// SetWhitelistExpiry records when the current whitelist expires; zero clears it.
+func (m *Metrics) SetWhitelistExpiry(unix int64) {
+	if m == nil {
+		return
+	}
+	m.whitelistExpiry.Set(float64(unix))
+}
+
+// SetNextKnock records the next scheduled knock; the zero time clears it.
+func (m *Metrics) SetNextKnock(next time.Time) {
+	if m == nil {
+		return
+	}
+	if next.IsZero() {
+		m.nextKnock.Set(0)
+		return
+	}
+	m.nextKnock.Set(float64(next.Unix()))
+}
+
+// SetCadence records the current knock or IP check interval.
+func (m *Metrics) SetCadence(cadence time.Duration) {
+	if m == nil {
+		return
+	}
+	m.cadence.Set(cadence.Seconds())
+}
+
+// ObserveAPIRequest records the latency of an API request.
+func (m *Metrics) ObserveAPIRequest(operation string, duration time.Duration, err error) {
+	if m == nil {
+		return
+	}
+	m.apiLatency.WithLabelValues(operation, resultLabel(err)).Observe(duration.Seconds())
+}
+
+func resultLabel(err error) string {
+	if err != nil {
+		return "failure"
+	}
+	return "success"
+}
@@ -0,0 +1,60 @@
+package metrics
+
+import (
+	"errors"
+	"io"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func scrape(t *testing.T, m *Metrics) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body, err := io.ReadAll(rec.Body)
+	assert.NoError(t, err)
+	return string(body)
+}
+
+func TestMetricsExposition(t *testing.T) {
+	m := New()
+	m.KnockTriggered("schedule", "success")
+	m.KnockTriggered("schedule", "success")
+	m.KnockTriggered("cli", "failure")
+	m.IPLookup(nil)
+	m.IPLookup(errors.New("timeout"))
+	m.HealthCheckFailed()
+	m.SetWhitelistExpiry(1750000000)
+	m.SetNextKnock(time.Unix(1749999000, 0))
+	m.SetCadence(9 * time.Minute)
+	m.ObserveAPIRequest("knock", 120*time.Millisecond, nil)
+
+	body := scrape(t, m)
+
+	assert.Contains(t, body, `knocker_knocks_total{result="success",source="schedule"} 2`)
+	assert.Contains(t, body, `knocker_knocks_total{result="failure",source="cli"} 1`)
+	assert.Contains(t, body, `knocker_ip_lookups_total{result="failure"} 1`)
+	assert.Contains(t, body, `knocker_ip_lookups_total{result="success"} 1`)
+	assert.Contains(t, body, "knocker_health_check_failures_total 1")
+	assert.Contains(t, body, "knocker_whitelist_expiry_timestamp_seconds 1.75e+09")
+	assert.Contains(t, body, "knocker_next_knock_timestamp_seconds 1.749999e+09")
+	assert.Contains(t, body, "knocker_cadence_seconds 540")
+	assert.Contains(t, body, `knocker_api_request_duration_seconds_count{operation="knock",result="success"} 1`)
+	assert.Contains(t, body, "go_goroutines")
+}
+
+func TestNilMetricsIsNoOp(t *testing.T) {
+	var m *Metrics
+	assert.NotPanics(t, func() {
+		m.KnockTriggered("schedule", "success")
+		m.IPLookup(nil)
+		m.HealthCheckFailed()
+		m.SetWhitelistExpiry(0)
+		m.SetNextKnock(time.Time{})
+		m.SetCadence(time.Minute)
+		m.ObserveAPIRequest("knock", time.Second, nil)
+	})
+}
@@ -0,0 +1,59 @@
+package mqtt
+
+// discoveryEntity is one Home Assistant MQTT discovery message.
+type discoveryEntity struct {
+	topic   string
+	payload map[string]interface{}
+}
+
+// discovery returns the Home Assistant discovery payloads describing the
+// entities backed by the status topic. They are grouped under one device per
+// node ID.
+func (p *Publisher) discovery() []discoveryEntity {
+	device := map[string]interface{}{
+		"identifiers":  []string{"knocker_" + p.cfg.NodeID},
+		"name":         "Knocker " + p.cfg.NodeID,
+		"manufacturer": "Knocker",
+		"model":        "knocker-cli",
+	}
+	if p.version != "" {
+		device["sw_version"] = p.version
+	}
+
+	entity := func(component, object, name, template string, extra map[string]interface{}) discoveryEntity {
+		payload := map[string]interface{}{
+			"name":               name,
+			"unique_id":          "knocker_" + p.cfg.NodeID + "_" + object,
+			"state_topic":        p.topic("status"),
+			"value_template":     template,
+			"availability_topic": p.topic("availability"),
+			"device":             device,
+		}
+		for key, value := range extra {
+			payload[key] = value
+		}
+		return discoveryEntity{
+			topic:   p.cfg.DiscoveryPrefix + "/" + component + "/" + p.cfg.NodeID + "/" + object + "/config",
+			payload: payload,
+		}
+	}
+
+	return []discoveryEntity{
+		entity("sensor", "state", "State", "{{ value_json.state }}", map[string]interface{}{
+			"icon": "mdi:shield-sync",
+		}),
+		entity("binary_sensor", "whitelisted", "Whitelisted", "{{ 'ON' if value_json.whitelisted else 'OFF' }}", map[string]interface{}{
+			"icon": "mdi:shield-check",
+		}),
+		entity("sensor", "whitelist_ip", "Whitelist IP", "{{ value_json.whitelist_ip }}", map[string]interface{}{
+			"icon":                  "mdi:ip-network",
+			"json_attributes_topic": p.topic("status"),
+		}),
+		entity("sensor", "expires_at", "Whitelist expires", "{{ value_json.expires_at }}", map[string]interface{}{
+			"device_class": "timestamp",
+		}),
+		entity("sensor", "next_knock_at", "Next knock", "{{ value_json.next_knock_at }}", map[string]interface{}{
+			"device_class": "timestamp",
+		}),
+	}
+}
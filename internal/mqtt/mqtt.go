@@ -0,0 +1,362 @@
+// Package mqtt publishes the service status and events to an MQTT broker so
+// home-automation dashboards such as Home Assistant can show them.
+//
+// Topics, below the configured prefix:
+//
+//	<prefix>/availability     "online" or "offline" (retained, Last Will)
+//	<prefix>/status           JSON status document (retained)
+//	<prefix>/events/<Type>    one JSON record per event
+package mqtt
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/FarisZR/knocker-cli/internal/config"
+	"github.com/FarisZR/knocker-cli/internal/events"
+	internalService "github.com/FarisZR/knocker-cli/internal/service"
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"github.com/eclipse/paho.mqtt.golang/packets"
+)
+
+// Availability payloads. PayloadOffline is also the Last Will, so the broker
+// publishes it when the connection drops without a clean shutdown.
+const (
+	PayloadOnline  = "online"
+	PayloadOffline = "offline"
+)
+
+const (
+	connectTimeout = 10 * time.Second
+	closeTimeout   = 2 * time.Second
+)
+
+// connectRetryInterval is the wait between background connection attempts
+// while the broker is unreachable.
+var connectRetryInterval = 30 * time.Second
+
+// errConnectTimeout is returned when the broker does not answer in time.
+var errConnectTimeout = errors.New("timed out")
+
+// Status is the retained status document. Absent times are null so Home
+// Assistant shows them as unknown.
+type Status struct {
+	State         string     `json:"state"`
+	Whitelisted   bool       `json:"whitelisted"`
+	WhitelistIP   string     `json:"whitelist_ip"`
+	WhitelistIPs  []string   `json:"whitelist_ips"`
+	ExpiresAt     *time.Time `json:"expires_at"`
+	NextKnockAt   *time.Time `json:"next_knock_at"`
+	CadenceSource string     `json:"cadence_source"`
+	// APIState is "open" while the Knocker API is unavailable; it is absent
+	// when the service runs without a circuit breaker.
+	APIState string `json:"api_state,omitempty"`
+}
+
+// Publisher is an event sink publishing to an MQTT broker. It keeps the
+// retained status up to date from ServiceState, StatusSnapshot,
+// NextKnockUpdated and WhitelistExpired events and publishes every event
+// passing the configured filter. Publishing never blocks the caller; the
+// availability, discovery payloads and status are published again after
+// each reconnect.
+type Publisher struct {
+	cfg     config.MQTT
+	version string
+	client  paho.Client
+	filter  map[string]bool
+	onError func(error)
+
+	mu     sync.Mutex
+	status Status
+	closed bool
+	// stop ends background connection attempts on Close.
+	stop chan struct{}
+}
+
+// Connect connects to the broker described by cfg and returns a publisher.
+// When the broker cannot be reached the publisher is returned anyway and
+// keeps trying in the background, so a broker that starts after Knocker is
+// picked up; only errors retrying cannot fix, such as rejected credentials
+// or an untrusted certificate, are returned. version is reported in the
+// discovery payloads. onError, when non-nil, receives failed connection
+// attempts and connection losses.
+func Connect(cfg config.MQTT, version string, onError func(error)) (*Publisher, error) {
+	p := &Publisher{
+		cfg:     cfg,
+		version: version,
+		filter:  map[string]bool{},
+		onError: onError,
+		status:  Status{WhitelistIPs: []string{}},
+		stop:    make(chan struct{}),
+	}
+	for _, eventType := range cfg.Events {
+		p.filter[strings.ToLower(eventType)] = true
+	}
+
+	opts := paho.NewClientOptions().
+		AddBroker(cfg.Broker).
+		SetClientID(cfg.ClientID).
+		SetUsername(cfg.Username).
+		SetPassword(cfg.Password).
+		SetWill(p.topic("availability"), PayloadOffline, cfg.QoS, true).
+		SetConnectTimeout(connectTimeout).
+		SetAutoReconnect(true).
+		SetOrderMatters(false).
+		SetOnConnectHandler(func(paho.Client) { p.announce() }).
+		SetConnectionLostHandler(func(_ paho.Client, err error) {
+			p.report(fmt.Errorf("MQTT connection lost: %w", err))
+		})
+	if tlsConfig, err := p.tlsConfig(); err != nil {
+		return nil, err
+	} else if tlsConfig != nil {
+		opts.SetTLSConfig(tlsConfig)
+	}
+
+	p.client = paho.NewClient(opts)
+	err := p.connect()
+	switch {
+	case err == nil:
+	case retryable(err):
+		p.report(fmt.Errorf("%w; retrying every %s", err, connectRetryInterval))
+		go p.retryConnect()
+	default:
+		p.client.Disconnect(0)
+		return nil, err
+	}
+	return p, nil
+}
+
+// connect makes one connection attempt.
+func (p *Publisher) connect() error {
+	token := p.client.Connect()
+	if !token.WaitTimeout(connectTimeout) {
+		p.client.Disconnect(0)
+		return fmt.Errorf("connecting to %s: %w", config.RedactURL(p.cfg.Broker), errConnectTimeout)
+	}
+	if err := token.Error(); err != nil {
+		return fmt.Errorf("connecting to %s: %w", config.RedactURL(p.cfg.Broker), err)
+	}
+	return nil
+}
+
+// retryConnect keeps trying to connect until it succeeds, fails for good or
+// the publisher is closed. Once connected, the client's auto-reconnect takes
+// over.
+func (p *Publisher) retryConnect() {
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-time.After(connectRetryInterval):
+		}
+		err := p.connect()
+		select {
+		case <-p.stop:
+			// Closed while connecting.
+			if err == nil {
+				p.client.Disconnect(0)
+			}
+			return
+		default:
+		}
+		if err == nil {
+			return
+		}
+		p.report(err)
+		if !retryable(err) {
+			return
+		}
+	}
+}
+
+// retryable reports whether a failed connection attempt may succeed later:
+// the broker was unreachable, did not answer or reported itself unavailable.
+func retryable(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, errConnectTimeout) || errors.Is(err, packets.ErrorRefusedServerUnavailable)
+}
+
+// Emit updates the retained status from event and publishes the event.
+func (p *Publisher) Emit(event events.Event) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return nil
+	}
+
+	var errs []error
+	if p.apply(event) {
+		errs = append(errs, p.publishStatus())
+	}
+	if len(p.filter) == 0 || p.filter[strings.ToLower(event.Type)] {
+		payload, err := json.Marshal(events.Record(event))
+		if err != nil {
+			return err
+		}
+		errs = append(errs, p.publish(p.topic("events/"+event.Type), payload, false))
+	}
+	return errors.Join(errs...)
+}
+
+// Close marks the service offline and disconnects. Later events are
+// ignored.
+func (p *Publisher) Close() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	close(p.stop)
+	p.mu.Unlock()
+
+	if !p.client.IsConnected() {
+		// Never connected, so the broker holds no online state to clear.
+		p.client.Disconnect(0)
+		return nil
+	}
+	token := p.client.Publish(p.topic("availability"), p.cfg.QoS, true, PayloadOffline)
+	token.WaitTimeout(closeTimeout)
+	p.client.Disconnect(250)
+	return token.Error()
+}
+
+// apply folds event into the status and reports whether it changed. p.mu
+// must be held.
+func (p *Publisher) apply(event events.Event) bool {
+	before := p.status
+	before.WhitelistIPs = append([]string(nil), p.status.WhitelistIPs...)
+
+	switch data := internalService.DecodeEventData(event).(type) {
+	case internalService.ServiceStateData:
+		p.status.State = data.State
+		if data.State == internalService.ServiceStateResumed {
+			p.status.State = internalService.ServiceStateStarted
+		}
+	case internalService.StatusSnapshotData:
+		p.status.Whitelisted = data.WhitelistIP != ""
+		p.status.WhitelistIP = data.WhitelistIP
+		p.status.WhitelistIPs = data.WhitelistIPs
+		if p.status.WhitelistIPs == nil {
+			p.status.WhitelistIPs = []string{}
+		}
+		p.status.ExpiresAt = data.ExpiresAt
+		p.status.NextKnockAt = data.NextKnockAt
+		p.status.CadenceSource = data.CadenceSource
+		p.status.APIState = data.APIState
+	case internalService.NextKnockUpdatedData:
+		p.status.NextKnockAt = data.NextKnockAt
+		if data.CadenceSource != "" {
+			p.status.CadenceSource = data.CadenceSource
+		}
+	case internalService.WhitelistExpiredData:
+		if data.IP == p.status.WhitelistIP {
+			p.status.Whitelisted = false
+			p.status.WhitelistIP = ""
+			p.status.ExpiresAt = nil
+		}
+	default:
+		return false
+	}
+	return !reflect.DeepEqual(before, p.status)
+}
+
+// announce runs on every (re)connect: it marks the service online and
+// publishes the discovery payloads and the current status. Errors are
+// reported rather than returned, as there is no caller to return them to.
+func (p *Publisher) announce() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return
+	}
+
+	errs := []error{p.publish(p.topic("availability"), []byte(PayloadOnline), true)}
+	if p.cfg.Discovery {
+		for _, entity := range p.discovery() {
+			payload, err := json.Marshal(entity.payload)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			errs = append(errs, p.publish(entity.topic, payload, true))
+		}
+	}
+	errs = append(errs, p.publishStatus())
+	if err := errors.Join(errs...); err != nil {
+		p.report(err)
+	}
+}
+
+// publishStatus publishes the retained status document. p.mu must be held.
+func (p *Publisher) publishStatus() error {
+	payload, err := json.Marshal(p.status)
+	if err != nil {
+		return err
+	}
+	return p.publish(p.topic("status"), payload, true)
+}
+
+// publish hands the message to the client without waiting for the broker.
+// Only errors the client reports immediately, such as not being connected,
+// are returned.
+func (p *Publisher) publish(topic string, payload []byte, retained bool) error {
+	token := p.client.Publish(topic, p.cfg.QoS, retained, payload)
+	select {
+	case <-token.Done():
+		if err := token.Error(); err != nil {
+			return fmt.Errorf("publishing to %s: %w", topic, err)
+		}
+	default:
+	}
+	return nil
+}
+
+func (p *Publisher) topic(name string) string {
+	return p.cfg.TopicPrefix + "/" + name
+}
+
+func (p *Publisher) report(err error) {
+	if p.onError != nil {
+		p.onError(err)
+	}
+}
+
+// tlsConfig builds the TLS settings from the mqtt.tls options. It returns
+// nil when none are set, leaving TLS to the broker URL scheme.
+func (p *Publisher) tlsConfig() (*tls.Config, error) {
+	cfg := p.cfg
+	if cfg.TLSCAFile == "" && cfg.TLSCertFile == "" && !cfg.TLSInsecure {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: cfg.TLSInsecure}
+	if cfg.TLSCAFile != "" {
+		pem, err := os.ReadFile(cfg.TLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading MQTT CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", cfg.TLSCAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if cfg.TLSCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.TLSCertFile, cfg.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading MQTT client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
@@ -0,0 +1,291 @@
+package mqtt
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io"
+	"log/slog"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/FarisZR/knocker-cli/internal/config"
+	"github.com/FarisZR/knocker-cli/internal/events"
+	"github.com/FarisZR/knocker-cli/internal/journald"
+	internalService "github.com/FarisZR/knocker-cli/internal/service"
+	mochi "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+	"github.com/mochi-mqtt/server/v2/packets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// broker is an in-process MQTT broker recording every published message.
+type broker struct {
+	*mochi.Server
+	addr string
+
+	mu       sync.Mutex
+	messages []packets.Packet
+}
+
+func startBroker(t *testing.T, authHook mochi.Hook, authConfig any, tlsConfig *tls.Config) *broker {
+	t.Helper()
+	return startBrokerOn(t, "127.0.0.1:0", authHook, authConfig, tlsConfig)
+}
+
+func startBrokerOn(t *testing.T, address string, authHook mochi.Hook, authConfig any, tlsConfig *tls.Config) *broker {
+	t.Helper()
+
+	server := mochi.New(&mochi.Options{
+		InlineClient: true,
+		Logger:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+	require.NoError(t, server.AddHook(authHook, authConfig))
+	listener := listeners.NewTCP(listeners.Config{ID: "test", Address: address, TLSConfig: tlsConfig})
+	require.NoError(t, server.AddListener(listener))
+	require.NoError(t, server.Serve())
+	t.Cleanup(func() { server.Close() })
+
+	b := &broker{Server: server, addr: listener.Address()}
+	require.NoError(t, server.Subscribe("#", 1, func(_ *mochi.Client, _ packets.Subscription, pk packets.Packet) {
+		b.mu.Lock()
+		b.messages = append(b.messages, pk)
+		b.mu.Unlock()
+	}))
+	return b
+}
+
+// payloads returns the payloads published to topic so far.
+func (b *broker) payloads(topic string) []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var payloads []string
+	for _, pk := range b.messages {
+		if pk.TopicName == topic {
+			payloads = append(payloads, string(pk.Payload))
+		}
+	}
+	return payloads
+}
+
+// retained returns the retained payload of topic, or "" when there is none.
+func (b *broker) retained(topic string) string {
+	for _, pk := range b.Topics.Messages(topic) {
+		return string(pk.Payload)
+	}
+	return ""
+}
+
+func testConfig(broker string) config.MQTT {
+	return config.MQTT{
+		Broker:          broker,
+		ClientID:        "knocker-test",
+		NodeID:          "test",
+		TopicPrefix:     "knocker/test",
+		QoS:             1,
+		Discovery:       true,
+		DiscoveryPrefix: "homeassistant",
+	}
+}
+
+func TestPublisherPublishesStatusAndEvents(t *testing.T) {
+	b := startBroker(t, new(auth.AllowHook), nil, nil)
+	p, err := Connect(testConfig("tcp://"+b.addr), "1.2.3", nil)
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool { return b.retained("knocker/test/availability") == PayloadOnline }, 5*time.Second, 10*time.Millisecond)
+	require.Eventually(t, func() bool { return b.retained("homeassistant/sensor/test/expires_at/config") != "" }, 5*time.Second, 10*time.Millisecond)
+
+	var discovery map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(b.retained("homeassistant/sensor/test/expires_at/config")), &discovery))
+	assert.Equal(t, "timestamp", discovery["device_class"])
+	assert.Equal(t, "knocker/test/status", discovery["state_topic"])
+	assert.Equal(t, "knocker/test/availability", discovery["availability_topic"])
+	assert.Equal(t, "knocker_test_expires_at", discovery["unique_id"])
+	assert.Equal(t, "1.2.3", discovery["device"].(map[string]interface{})["sw_version"])
+	assert.NotEmpty(t, b.retained("homeassistant/binary_sensor/test/whitelisted/config"))
+
+	require.NoError(t, p.Emit(events.New(internalService.EventServiceState, "Service state: started", journald.PriInfo, journald.Fields{
+		"KNOCKER_SERVICE_STATE": internalService.ServiceStateStarted,
+	})))
+	require.NoError(t, p.Emit(events.New(internalService.EventStatusSnapshot, "Status", journald.PriInfo, journald.Fields{
+		"KNOCKER_WHITELIST_IP":   "203.0.113.7",
+		"KNOCKER_EXPIRES_UNIX":   "1700000600",
+		"KNOCKER_NEXT_AT_UNIX":   "1700000300",
+		"KNOCKER_CADENCE_SOURCE": "ttl",
+	})))
+
+	var status Status
+	require.Eventually(t, func() bool {
+		status = Status{}
+		return json.Unmarshal([]byte(b.retained("knocker/test/status")), &status) == nil && status.WhitelistIP != ""
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, internalService.ServiceStateStarted, status.State)
+	assert.True(t, status.Whitelisted)
+	assert.Equal(t, "203.0.113.7", status.WhitelistIP)
+	require.NotNil(t, status.ExpiresAt)
+	assert.Equal(t, int64(1700000600), status.ExpiresAt.Unix())
+	require.NotNil(t, status.NextKnockAt)
+	assert.Equal(t, int64(1700000300), status.NextKnockAt.Unix())
+
+	require.NoError(t, p.Emit(events.New(internalService.EventWhitelistExpired, "Expired", journald.PriNotice, journald.Fields{
+		"KNOCKER_WHITELIST_IP": "203.0.113.7",
+	})))
+	require.Eventually(t, func() bool { return len(b.payloads("knocker/test/events/WhitelistExpired")) == 1 }, 5*time.Second, 10*time.Millisecond)
+	var record map[string]string
+	require.NoError(t, json.Unmarshal([]byte(b.payloads("knocker/test/events/WhitelistExpired")[0]), &record))
+	assert.Equal(t, "203.0.113.7", record["KNOCKER_WHITELIST_IP"])
+	assert.Equal(t, "Expired", record["MESSAGE"])
+
+	require.Eventually(t, func() bool {
+		status = Status{}
+		return json.Unmarshal([]byte(b.retained("knocker/test/status")), &status) == nil && !status.Whitelisted
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Nil(t, status.ExpiresAt)
+
+	require.NoError(t, p.Close())
+	assert.Equal(t, PayloadOffline, b.retained("knocker/test/availability"))
+	assert.NoError(t, p.Emit(events.New(internalService.EventError, "ignored", journald.PriErr, nil)))
+}
+
+func TestPublisherFiltersEvents(t *testing.T) {
+	b := startBroker(t, new(auth.AllowHook), nil, nil)
+	cfg := testConfig("tcp://" + b.addr)
+	cfg.Events = []string{"error"}
+	cfg.Discovery = false
+	p, err := Connect(cfg, "", nil)
+	require.NoError(t, err)
+
+	require.NoError(t, p.Emit(events.New(internalService.EventKnockTriggered, "Knocked", journald.PriInfo, nil)))
+	require.NoError(t, p.Emit(events.New(internalService.EventError, "Failed", journald.PriErr, nil)))
+	require.NoError(t, p.Close())
+
+	assert.Len(t, b.payloads("knocker/test/events/Error"), 1)
+	assert.Empty(t, b.payloads("knocker/test/events/KnockTriggered"))
+	assert.Empty(t, b.Topics.Messages("homeassistant/#"))
+}
+
+func TestPublisherLastWillAndReconnect(t *testing.T) {
+	b := startBroker(t, new(auth.AllowHook), nil, nil)
+	lost := make(chan error, 1)
+	p, err := Connect(testConfig("tcp://"+b.addr), "", func(err error) {
+		select {
+		case lost <- err:
+		default:
+		}
+	})
+	require.NoError(t, err)
+	defer p.Close()
+	require.Eventually(t, func() bool { return len(b.payloads("knocker/test/availability")) == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	client, ok := b.Clients.Get("knocker-test")
+	require.True(t, ok)
+	client.Stop(errors.New("connection dropped"))
+
+	select {
+	case err := <-lost:
+		assert.ErrorContains(t, err, "connection lost")
+	case <-time.After(5 * time.Second):
+		t.Fatal("connection loss was not reported")
+	}
+	require.Eventually(t, func() bool { return len(b.payloads("knocker/test/availability")) >= 3 }, 10*time.Second, 10*time.Millisecond, "availability: %v", b.payloads("knocker/test/availability"))
+	// An unacknowledged "online" may be delivered again after reconnecting,
+	// so only the order matters: online, the Last Will, online again.
+	availability := b.payloads("knocker/test/availability")
+	assert.Equal(t, []string{PayloadOnline, PayloadOffline}, availability[:2])
+	assert.Equal(t, PayloadOnline, availability[len(availability)-1])
+	assert.Equal(t, PayloadOnline, b.retained("knocker/test/availability"))
+}
+
+func TestConnectRetriesUntilBrokerStarts(t *testing.T) {
+	defer func(interval time.Duration) { connectRetryInterval = interval }(connectRetryInterval)
+	connectRetryInterval = 20 * time.Millisecond
+
+	// Reserve a port with nothing listening on it yet.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	address := l.Addr().String()
+	require.NoError(t, l.Close())
+
+	failures := make(chan error, 100)
+	p, err := Connect(testConfig("tcp://"+address), "", func(err error) {
+		select {
+		case failures <- err:
+		default:
+		}
+	})
+	require.NoError(t, err, "an unreachable broker does not disable publishing")
+	defer p.Close()
+	assert.ErrorContains(t, <-failures, "retrying")
+
+	b := startBrokerOn(t, address, new(auth.AllowHook), nil, nil)
+	require.Eventually(t, func() bool { return b.retained("knocker/test/availability") == PayloadOnline }, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestConnectAuthenticates(t *testing.T) {
+	b := startBroker(t, new(auth.Hook), &auth.Options{Ledger: &auth.Ledger{
+		Auth: auth.AuthRules{{Username: "knocker", Password: "secret", Allow: true}},
+	}}, nil)
+
+	cfg := testConfig("tcp://" + b.addr)
+	cfg.Username, cfg.Password = "knocker", "wrong"
+	_, err := Connect(cfg, "", nil)
+	assert.Error(t, err)
+
+	cfg.Password = "secret"
+	p, err := Connect(cfg, "", nil)
+	require.NoError(t, err)
+	assert.NoError(t, p.Close())
+}
+
+func TestConnectVerifiesBrokerCertificate(t *testing.T) {
+	serverTLS, caFile := selfSignedTLS(t)
+	b := startBroker(t, new(auth.AllowHook), nil, serverTLS)
+
+	cfg := testConfig("mqtts://" + b.addr)
+	_, err := Connect(cfg, "", nil)
+	assert.Error(t, err, "the test CA is not trusted by default")
+
+	cfg.TLSCAFile = caFile
+	p, err := Connect(cfg, "", nil)
+	require.NoError(t, err)
+	assert.NoError(t, p.Close())
+}
+
+// selfSignedTLS returns a server TLS config for 127.0.0.1 and the path of
+// its certificate in PEM form.
+func selfSignedTLS(t *testing.T) (*tls.Config, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+
+	return &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}, caFile
+}
@@ -0,0 +1,56 @@
+package notify
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	notificationsName      = "org.freedesktop.Notifications"
+	notificationsPath      = dbus.ObjectPath("/org/freedesktop/Notifications")
+	notificationsInterface = "org.freedesktop.Notifications"
+	appName                = "Knocker"
+
+	// sendTimeout bounds a Notify call, so a hung notification daemon
+	// cannot hold up the events the notifier is handling.
+	sendTimeout = 3 * time.Second
+)
+
+// DBusSender sends notifications through the org.freedesktop.Notifications
+// service. A new notification replaces the previous one of the same kind, so
+// a stale warning does not linger next to its update.
+type DBusSender struct {
+	conn    *dbus.Conn
+	timeout time.Duration
+
+	mu  sync.Mutex
+	ids map[string]uint32
+}
+
+// NewDBusSender returns a sender using conn, normally the session bus.
+func NewDBusSender(conn *dbus.Conn) *DBusSender {
+	return &DBusSender{conn: conn, timeout: sendTimeout, ids: map[string]uint32{}}
+}
+
+func (d *DBusSender) Send(n Notification) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), d.timeout)
+	defer cancel()
+	hints := map[string]dbus.Variant{"urgency": dbus.MakeVariant(byte(n.Urgency))}
+	call := d.conn.Object(notificationsName, notificationsPath).CallWithContext(ctx,
+		notificationsInterface+".Notify", 0,
+		appName, d.ids[n.Kind], "", n.Summary, n.Body, []string{}, hints, int32(-1),
+	)
+
+	var id uint32
+	if err := call.Store(&id); err != nil {
+		return err
+	}
+	d.ids[n.Kind] = id
+	return nil
+}
@@ -0,0 +1,68 @@
+package notify
+
+import (
+	"testing"
+	"time"
+
+	"github.com/FarisZR/knocker-cli/internal/dbustest"
+	"github.com/godbus/dbus/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type notifyCall struct {
+	ReplacesID uint32
+	Summary    string
+	Urgency    byte
+}
+
+// notificationServer stands in for the desktop's notification daemon.
+type notificationServer struct {
+	calls chan notifyCall
+	next  uint32
+}
+
+func (s *notificationServer) Notify(appName string, replacesID uint32, icon, summary, body string, actions []string, hints map[string]dbus.Variant, timeout int32) (uint32, *dbus.Error) {
+	urgency, _ := hints["urgency"].Value().(byte)
+	s.calls <- notifyCall{ReplacesID: replacesID, Summary: summary, Urgency: urgency}
+	s.next++
+	return s.next, nil
+}
+
+func TestDBusSenderReplacesNotificationsOfTheSameKind(t *testing.T) {
+	address := dbustest.StartBus(t)
+
+	server := &notificationServer{calls: make(chan notifyCall, 4)}
+	serverConn := dbustest.Connect(t, address)
+	require.NoError(t, serverConn.Export(server, notificationsPath, notificationsInterface))
+	reply, err := serverConn.RequestName(notificationsName, dbus.NameFlagDoNotQueue)
+	require.NoError(t, err)
+	require.Equal(t, dbus.RequestNameReplyPrimaryOwner, reply)
+
+	sender := NewDBusSender(dbustest.Connect(t, address))
+	require.NoError(t, sender.Send(Notification{Kind: KindExpiresSoon, Summary: "first", Urgency: UrgencyNormal}))
+	require.NoError(t, sender.Send(Notification{Kind: KindExpired, Summary: "second", Urgency: UrgencyCritical}))
+	require.NoError(t, sender.Send(Notification{Kind: KindExpiresSoon, Summary: "third"}))
+
+	assert.Equal(t, notifyCall{ReplacesID: 0, Summary: "first", Urgency: byte(UrgencyNormal)}, <-server.calls)
+	assert.Equal(t, notifyCall{ReplacesID: 0, Summary: "second", Urgency: byte(UrgencyCritical)}, <-server.calls)
+	assert.Equal(t, notifyCall{ReplacesID: 1, Summary: "third", Urgency: byte(UrgencyLow)}, <-server.calls)
+}
+
+func TestDBusSenderGivesUpOnAHungDaemon(t *testing.T) {
+	address := dbustest.StartBus(t)
+
+	// Nobody reads calls, so Notify never returns until the test ends.
+	server := &notificationServer{calls: make(chan notifyCall)}
+	serverConn := dbustest.Connect(t, address)
+	require.NoError(t, serverConn.Export(server, notificationsPath, notificationsInterface))
+	_, err := serverConn.RequestName(notificationsName, dbus.NameFlagDoNotQueue)
+	require.NoError(t, err)
+	t.Cleanup(func() { <-server.calls })
+
+	sender := NewDBusSender(dbustest.Connect(t, address))
+	sender.timeout = 100 * time.Millisecond
+	start := time.Now()
+	assert.Error(t, sender.Send(Notification{Kind: KindExpired, Summary: "stuck"}))
+	assert.Less(t, time.Since(start), 2*time.Second)
+}
@@ -0,0 +1,224 @@
+// Package notify turns service events into desktop notifications: repeated
+// knock failures, a whitelist about to expire, and an expired whitelist.
+package notify
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/FarisZR/knocker-cli/internal/config"
+	"github.com/FarisZR/knocker-cli/internal/events"
+	internalService "github.com/FarisZR/knocker-cli/internal/service"
+)
+
+// Kinds of notification. Each kind is rate limited separately.
+const (
+	KindKnockFailed    = "knock_failed"
+	KindExpiresSoon    = "expires_soon"
+	KindExpired        = "expired"
+	KindFailureCleared = "failure_cleared"
+)
+
+// Urgency levels defined by the desktop notifications specification.
+type Urgency byte
+
+const (
+	UrgencyLow      Urgency = 0
+	UrgencyNormal   Urgency = 1
+	UrgencyCritical Urgency = 2
+)
+
+// Notification is a single desktop notification.
+type Notification struct {
+	Kind    string
+	Summary string
+	Body    string
+	Urgency Urgency
+}
+
+// Sender delivers notifications to the desktop.
+type Sender interface {
+	Send(n Notification) error
+}
+
+// warningGrace is how long after a scheduled knock the expiry warning waits
+// for the knock to refresh the whitelist.
+const warningGrace = 30 * time.Second
+
+// Notifier is an event sink that raises desktop notifications. Knock,
+// health check and IP lookup errors count as failures until a knock
+// succeeds. The expiry warning is scheduled from StatusSnapshot and
+// NextKnockUpdated events: it fires ExpiryWarning before the whitelist
+// expires, but never before the next scheduled knock has had the chance to
+// refresh it.
+type Notifier struct {
+	cfg    config.Notifications
+	sender Sender
+	logger *slog.Logger
+	now    func() time.Time
+
+	mu            sync.Mutex
+	failures      int
+	lastSent      map[string]time.Time
+	whitelistIP   string
+	expiresUnix   int64
+	nextKnockUnix int64
+	warning       *time.Timer
+	closed        bool
+}
+
+// New returns a Notifier sending through sender. Delivery errors are
+// written to logger.
+func New(cfg config.Notifications, sender Sender, logger *slog.Logger) *Notifier {
+	return &Notifier{
+		cfg:      cfg,
+		sender:   sender,
+		logger:   logger,
+		now:      time.Now,
+		lastSent: map[string]time.Time{},
+	}
+}
+
+// Emit updates the notifier's state from event and sends any notification
+// it calls for.
+func (n *Notifier) Emit(event events.Event) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.closed {
+		return nil
+	}
+
+	switch event.Type {
+	case internalService.EventError:
+		switch event.Fields["KNOCKER_ERROR_CODE"] {
+		case internalService.ErrorCodeKnockFailed, internalService.ErrorCodeHealthCheck, internalService.ErrorCodeIPLookup:
+			n.failures++
+			if n.failures >= n.cfg.FailureThreshold {
+				n.send(Notification{
+					Kind:    KindKnockFailed,
+					Summary: "Knocker cannot refresh the whitelist",
+					Body:    fmt.Sprintf("%d attempts failed in a row. Last error: %s", n.failures, event.Fields["KNOCKER_ERROR_MSG"]),
+					Urgency: UrgencyCritical,
+				})
+			}
+		}
+	case internalService.EventAPIUnavailable:
+		// The service stops reporting each failed request once the API is
+		// known to be down, so the outage counts as reaching the threshold.
+		n.failures = max(n.failures, n.cfg.FailureThreshold)
+		n.send(Notification{
+			Kind:    KindKnockFailed,
+			Summary: "Knocker API unavailable",
+			Body:    fmt.Sprintf("%s is not answering. Last error: %s", event.Fields["KNOCKER_CONTEXT"], event.Fields["KNOCKER_ERROR_MSG"]),
+			Urgency: UrgencyCritical,
+		})
+	case internalService.EventKnockTriggered:
+		if event.Fields["KNOCKER_RESULT"] == internalService.ResultSuccess {
+			if n.failures >= n.cfg.FailureThreshold {
+				n.send(Notification{
+					Kind:    KindFailureCleared,
+					Summary: "Knocker is whitelisted again",
+					Body:    fmt.Sprintf("The knock succeeded after %d failed attempts.", n.failures),
+					Urgency: UrgencyLow,
+				})
+			}
+			n.failures = 0
+		}
+	case internalService.EventStatusSnapshot:
+		n.whitelistIP = event.Fields["KNOCKER_WHITELIST_IP"]
+		n.expiresUnix, _ = strconv.ParseInt(event.Fields["KNOCKER_EXPIRES_UNIX"], 10, 64)
+		n.nextKnockUnix, _ = strconv.ParseInt(event.Fields["KNOCKER_NEXT_AT_UNIX"], 10, 64)
+		n.scheduleWarning()
+	case internalService.EventNextKnockUpdated:
+		n.nextKnockUnix, _ = strconv.ParseInt(event.Fields["KNOCKER_NEXT_AT_UNIX"], 10, 64)
+		n.scheduleWarning()
+	case internalService.EventWhitelistExpired:
+		body := "The whitelist entry has expired."
+		if ip := event.Fields["KNOCKER_WHITELIST_IP"]; ip != "" {
+			body = fmt.Sprintf("The whitelist entry for %s has expired.", ip)
+		}
+		n.send(Notification{Kind: KindExpired, Summary: "Knocker whitelist expired", Body: body, Urgency: UrgencyCritical})
+	}
+	return nil
+}
+
+// Close cancels a pending expiry warning. Later events are ignored.
+func (n *Notifier) Close() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.closed = true
+	if n.warning != nil {
+		n.warning.Stop()
+	}
+	return nil
+}
+
+// scheduleWarning replaces the pending expiry warning with one for the
+// current whitelist. No warning is scheduled without an expiry, or when the
+// next knock is due too close to the expiry to warn after it. n.mu must be
+// held.
+func (n *Notifier) scheduleWarning() {
+	if n.warning != nil {
+		n.warning.Stop()
+		n.warning = nil
+	}
+	if n.expiresUnix <= 0 {
+		return
+	}
+
+	now := n.now()
+	expires := time.Unix(n.expiresUnix, 0)
+	warnAt := expires.Add(-n.cfg.ExpiryWarning)
+	if n.nextKnockUnix > 0 {
+		if afterKnock := time.Unix(n.nextKnockUnix, 0).Add(warningGrace); afterKnock.After(warnAt) {
+			warnAt = afterKnock
+		}
+	}
+	if !warnAt.Before(expires) || !expires.After(now) {
+		return
+	}
+
+	ip := n.whitelistIP
+	n.warning = time.AfterFunc(max(warnAt.Sub(now), 0), func() {
+		n.mu.Lock()
+		defer n.mu.Unlock()
+		if n.closed {
+			return
+		}
+		n.warnExpiry(ip, expires)
+	})
+}
+
+func (n *Notifier) warnExpiry(ip string, expires time.Time) {
+	remaining := expires.Sub(n.now()).Round(time.Second)
+	if remaining <= 0 {
+		return
+	}
+	entry := "The whitelist"
+	if ip != "" {
+		entry = "The whitelist for " + ip
+	}
+	n.send(Notification{
+		Kind:    KindExpiresSoon,
+		Summary: "Knocker whitelist expires soon",
+		Body:    fmt.Sprintf("%s expires at %s (in %v).", entry, expires.Local().Format("15:04"), remaining),
+		Urgency: UrgencyNormal,
+	})
+}
+
+// send delivers notification unless one of the same kind was sent within
+// the configured minimum interval. n.mu must be held.
+func (n *Notifier) send(notification Notification) {
+	now := n.now()
+	if last, ok := n.lastSent[notification.Kind]; ok && now.Sub(last) < n.cfg.MinInterval {
+		return
+	}
+	n.lastSent[notification.Kind] = now
+
+	if err := n.sender.Send(notification); err != nil && n.logger != nil {
+		n.logger.Warn("Failed to send desktop notification", "kind", notification.Kind, "error", err)
+	}
+}
@@ -0,0 +1,162 @@
+package notify
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/FarisZR/knocker-cli/internal/config"
+	"github.com/FarisZR/knocker-cli/internal/events"
+	"github.com/FarisZR/knocker-cli/internal/journald"
+	internalService "github.com/FarisZR/knocker-cli/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeSender struct {
+	mu   sync.Mutex
+	sent []Notification
+	ch   chan Notification
+}
+
+func newFakeSender() *fakeSender {
+	return &fakeSender{ch: make(chan Notification, 16)}
+}
+
+func (f *fakeSender) Send(n Notification) error {
+	f.mu.Lock()
+	f.sent = append(f.sent, n)
+	f.mu.Unlock()
+	f.ch <- n
+	return nil
+}
+
+func (f *fakeSender) kinds() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var kinds []string
+	for _, n := range f.sent {
+		kinds = append(kinds, n.Kind)
+	}
+	return kinds
+}
+
+func testConfig() config.Notifications {
+	return config.Notifications{
+		Enabled:          true,
+		ExpiryWarning:    10 * time.Minute,
+		FailureThreshold: 3,
+		MinInterval:      15 * time.Minute,
+	}
+}
+
+func event(eventType string, fields journald.Fields) events.Event {
+	return events.New(eventType, "", journald.PriInfo, fields)
+}
+
+func knockFailed() events.Event {
+	return event(internalService.EventError, journald.Fields{
+		"KNOCKER_ERROR_CODE": internalService.ErrorCodeKnockFailed,
+		"KNOCKER_ERROR_MSG":  "Knock failed: knock failed with status code: 502",
+	})
+}
+
+func TestNotifierReportsRepeatedFailuresOnce(t *testing.T) {
+	sender := newFakeSender()
+	n := New(testConfig(), sender, nil)
+	defer n.Close()
+
+	for i := 0; i < 5; i++ {
+		require.NoError(t, n.Emit(knockFailed()))
+	}
+	// Hook failures are not knock failures.
+	require.NoError(t, n.Emit(event(internalService.EventError, journald.Fields{"KNOCKER_ERROR_CODE": internalService.ErrorCodeHookFailed})))
+	require.NoError(t, n.Emit(event(internalService.EventKnockTriggered, journald.Fields{"KNOCKER_RESULT": internalService.ResultSuccess})))
+
+	assert.Equal(t, []string{KindKnockFailed, KindFailureCleared}, sender.kinds())
+	assert.Contains(t, sender.sent[0].Body, "3 attempts failed in a row")
+	assert.Equal(t, UrgencyCritical, sender.sent[0].Urgency)
+}
+
+func TestNotifierReportsAPIOutage(t *testing.T) {
+	sender := newFakeSender()
+	cfg := testConfig()
+	cfg.FailureThreshold = 10
+	n := New(cfg, sender, nil)
+	defer n.Close()
+
+	require.NoError(t, n.Emit(knockFailed()))
+	require.NoError(t, n.Emit(event(internalService.EventAPIUnavailable, journald.Fields{
+		"KNOCKER_CONTEXT":   "https://knocker.example.com",
+		"KNOCKER_ERROR_MSG": "connection refused",
+	})))
+	require.NoError(t, n.Emit(event(internalService.EventKnockTriggered, journald.Fields{"KNOCKER_RESULT": internalService.ResultSuccess})))
+
+	assert.Equal(t, []string{KindKnockFailed, KindFailureCleared}, sender.kinds())
+	assert.Equal(t, "Knocker API unavailable", sender.sent[0].Summary)
+	assert.Contains(t, sender.sent[0].Body, "https://knocker.example.com is not answering")
+}
+
+func TestNotifierRateLimitsEachKind(t *testing.T) {
+	sender := newFakeSender()
+	n := New(testConfig(), sender, nil)
+	defer n.Close()
+	now := time.Now()
+	n.now = func() time.Time { return now }
+
+	expired := event(internalService.EventWhitelistExpired, journald.Fields{"KNOCKER_WHITELIST_IP": "1.2.3.4"})
+	require.NoError(t, n.Emit(expired))
+	require.NoError(t, n.Emit(expired))
+	now = now.Add(16 * time.Minute)
+	require.NoError(t, n.Emit(expired))
+
+	assert.Equal(t, []string{KindExpired, KindExpired}, sender.kinds())
+	assert.Contains(t, sender.sent[0].Body, "1.2.3.4")
+}
+
+func TestNotifierWarnsBeforeExpiry(t *testing.T) {
+	sender := newFakeSender()
+	n := New(testConfig(), sender, nil)
+	defer n.Close()
+
+	expires := time.Now().Add(time.Minute).Unix()
+	require.NoError(t, n.Emit(event(internalService.EventStatusSnapshot, journald.Fields{
+		"KNOCKER_WHITELIST_IP": "1.2.3.4",
+		"KNOCKER_EXPIRES_UNIX": strconv.FormatInt(expires, 10),
+	})))
+
+	select {
+	case notification := <-sender.ch:
+		assert.Equal(t, KindExpiresSoon, notification.Kind)
+		assert.Contains(t, notification.Body, "The whitelist for 1.2.3.4 expires at")
+	case <-time.After(5 * time.Second):
+		t.Fatal("no expiry warning sent")
+	}
+}
+
+func TestNotifierWaitsForScheduledKnockBeforeWarning(t *testing.T) {
+	sender := newFakeSender()
+	n := New(testConfig(), sender, nil)
+	defer n.Close()
+
+	now := time.Now()
+	snapshot := event(internalService.EventStatusSnapshot, journald.Fields{
+		"KNOCKER_EXPIRES_UNIX": strconv.FormatInt(now.Add(time.Hour).Unix(), 10),
+		"KNOCKER_NEXT_AT_UNIX": strconv.FormatInt(now.Add(54*time.Minute).Unix(), 10),
+	})
+	require.NoError(t, n.Emit(snapshot))
+	require.NotNil(t, n.warning)
+
+	// A knock due within the grace period of the expiry leaves no room to warn.
+	require.NoError(t, n.Emit(event(internalService.EventNextKnockUpdated, journald.Fields{
+		"KNOCKER_NEXT_AT_UNIX": strconv.FormatInt(now.Add(time.Hour-10*time.Second).Unix(), 10),
+	})))
+	assert.Nil(t, n.warning)
+
+	// An expired or missing whitelist cancels the warning.
+	require.NoError(t, n.Emit(snapshot))
+	require.NoError(t, n.Emit(event(internalService.EventStatusSnapshot, journald.Fields{})))
+	assert.Nil(t, n.warning)
+	assert.Empty(t, sender.kinds())
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/FarisZR/knocker-cli/internal/api"
+	"github.com/FarisZR/knocker-cli/internal/journald"
+)
+
+// breaker returns the circuit breaker guarding the API client, if any.
+func (s *Service) breaker() *api.Breaker {
+	if s.APIClient == nil {
+		return nil
+	}
+	return s.APIClient.Breaker
+}
+
+// apiBlocked reports whether the breaker is open at now and, if so, when it
+// lets the next probe through.
+func (s *Service) apiBlocked(now time.Time) (time.Time, bool) {
+	snap := s.breaker().Snapshot()
+	return snap.RetryAt, snap.State == api.BreakerOpen && now.Before(snap.RetryAt)
+}
+
+// observeBreaker compares the breaker with the state seen after the previous
+// cycle. Opening emits ApiUnavailable and closing again ApiRecovered, so an
+// outage is reported once rather than by an Error per failed request; any
+// change, including a new probe time, is published with a status snapshot.
+func (s *Service) observeBreaker() {
+	snap := s.breaker().Snapshot()
+	state := snap.State
+	if state == "" {
+		return
+	}
+	if state == api.BreakerHalfOpen {
+		// A probe that has not completed; the API is still considered down.
+		state = api.BreakerOpen
+	}
+
+	prev, prevRetry := s.apiState, s.apiRetryAt
+	s.apiState, s.apiRetryAt = state, snap.RetryAt
+	if prev == "" && state == api.BreakerClosed {
+		return
+	}
+	if state == prev && snap.RetryAt.Equal(prevRetry) {
+		return
+	}
+
+	switch {
+	case state == api.BreakerOpen && prev != api.BreakerOpen:
+		s.apiDownSince = time.Now()
+		s.Logger.Warn("API unavailable; backing off", "failures", snap.Failures, "retry_at", snap.RetryAt, "context", s.APIClient.BaseURL)
+		s.emitAPIUnavailable(snap)
+	case state == api.BreakerClosed:
+		downtime := time.Since(s.apiDownSince)
+		s.Logger.Info("API recovered", "downtime", downtime.Round(time.Second), "context", s.APIClient.BaseURL)
+		s.emitAPIRecovered(downtime)
+	}
+	s.emitStatusSnapshot()
+}
+
+// emitAPIError emits an Error for a failed API request, unless the outage
+// has already been reported by ApiUnavailable.
+func (s *Service) emitAPIError(code, msg, context string) {
+	if s.apiState == api.BreakerOpen {
+		return
+	}
+	s.emitError(code, msg, context)
+}
+
+func (s *Service) emitAPIUnavailable(snap api.BreakerSnapshot) {
+	fields := journald.Fields{
+		"KNOCKER_CONTEXT":  s.APIClient.BaseURL,
+		"KNOCKER_FAILURES": strconv.Itoa(snap.Failures),
+	}
+	if snap.LastError != nil {
+		fields["KNOCKER_ERROR_MSG"] = snap.LastError.Error()
+	}
+	if !snap.RetryAt.IsZero() {
+		fields["KNOCKER_RETRY_AT_UNIX"] = strconv.FormatInt(snap.RetryAt.Unix(), 10)
+	}
+
+	message := fmt.Sprintf("Knocker API unavailable after %d failed requests", snap.Failures)
+	s.emit(EventAPIUnavailable, message, journald.PriErr, fields)
+}
+
+func (s *Service) emitAPIRecovered(downtime time.Duration) {
+	fields := journald.Fields{
+		"KNOCKER_CONTEXT":      s.APIClient.BaseURL,
+		"KNOCKER_DOWNTIME_SEC": strconv.FormatInt(int64(downtime.Seconds()), 10),
+	}
+
+	message := fmt.Sprintf("Knocker API recovered after %s", downtime.Round(time.Second))
+	s.emit(EventAPIRecovered, message, journald.PriNotice, fields)
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/FarisZR/knocker-cli/internal/api"
+	"github.com/FarisZR/knocker-cli/internal/events"
+	"github.com/FarisZR/knocker-cli/internal/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServiceReportsAPIOutageOnce(t *testing.T) {
+	var up atomic.Bool
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if !up.Load() {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		json.NewEncoder(w).Encode(api.KnockResponse{
+			WhitelistedEntry: "1.2.3.4",
+			ExpiresAt:        time.Now().Add(time.Hour).Unix(),
+			ExpiresInSeconds: 3600,
+		})
+	}))
+	defer server.Close()
+
+	client := api.NewClient(server.URL, "test-key")
+	client.Breaker = api.NewBreaker(2, 20*time.Millisecond, time.Second)
+	service := NewService(client, &mockIPGetter{}, time.Hour, "", 3600, "ttl", "test", logging.Discard())
+	sink := &recordingSink{}
+	// The default v1 schema keeps the breaker fields.
+	service.Sink = events.NewSchemaSink(sink, events.DefaultSchema)
+
+	service.checkAndKnock()
+	service.checkAndKnock()
+	assert.Len(t, sink.ofType(EventError), 2)
+	unavailable := sink.ofType(EventAPIUnavailable)
+	require.Len(t, unavailable, 1)
+	assert.Equal(t, "2", unavailable[0].Fields["KNOCKER_FAILURES"])
+	assert.Equal(t, server.URL, unavailable[0].Fields["KNOCKER_CONTEXT"])
+	assert.Contains(t, unavailable[0].Fields["KNOCKER_ERROR_MSG"], "502")
+
+	st := service.Status()
+	assert.Equal(t, string(api.BreakerOpen), st.APIState)
+	assert.NotZero(t, st.APIRetryAtUnix)
+	snapshots := sink.ofType(EventStatusSnapshot)
+	assert.Equal(t, "open", snapshots[len(snapshots)-1].Fields["KNOCKER_API_STATE"])
+
+	// While open, cycles skip the API and the schedule waits for the probe.
+	now := time.Now()
+	retryAt := client.Breaker.Snapshot().RetryAt
+	assert.Equal(t, retryAt, service.nextKnockAt(now))
+	service.checkAndKnock()
+	assert.Equal(t, int32(2), requests.Load())
+
+	// A failed probe is not reported as another error.
+	time.Sleep(30 * time.Millisecond)
+	service.checkAndKnock()
+	assert.Equal(t, int32(3), requests.Load())
+	assert.Len(t, sink.ofType(EventError), 2)
+	assert.Len(t, sink.ofType(EventAPIUnavailable), 1)
+
+	up.Store(true)
+	time.Sleep(60 * time.Millisecond)
+	service.checkAndKnock()
+	recovered := sink.ofType(EventAPIRecovered)
+	require.Len(t, recovered, 1)
+	assert.Equal(t, server.URL, recovered[0].Fields["KNOCKER_CONTEXT"])
+	assert.Equal(t, string(api.BreakerClosed), service.Status().APIState)
+	assert.Zero(t, service.Status().APIRetryAtUnix)
+	assert.True(t, service.Status().Whitelisted)
+}
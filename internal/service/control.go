@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrNotRunning is returned by the control methods when Run is not
+// processing requests.
+var ErrNotRunning = errors.New("service is not running")
+
+type controlOp int
+
+const (
+	controlKnock controlOp = iota
+	controlPause
+	controlResume
+)
+
+type controlRequest struct {
+	op   controlOp
+	done chan error
+}
+
+// Knock asks the running service to knock now and waits for the result. It
+// knocks even when paused or when comparison mode sees an unchanged IP.
+func (s *Service) Knock(ctx context.Context) error {
+	return s.control(ctx, controlKnock)
+}
+
+// Pause stops scheduled knocks until Resume is called. Whitelist expiry is
+// still tracked while paused.
+func (s *Service) Pause(ctx context.Context) error {
+	return s.control(ctx, controlPause)
+}
+
+// Resume restarts scheduled knocks, knocking immediately.
+func (s *Service) Resume(ctx context.Context) error {
+	return s.control(ctx, controlResume)
+}
+
+// control hands op to the Run loop, which owns the service state, and waits
+// for it to be handled.
+func (s *Service) control(ctx context.Context, op controlOp) error {
+	req := controlRequest{op: op, done: make(chan error, 1)}
+	select {
+	case s.requests <- req:
+	case <-s.stop:
+		return ErrNotRunning
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case err := <-req.done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// handleControl runs req on the Run loop. It reports whether the knock
+// schedule must be restarted.
+func (s *Service) handleControl(req controlRequest) (reschedule bool) {
+	var err error
+	switch req.op {
+	case controlKnock:
+		err = s.runKnockCycle(TriggerSourceExternal, true)
+		reschedule = true
+	case controlPause:
+		if !s.paused {
+			s.paused = true
+			s.Logger.Info("Scheduled knocks paused")
+			s.setState(ServiceStatePaused)
+			s.clearNextKnock()
+		}
+	case controlResume:
+		if s.paused {
+			s.paused = false
+			s.Logger.Info("Scheduled knocks resumed")
+			s.setState(ServiceStateResumed)
+			s.checkAndKnock()
+			reschedule = true
+		}
+	}
+	req.done <- err
+	return reschedule
+}
+
+// setState records the lifecycle state, emits a ServiceState event and
+// publishes the new state. Resuming returns the service to "started". Unlike
+// the rest of the status, the state may be set from other goroutines (see
+// NotifyStopping).
+func (s *Service) setState(state string) {
+	current := state
+	if state == ServiceStateResumed {
+		current = ServiceStateStarted
+	}
+	s.statusMu.Lock()
+	s.state = current
+	s.status.State = current
+	st := s.status
+	s.statusMu.Unlock()
+
+	s.emitServiceState(state)
+	s.notifyStatus(st)
+}
+
+// scheduleNext works out when the Run loop wakes next and returns the wait.
+// Unless paused, the time is published as the next scheduled knock.
+func (s *Service) scheduleNext(now time.Time) time.Duration {
+	next := s.nextKnockAt(now)
+	if !s.paused {
+		s.updateNextKnock(next)
+	}
+	return next.Sub(now)
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/FarisZR/knocker-cli/internal/api"
+	"github.com/FarisZR/knocker-cli/internal/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServiceControlPauseResumeAndKnock(t *testing.T) {
+	var knocks atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/knock" {
+			return
+		}
+		knocks.Add(1)
+		json.NewEncoder(w).Encode(api.KnockResponse{
+			WhitelistedEntry: "1.2.3.4",
+			ExpiresAt:        time.Now().Add(time.Hour).Unix(),
+			ExpiresInSeconds: 3600,
+		})
+	}))
+	defer server.Close()
+
+	logger := logging.Discard()
+	service := NewService(api.NewClient(server.URL, "test-key"), &mockIPGetter{}, time.Hour, server.URL, 3600, "check_interval", "test", logger)
+	sink := &recordingSink{}
+	service.Sink = sink
+
+	var mu sync.Mutex
+	var states []string
+	service.OnStatusChange = func(st Status) {
+		mu.Lock()
+		defer mu.Unlock()
+		if len(states) == 0 || states[len(states)-1] != st.State {
+			states = append(states, st.State)
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		service.Run(nil)
+		close(done)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	require.NoError(t, service.Pause(ctx))
+	assert.Equal(t, ServiceStatePaused, service.Status().State)
+	assert.Zero(t, service.Status().NextKnockUnix)
+	assert.Equal(t, int32(1), knocks.Load())
+
+	// Knock ignores both the pause and the unchanged IP.
+	require.NoError(t, service.Knock(ctx))
+	assert.Equal(t, int32(2), knocks.Load())
+	triggered := sink.ofType(EventKnockTriggered)
+	assert.Equal(t, TriggerSourceExternal, triggered[len(triggered)-1].Fields["KNOCKER_TRIGGER_SOURCE"])
+
+	require.NoError(t, service.Resume(ctx))
+	assert.Equal(t, ServiceStateStarted, service.Status().State)
+	assert.NotZero(t, service.Status().NextKnockUnix)
+
+	service.Stop()
+	<-done
+	assert.ErrorIs(t, service.Pause(ctx), ErrNotRunning)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{ServiceStateStarted, ServiceStatePaused, ServiceStateStarted, ServiceStateStopping, ServiceStateStopped}, states)
+}
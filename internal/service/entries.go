@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/FarisZR/knocker-cli/internal/api"
+)
+
+// ExtrasError reports that the caller's address was whitelisted but the
+// follow-up request for the extra entries failed.
+type ExtrasError struct {
+	Extras []string
+	Err    error
+}
+
+func (e *ExtrasError) Error() string {
+	return fmt.Sprintf("whitelisting extra entries %s failed: %v", strings.Join(e.Extras, ", "), e.Err)
+}
+
+func (e *ExtrasError) Unwrap() error {
+	return e.Err
+}
+
+// KnockWithExtras whitelists ip together with the configured extra entries
+// (addresses or CIDR ranges). When ip is known it is sent with the extras in a
+// single request. Otherwise only the server can see the caller's address, so
+// a plain knock is sent first and the extras follow in a second request. The
+// returned response lists every whitelisted entry, with WhitelistedEntry set
+// to the primary (caller's) entry. If only that second request fails, the
+// response of the first is returned together with an *ExtrasError, since
+// the caller's address was whitelisted.
+func KnockWithExtras(ctx context.Context, client *api.Client, ip string, extras []string, ttl int) (*api.KnockResponse, error) {
+	if len(extras) == 0 {
+		return client.KnockContext(ctx, ip, ttl)
+	}
+
+	if ip != "" {
+		knockResponse, err := client.KnockEntriesContext(ctx, append([]string{ip}, extras...), ttl)
+		if err != nil {
+			return nil, err
+		}
+		if knockResponse.WhitelistedEntry == "" {
+			knockResponse.WhitelistedEntry = ip
+		}
+		return knockResponse, nil
+	}
+
+	primary, err := client.KnockContext(ctx, "", ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	extra, err := client.KnockEntriesContext(ctx, extras, ttl)
+	if err != nil {
+		return primary, &ExtrasError{Extras: extras, Err: err}
+	}
+
+	return mergeKnockResponses(primary, extra), nil
+}
+
+// mergeKnockResponses combines two responses, keeping the earliest expiry so
+// the refresh schedule stays ahead of every entry.
+func mergeKnockResponses(primary, extra *api.KnockResponse) *api.KnockResponse {
+	merged := &api.KnockResponse{
+		WhitelistedEntry: primary.WhitelistedEntry,
+		ExpiresAt:        primary.ExpiresAt,
+		ExpiresInSeconds: primary.ExpiresInSeconds,
+	}
+	merged.WhitelistedEntries = append(append([]string{}, primary.Entries()...), extra.Entries()...)
+
+	if extra.ExpiresAt > 0 && (merged.ExpiresAt <= 0 || extra.ExpiresAt < merged.ExpiresAt) {
+		merged.ExpiresAt = extra.ExpiresAt
+	}
+	if extra.ExpiresInSeconds > 0 && (merged.ExpiresInSeconds <= 0 || extra.ExpiresInSeconds < merged.ExpiresInSeconds) {
+		merged.ExpiresInSeconds = extra.ExpiresInSeconds
+	}
+
+	return merged
+}
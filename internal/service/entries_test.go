@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/FarisZR/knocker-cli/internal/api"
+	"github.com/FarisZR/knocker-cli/internal/logging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newEntriesServer(t *testing.T, requests *[]map[string]interface{}) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		*requests = append(*requests, body)
+
+		response := api.KnockResponse{ExpiresAt: time.Now().Add(time.Hour).Unix(), ExpiresInSeconds: 3600}
+		switch {
+		case body["ip_addresses"] != nil:
+			for _, entry := range body["ip_addresses"].([]interface{}) {
+				response.WhitelistedEntries = append(response.WhitelistedEntries, entry.(string))
+			}
+		case body["ip_address"] != nil:
+			response.WhitelistedEntry = body["ip_address"].(string)
+		default:
+			response.WhitelistedEntry = "198.51.100.1"
+			response.ExpiresInSeconds = 600
+		}
+		json.NewEncoder(w).Encode(response)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestKnockWithExtrasSendsSingleRequestWhenIPKnown(t *testing.T) {
+	var requests []map[string]interface{}
+	server := newEntriesServer(t, &requests)
+
+	knockResponse, err := KnockWithExtras(context.Background(), api.NewClient(server.URL, "test-key"), "1.2.3.4", []string{"203.0.113.0/29"}, 0)
+
+	assert.NoError(t, err)
+	assert.Len(t, requests, 1)
+	assert.Equal(t, "1.2.3.4", knockResponse.WhitelistedEntry)
+	assert.Equal(t, []string{"1.2.3.4", "203.0.113.0/29"}, knockResponse.Entries())
+}
+
+func TestKnockWithExtrasKnocksCallerThenExtras(t *testing.T) {
+	var requests []map[string]interface{}
+	server := newEntriesServer(t, &requests)
+
+	knockResponse, err := KnockWithExtras(context.Background(), api.NewClient(server.URL, "test-key"), "", []string{"203.0.113.0/29"}, 0)
+
+	assert.NoError(t, err)
+	assert.Len(t, requests, 2)
+	assert.Equal(t, "198.51.100.1", knockResponse.WhitelistedEntry)
+	assert.Equal(t, []string{"198.51.100.1", "203.0.113.0/29"}, knockResponse.Entries())
+	assert.Equal(t, 600, knockResponse.ExpiresInSeconds)
+}
+
+func TestKnockWithExtrasKeepsPrimaryWhenExtrasFail(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["ip_address"] != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		json.NewEncoder(w).Encode(api.KnockResponse{WhitelistedEntry: "198.51.100.1", ExpiresAt: time.Now().Add(time.Hour).Unix(), ExpiresInSeconds: 3600})
+	}))
+	defer server.Close()
+
+	knockResponse, err := KnockWithExtras(context.Background(), api.NewClient(server.URL, "test-key"), "", []string{"203.0.113.0/29"}, 0)
+
+	var extrasErr *ExtrasError
+	assert.ErrorAs(t, err, &extrasErr)
+	assert.Equal(t, []string{"203.0.113.0/29"}, extrasErr.Extras)
+	assert.Equal(t, []string{"198.51.100.1"}, knockResponse.Entries())
+}
+
+func TestServiceTracksPrimaryAndRetriesWhenExtrasFail(t *testing.T) {
+	var extrasUp atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		response := api.KnockResponse{ExpiresAt: time.Now().Add(time.Hour).Unix(), ExpiresInSeconds: 3600}
+		if body["ip_address"] != nil {
+			if !extrasUp.Load() {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			response.WhitelistedEntries = []string{"203.0.113.0/29"}
+		} else {
+			response.WhitelistedEntry = "198.51.100.1"
+		}
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer server.Close()
+
+	service := NewService(api.NewClient(server.URL, "test-key"), &mockIPGetter{}, time.Hour, "", 3600, "ttl", "test", logging.Discard())
+	service.ExtraEntries = []string{"203.0.113.0/29"}
+	sink := &recordingSink{}
+	service.Sink = sink
+
+	service.checkAndKnock()
+	assert.Zero(t, service.failures, "the caller's address was whitelisted")
+	assert.Equal(t, 1, service.extrasFailures)
+	assert.Equal(t, "198.51.100.1", service.Status().WhitelistIP)
+	assert.Len(t, service.whitelists, 1)
+	require.Len(t, sink.ofType(EventKnockTriggered), 1)
+	assert.Equal(t, ResultSuccess, sink.ofType(EventKnockTriggered)[0].Fields["KNOCKER_RESULT"])
+	errorEvents := sink.ofType(EventError)
+	require.Len(t, errorEvents, 1)
+	assert.Equal(t, "203.0.113.0/29", errorEvents[0].Fields["KNOCKER_CONTEXT"])
+
+	// The extras are retried well before the refresh.
+	now := time.Now()
+	assert.Equal(t, now.Add(failureRetryBase), service.nextKnockAt(now))
+
+	extrasUp.Store(true)
+	service.checkAndKnock()
+	assert.Zero(t, service.extrasFailures)
+	assert.ElementsMatch(t, []string{"198.51.100.1", "203.0.113.0/29"}, service.Status().WhitelistIPs)
+}
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/FarisZR/knocker-cli/internal/events"
+)
+
+// ServiceStateData is the typed form of a ServiceState event.
+type ServiceStateData struct {
+	State   string `json:"state"`
+	Version string `json:"version,omitempty"`
+}
+
+// StatusSnapshotData is the typed form of a StatusSnapshot event.
+type StatusSnapshotData struct {
+	WhitelistIP   string     `json:"whitelist_ip,omitempty"`
+	WhitelistIPs  []string   `json:"whitelist_ips,omitempty"`
+	ExpiresAt     *time.Time `json:"expires_at,omitempty"`
+	TTLSeconds    int        `json:"ttl_sec,omitempty"`
+	NextKnockAt   *time.Time `json:"next_at,omitempty"`
+	CadenceSource string     `json:"cadence_source,omitempty"`
+	APIState      string     `json:"api_state,omitempty"`
+	APIRetryAt    *time.Time `json:"api_retry_at,omitempty"`
+}
+
+// WhitelistAppliedData is the typed form of a WhitelistApplied event.
+type WhitelistAppliedData struct {
+	IP         string     `json:"ip,omitempty"`
+	TTLSeconds int        `json:"ttl_sec,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	Source     string     `json:"source,omitempty"`
+}
+
+// WhitelistExpiredData is the typed form of a WhitelistExpired event.
+type WhitelistExpiredData struct {
+	IP        string     `json:"ip,omitempty"`
+	ExpiredAt *time.Time `json:"expired_at,omitempty"`
+}
+
+// NextKnockUpdatedData is the typed form of a NextKnockUpdated event. A nil
+// NextKnockAt means the schedule was cleared.
+type NextKnockUpdatedData struct {
+	NextKnockAt   *time.Time `json:"next_at,omitempty"`
+	CadenceSource string     `json:"cadence_source,omitempty"`
+}
+
+// KnockTriggeredData is the typed form of a KnockTriggered event.
+type KnockTriggeredData struct {
+	Source     string `json:"source"`
+	Result     string `json:"result"`
+	IP         string `json:"ip,omitempty"`
+	LatencyMS  *int   `json:"latency_ms,omitempty"`
+	HTTPStatus int    `json:"http_status,omitempty"`
+}
+
+// ErrorData is the typed form of an Error event.
+type ErrorData struct {
+	Code        string `json:"code"`
+	Message     string `json:"message"`
+	Context     string `json:"context,omitempty"`
+	HookCommand string `json:"hook_command,omitempty"`
+}
+
+// APIUnavailableData is the typed form of an ApiUnavailable event.
+type APIUnavailableData struct {
+	Context  string     `json:"context"`
+	Failures int        `json:"failures"`
+	Message  string     `json:"message,omitempty"`
+	RetryAt  *time.Time `json:"retry_at,omitempty"`
+}
+
+// APIRecoveredData is the typed form of an ApiRecovered event.
+type APIRecoveredData struct {
+	Context         string `json:"context"`
+	DowntimeSeconds int    `json:"downtime_sec"`
+}
+
+// DecodeEventData converts the KNOCKER_* fields of event into the typed
+// struct for its type. Unknown event types return their raw fields.
+func DecodeEventData(event events.Event) interface{} {
+	f := event.Fields
+	switch event.Type {
+	case EventServiceState:
+		return ServiceStateData{State: f["KNOCKER_SERVICE_STATE"], Version: f["KNOCKER_VERSION"]}
+	case EventStatusSnapshot:
+		data := StatusSnapshotData{
+			WhitelistIP:   f["KNOCKER_WHITELIST_IP"],
+			ExpiresAt:     unixField(f["KNOCKER_EXPIRES_UNIX"]),
+			TTLSeconds:    intField(f["KNOCKER_TTL_SEC"]),
+			NextKnockAt:   unixField(f["KNOCKER_NEXT_AT_UNIX"]),
+			CadenceSource: f["KNOCKER_CADENCE_SOURCE"],
+			APIState:      f["KNOCKER_API_STATE"],
+			APIRetryAt:    unixField(f["KNOCKER_API_RETRY_AT_UNIX"]),
+		}
+		if encoded := f["KNOCKER_WHITELIST_IPS_JSON"]; encoded != "" {
+			_ = json.Unmarshal([]byte(encoded), &data.WhitelistIPs)
+		}
+		return data
+	case EventWhitelistApplied:
+		return WhitelistAppliedData{
+			IP:         f["KNOCKER_WHITELIST_IP"],
+			TTLSeconds: intField(f["KNOCKER_TTL_SEC"]),
+			ExpiresAt:  unixField(f["KNOCKER_EXPIRES_UNIX"]),
+			Source:     f["KNOCKER_SOURCE"],
+		}
+	case EventWhitelistExpired:
+		return WhitelistExpiredData{IP: f["KNOCKER_WHITELIST_IP"], ExpiredAt: unixField(f["KNOCKER_EXPIRED_UNIX"])}
+	case EventNextKnockUpdated:
+		return NextKnockUpdatedData{NextKnockAt: unixField(f["KNOCKER_NEXT_AT_UNIX"]), CadenceSource: f["KNOCKER_CADENCE_SOURCE"]}
+	case EventKnockTriggered:
+		data := KnockTriggeredData{
+			Source:     f["KNOCKER_TRIGGER_SOURCE"],
+			Result:     f["KNOCKER_RESULT"],
+			IP:         f["KNOCKER_WHITELIST_IP"],
+			HTTPStatus: intField(f["KNOCKER_HTTP_STATUS"]),
+		}
+		if latency, err := strconv.Atoi(f["KNOCKER_LATENCY_MS"]); err == nil {
+			data.LatencyMS = &latency
+		}
+		return data
+	case EventError:
+		return ErrorData{
+			Code:        f["KNOCKER_ERROR_CODE"],
+			Message:     f["KNOCKER_ERROR_MSG"],
+			Context:     f["KNOCKER_CONTEXT"],
+			HookCommand: f["KNOCKER_HOOK_COMMAND"],
+		}
+	case EventAPIUnavailable:
+		return APIUnavailableData{
+			Context:  f["KNOCKER_CONTEXT"],
+			Failures: intField(f["KNOCKER_FAILURES"]),
+			Message:  f["KNOCKER_ERROR_MSG"],
+			RetryAt:  unixField(f["KNOCKER_RETRY_AT_UNIX"]),
+		}
+	case EventAPIRecovered:
+		return APIRecoveredData{Context: f["KNOCKER_CONTEXT"], DowntimeSeconds: intField(f["KNOCKER_DOWNTIME_SEC"])}
+	default:
+		return f
+	}
+}
+
+// unixField parses a KNOCKER_*_UNIX value; absent or zero values are nil.
+func unixField(value string) *time.Time {
+	unix, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || unix <= 0 {
+		return nil
+	}
+	t := time.Unix(unix, 0).UTC()
+	return &t
+}
+
+func intField(value string) int {
+	n, _ := strconv.Atoi(value)
+	return n
+}
@@ -0,0 +1,53 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/FarisZR/knocker-cli/internal/events"
+	"github.com/FarisZR/knocker-cli/internal/journald"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeEventData(t *testing.T) {
+	applied := DecodeEventData(events.New(EventWhitelistApplied, "", journald.PriInfo, journald.Fields{
+		"KNOCKER_WHITELIST_IP": "1.2.3.4",
+		"KNOCKER_TTL_SEC":      "600",
+		"KNOCKER_EXPIRES_UNIX": "1750202500",
+		"KNOCKER_SOURCE":       "schedule",
+	}))
+	if data, ok := applied.(WhitelistAppliedData); assert.True(t, ok) {
+		assert.Equal(t, "1.2.3.4", data.IP)
+		assert.Equal(t, 600, data.TTLSeconds)
+		assert.Equal(t, time.Unix(1750202500, 0).UTC(), *data.ExpiresAt)
+		assert.Equal(t, "schedule", data.Source)
+	}
+
+	snapshot := DecodeEventData(events.New(EventStatusSnapshot, "", journald.PriInfo, journald.Fields{
+		"KNOCKER_WHITELIST_IPS_JSON": `["1.2.3.4","203.0.113.0/29"]`,
+		"KNOCKER_NEXT_AT_UNIX":       "0",
+	}))
+	if data, ok := snapshot.(StatusSnapshotData); assert.True(t, ok) {
+		assert.Equal(t, []string{"1.2.3.4", "203.0.113.0/29"}, data.WhitelistIPs)
+		assert.Nil(t, data.NextKnockAt)
+	}
+
+	failure := DecodeEventData(events.New(EventError, "", journald.PriErr, journald.Fields{
+		"KNOCKER_ERROR_CODE": ErrorCodeKnockFailed,
+		"KNOCKER_ERROR_MSG":  "Knock failed: boom",
+	}))
+	assert.Equal(t, ErrorData{Code: ErrorCodeKnockFailed, Message: "Knock failed: boom"}, failure)
+
+	unavailable := DecodeEventData(events.New(EventAPIUnavailable, "", journald.PriErr, journald.Fields{
+		"KNOCKER_CONTEXT":       "https://knocker.example.com",
+		"KNOCKER_FAILURES":      "3",
+		"KNOCKER_RETRY_AT_UNIX": "1750202500",
+	}))
+	if data, ok := unavailable.(APIUnavailableData); assert.True(t, ok) {
+		assert.Equal(t, 3, data.Failures)
+		assert.Equal(t, time.Unix(1750202500, 0).UTC(), *data.RetryAt)
+	}
+
+	unknown := DecodeEventData(events.New("Custom", "", journald.PriInfo, journald.Fields{"KNOCKER_X": "1"}))
+	assert.Equal(t, journald.Fields{"KNOCKER_X": "1"}, unknown)
+}
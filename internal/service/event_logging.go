@@ -1,11 +1,17 @@
 package service
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
 	"strconv"
 	"time"
 
+	"github.com/FarisZR/knocker-cli/internal/api"
+	"github.com/FarisZR/knocker-cli/internal/events"
 	"github.com/FarisZR/knocker-cli/internal/journald"
+	"github.com/FarisZR/knocker-cli/internal/logging"
 )
 
 const (
@@ -16,29 +22,43 @@ const (
 	EventNextKnockUpdated = "NextKnockUpdated"
 	EventKnockTriggered   = "KnockTriggered"
 	EventError            = "Error"
+	EventAPIUnavailable   = "ApiUnavailable"
+	EventAPIRecovered     = "ApiRecovered"
 )
 
 const (
 	ServiceStateStarted  = "started"
 	ServiceStateStopping = "stopping"
 	ServiceStateStopped  = "stopped"
+	ServiceStatePaused   = "paused"
+	ServiceStateResumed  = "resumed"
 )
 
 const (
 	TriggerSourceCLI      = "cli"
 	TriggerSourceSchedule = "schedule"
 	TriggerSourceExternal = "external"
+	// TriggerSourceExpiry marks the knock sent right after a whitelist
+	// expired.
+	TriggerSourceExpiry = "expiry"
 )
 
 const (
 	ResultSuccess = "success"
 	ResultFailure = "failure"
+	// ResultUnchanged marks a comparison-mode check that found the same IP
+	// and did not knock. It only appears on traces.
+	ResultUnchanged = "unchanged"
+	// ResultSkipped marks a cycle that did not contact the API because the
+	// circuit breaker is open. It only appears on traces.
+	ResultSkipped = "skipped"
 )
 
 const (
 	ErrorCodeIPLookup    = "ip_lookup_failed"
 	ErrorCodeHealthCheck = "health_check_failed"
 	ErrorCodeKnockFailed = "knock_failed"
+	ErrorCodeHookFailed  = "hook_failed"
 )
 
 type whitelistState struct {
@@ -49,8 +69,20 @@ type whitelistState struct {
 }
 
 func (s *Service) emit(eventType, message string, priority journald.Priority, fields journald.Fields) {
-	if err := journald.Emit(eventType, message, priority, fields); err != nil && s.Logger != nil {
-		s.Logger.Printf("Failed to emit journald event %s: %v", eventType, err)
+	if s.Sink == nil {
+		return
+	}
+	if cycle := s.cycle.Load(); cycle != nil {
+		if fields == nil {
+			fields = journald.Fields{}
+		}
+		fields["KNOCKER_CYCLE_ID"] = cycle.ID
+		fields["KNOCKER_ATTEMPT"] = strconv.Itoa(cycle.Attempt)
+	}
+	if err := s.Sink.Emit(events.New(eventType, message, priority, fields)); err != nil && s.Logger != nil {
+		// Keep the event's data in the log when a sink rejects it.
+		attrs := append([]any{"event", eventType, "error", err}, logging.FieldAttrs(fields)...)
+		s.Logger.Warn("Failed to emit event", attrs...)
 	}
 }
 
@@ -63,13 +95,15 @@ func (s *Service) emitServiceState(state string) {
 		fields["KNOCKER_VERSION"] = s.version
 	}
 	priority := journald.PriInfo
-	if state == ServiceStateStopping {
+	if state == ServiceStateStopping || state == ServiceStatePaused {
 		priority = journald.PriNotice
 	}
 	s.emit(EventServiceState, message, priority, fields)
 }
 
 func (s *Service) emitStatusSnapshot() {
+	s.publishStatus()
+
 	fields := journald.Fields{}
 	if s.currentWhitelist != nil {
 		if s.currentWhitelist.IP != "" {
@@ -82,12 +116,31 @@ func (s *Service) emitStatusSnapshot() {
 			fields["KNOCKER_TTL_SEC"] = strconv.Itoa(s.currentWhitelist.TTLSeconds)
 		}
 	}
+	if len(s.whitelists) > 1 {
+		if encoded, err := json.Marshal(s.trackedEntries()); err == nil {
+			fields["KNOCKER_WHITELIST_IPS_JSON"] = string(encoded)
+		}
+	}
 	if s.nextKnockUnix > 0 {
 		fields["KNOCKER_NEXT_AT_UNIX"] = strconv.FormatInt(s.nextKnockUnix, 10)
 	}
 	if s.cadenceSrc != "" {
 		fields["KNOCKER_CADENCE_SOURCE"] = s.cadenceSrc
 	}
+	if s.apiState != "" {
+		fields["KNOCKER_API_STATE"] = string(s.apiState)
+	}
+	if s.apiState == api.BreakerOpen && !s.apiRetryAt.IsZero() {
+		fields["KNOCKER_API_RETRY_AT_UNIX"] = strconv.FormatInt(s.apiRetryAt.Unix(), 10)
+	}
+
+	var expiresUnix int64
+	if s.currentWhitelist != nil {
+		expiresUnix = s.currentWhitelist.ExpiresUnix
+	}
+	s.Metrics.SetWhitelistExpiry(expiresUnix)
+	s.Metrics.SetCadence(s.Cadence)
+
 	s.emit(EventStatusSnapshot, "Status snapshot", journald.PriInfo, fields)
 }
 
@@ -155,14 +208,12 @@ func (s *Service) emitNextKnockUpdated(next time.Time) {
 		fields["KNOCKER_CADENCE_SOURCE"] = s.cadenceSrc
 	}
 
+	s.Metrics.SetNextKnock(next)
 	s.emit(EventNextKnockUpdated, message, journald.PriInfo, fields)
 }
 
-func (s *Service) emitKnockTriggered(source, result, ip string) {
-	fields := journald.Fields{
-		"KNOCKER_TRIGGER_SOURCE": source,
-		"KNOCKER_RESULT":         result,
-	}
+func (s *Service) emitKnockTriggered(source, result, ip string, latency time.Duration, err error) {
+	fields := KnockFields(source, result, latency, err)
 	if ip != "" {
 		fields["KNOCKER_WHITELIST_IP"] = ip
 	}
@@ -172,10 +223,41 @@ func (s *Service) emitKnockTriggered(source, result, ip string) {
 		priority = journald.PriErr
 	}
 
+	s.Metrics.KnockTriggered(source, result)
+
 	message := fmt.Sprintf("Knock triggered via %s: %s", source, result)
 	s.emit(EventKnockTriggered, message, priority, fields)
 }
 
+// KnockFields returns the KnockTriggered fields describing a knock request:
+// its source and result, how long it took and the HTTP status the API
+// answered with, when known.
+func KnockFields(source, result string, latency time.Duration, err error) journald.Fields {
+	fields := journald.Fields{
+		"KNOCKER_TRIGGER_SOURCE": source,
+		"KNOCKER_RESULT":         result,
+		"KNOCKER_LATENCY_MS":     strconv.FormatInt(latency.Milliseconds(), 10),
+	}
+	if status := KnockHTTPStatus(err); status > 0 {
+		fields["KNOCKER_HTTP_STATUS"] = strconv.Itoa(status)
+	}
+	return fields
+}
+
+// KnockHTTPStatus returns the HTTP status of a knock that ended with err: 200
+// on success, the API's status for a rejected request, and 0 when no response
+// was received.
+func KnockHTTPStatus(err error) int {
+	if err == nil {
+		return http.StatusOK
+	}
+	var statusErr *api.StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode
+	}
+	return 0
+}
+
 func (s *Service) emitError(code, msg, context string) {
 	fields := journald.Fields{
 		"KNOCKER_ERROR_CODE": code,
@@ -199,6 +281,7 @@ func (s *Service) updateNextKnock(next time.Time) {
 	}
 
 	s.nextKnockUnix = unix
+	s.publishStatus()
 	s.emitNextKnockUpdated(next)
 }
 
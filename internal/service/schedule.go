@@ -0,0 +1,146 @@
+package service
+
+import (
+	"hash/fnv"
+	"math/rand/v2"
+	"time"
+)
+
+const (
+	// minScheduleDelay is the shortest wait derived from a whitelist's
+	// expiry, so a refresh time already in the past does not spin the loop.
+	minScheduleDelay = time.Second
+	// failureRetryBase is the wait after the first failed cycle; it doubles
+	// with every further failure, up to the cadence.
+	failureRetryBase = 5 * time.Second
+	// maxRetryShift caps the doubling of failureRetryBase.
+	maxRetryShift = 10
+)
+
+// nextKnockAt returns when the Run loop wakes next, given that the last cycle
+// ended at now.
+//
+// The cadence is the fallback. When a whitelist is tracked, the refresh is
+// aimed at its server-reported expiry with 10% of the TTL (or RefreshMargin)
+// to spare, see refreshAt: in simple mode that replaces the cadence, in
+// comparison mode the earlier of the two wins so the whitelist is refreshed
+// even when the IP never changes. After a failed cycle, or a knock whose
+// extra entries were not whitelisted, the service retries sooner, backing
+// off exponentially but always within half of the time the whitelist has
+// left, unless the circuit breaker is open: then the next probe time set by
+// the breaker's back-off applies. Jitter then delays the knock, though never
+// past the midpoint between the refresh time and the expiry.
+func (s *Service) nextKnockAt(now time.Time) time.Time {
+	next := now.Add(s.Cadence)
+	if s.paused {
+		return next
+	}
+
+	refresh, tracked := s.refreshAt()
+	if retryAt, blocked := s.apiBlocked(now); blocked {
+		next = retryAt
+	} else if s.failures > 0 {
+		next = now.Add(s.retryDelay(now, s.failures))
+	} else if s.extrasFailures > 0 {
+		next = now.Add(s.retryDelay(now, s.extrasFailures))
+	} else if tracked && (s.ipCheckURL == "" || refresh.Before(next)) {
+		next = refresh
+	}
+
+	if offset := s.jitterOffset(); offset > 0 {
+		jittered := next.Add(offset)
+		if expiry, ok := s.earliestExpiry(); tracked && ok {
+			if deadline := refresh.Add(expiry.Sub(refresh) / 2); jittered.After(deadline) {
+				jittered = deadline
+			}
+		}
+		if jittered.After(next) {
+			next = jittered
+		}
+	}
+
+	if earliest := now.Add(minScheduleDelay); tracked && next.Before(earliest) {
+		next = earliest
+	}
+	return next
+}
+
+// refreshAt returns when the tracked whitelists should be refreshed: with the
+// larger of 10% of the TTL and RefreshMargin, and at least minScheduleDelay,
+// left before the earliest of them expires. The margin is capped at half the
+// TTL so a short TTL does not cause back-to-back knocks. It reports false
+// when no expiry is tracked.
+func (s *Service) refreshAt() (time.Time, bool) {
+	var (
+		refresh time.Time
+		tracked bool
+	)
+	for _, state := range s.whitelists {
+		if state.ExpiresUnix <= 0 {
+			continue
+		}
+		ttl := time.Duration(state.TTLSeconds) * time.Second
+		margin := max(ttl/10, s.RefreshMargin)
+		if ttl > 0 {
+			margin = min(margin, ttl/2)
+		}
+		margin = max(margin, minScheduleDelay)
+		at := time.Unix(state.ExpiresUnix, 0).Add(-margin)
+		if !tracked || at.Before(refresh) {
+			refresh, tracked = at, true
+		}
+	}
+	return refresh, tracked
+}
+
+// retryDelay returns the wait before retrying after the given number of
+// consecutive failures.
+func (s *Service) retryDelay(now time.Time, failures int) time.Duration {
+	delay := min(failureRetryBase<<min(failures-1, maxRetryShift), s.Cadence)
+	if expiry, ok := s.earliestExpiry(); ok {
+		if left := expiry.Sub(now); left > 0 {
+			delay = min(delay, left/2)
+		}
+	}
+	return delay
+}
+
+// earliestExpiry returns the expiry of the first tracked whitelist to lapse.
+func (s *Service) earliestExpiry() (time.Time, bool) {
+	var (
+		earliest time.Time
+		tracked  bool
+	)
+	for _, state := range s.whitelists {
+		if state.ExpiresUnix <= 0 {
+			continue
+		}
+		at := time.Unix(state.ExpiresUnix, 0)
+		if !tracked || at.Before(earliest) {
+			earliest, tracked = at, true
+		}
+	}
+	return earliest, tracked
+}
+
+// refreshDue reports whether the tracked whitelists are within their refresh
+// margin at now, so comparison mode knocks even though the IP is unchanged.
+func (s *Service) refreshDue(now time.Time) bool {
+	refresh, tracked := s.refreshAt()
+	return tracked && !now.Before(refresh)
+}
+
+// jitterOffset returns the delay added to a scheduled knock: up to Jitter,
+// either random or, with JitterSeed set, fixed by hashing the seed so each
+// host keeps its own slot.
+func (s *Service) jitterOffset() time.Duration {
+	if s.Jitter <= 0 {
+		return 0
+	}
+	if s.JitterSeed != "" {
+		h := fnv.New64a()
+		h.Write([]byte(s.JitterSeed))
+		return time.Duration(h.Sum64() % uint64(s.Jitter))
+	}
+	return rand.N(s.Jitter)
+}
@@ -0,0 +1,233 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/FarisZR/knocker-cli/internal/api"
+	"github.com/FarisZR/knocker-cli/internal/logging"
+	"github.com/stretchr/testify/assert"
+)
+
+func scheduledService(ipCheckURL string, cadence time.Duration, expires time.Time, ttlSeconds int) *Service {
+	s := NewService(nil, &mockIPGetter{}, cadence, ipCheckURL, ttlSeconds, "ttl", "test", logging.Discard())
+	s.whitelists = map[string]*whitelistState{
+		"1.2.3.4": {IP: "1.2.3.4", ExpiresUnix: expires.Unix(), TTLSeconds: ttlSeconds},
+	}
+	return s
+}
+
+func TestNextKnockAtFollowsServerExpiryInSimpleMode(t *testing.T) {
+	now := time.Unix(1_750_000_000, 0)
+
+	// The server's expiry wins over the local cadence, even when it is later.
+	s := scheduledService("", 5*time.Minute, now.Add(10*time.Minute), 600)
+	assert.Equal(t, now.Add(9*time.Minute), s.nextKnockAt(now))
+
+	// An expiry sooner than the TTL suggests (clock skew, a slow response)
+	// still leaves 10% of the TTL to spare.
+	s = scheduledService("", 9*time.Minute, now.Add(100*time.Second), 600)
+	assert.Equal(t, now.Add(40*time.Second), s.nextKnockAt(now))
+
+	// Without a tracked whitelist the cadence applies.
+	s.whitelists = nil
+	assert.Equal(t, now.Add(9*time.Minute), s.nextKnockAt(now))
+}
+
+func TestNextKnockAtRefreshesBeforeExpiryInComparisonMode(t *testing.T) {
+	now := time.Unix(1_750_000_000, 0)
+
+	s := scheduledService("https://ip.example", 5*time.Minute, now.Add(2*time.Minute), 120)
+	assert.Equal(t, now.Add(108*time.Second), s.nextKnockAt(now))
+	assert.False(t, s.refreshDue(now))
+	assert.True(t, s.refreshDue(now.Add(108*time.Second)))
+
+	s = scheduledService("https://ip.example", 5*time.Minute, now.Add(time.Hour), 3600)
+	assert.Equal(t, now.Add(5*time.Minute), s.nextKnockAt(now))
+	assert.False(t, s.refreshDue(now.Add(5*time.Minute)))
+}
+
+func TestNextKnockAtRetriesSoonerAfterFailures(t *testing.T) {
+	now := time.Unix(1_750_000_000, 0)
+	s := scheduledService("", 9*time.Minute, now.Add(10*time.Minute), 600)
+
+	s.failures = 1
+	assert.Equal(t, now.Add(5*time.Second), s.nextKnockAt(now))
+	s.failures = 3
+	assert.Equal(t, now.Add(20*time.Second), s.nextKnockAt(now))
+	s.failures = 40
+	assert.Equal(t, now.Add(5*time.Minute), s.nextKnockAt(now), "half of the time left before expiry")
+
+	// A failure at the refresh point still retries well before expiry.
+	s = scheduledService("", 9*time.Minute, now.Add(30*time.Second), 600)
+	s.failures = 4
+	assert.Equal(t, now.Add(15*time.Second), s.nextKnockAt(now))
+	s = scheduledService("", 9*time.Minute, now.Add(time.Second), 600)
+	s.failures = 4
+	assert.Equal(t, now.Add(time.Second), s.nextKnockAt(now), "never sooner than a second")
+
+	// The back-off never exceeds the cadence.
+	s.whitelists = nil
+	s.failures = maxRetryShift + 5
+	assert.Equal(t, now.Add(9*time.Minute), s.nextKnockAt(now))
+}
+
+func TestNextKnockAtUsesCadenceWhilePaused(t *testing.T) {
+	now := time.Unix(1_750_000_000, 0)
+	s := scheduledService("", 9*time.Minute, now.Add(time.Minute), 600)
+	s.paused = true
+	assert.Equal(t, now.Add(9*time.Minute), s.nextKnockAt(now))
+}
+
+func TestServiceRefreshesUnchangedIPBeforeExpiry(t *testing.T) {
+	var knocks atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/knock" {
+			return
+		}
+		knocks.Add(1)
+		json.NewEncoder(w).Encode(api.KnockResponse{
+			WhitelistedEntry: "1.2.3.4",
+			ExpiresAt:        time.Now().Add(2 * time.Second).Unix(),
+			ExpiresInSeconds: 2,
+		})
+	}))
+	defer server.Close()
+
+	// The IP never changes and the check interval is far away, so only the
+	// expiry can trigger the second knock.
+	service := NewService(api.NewClient(server.URL, "test-key"), &mockIPGetter{}, time.Hour, server.URL, 2, "check_interval", "test", logging.Discard())
+	done := make(chan struct{})
+	go func() {
+		service.Run(nil)
+		close(done)
+	}()
+
+	assert.Eventually(t, func() bool { return knocks.Load() >= 2 }, 5*time.Second, 20*time.Millisecond)
+	service.Stop()
+	<-done
+}
+
+func TestRefreshAtHonoursRefreshMargin(t *testing.T) {
+	now := time.Unix(1_750_000_000, 0)
+	s := scheduledService("https://ip.example", time.Hour, now.Add(10*time.Minute), 600)
+
+	s.RefreshMargin = 2 * time.Minute
+	refresh, ok := s.refreshAt()
+	assert.True(t, ok)
+	assert.Equal(t, now.Add(8*time.Minute), refresh)
+
+	// A margin longer than the TTL is capped at half of it.
+	s.RefreshMargin = time.Hour
+	refresh, _ = s.refreshAt()
+	assert.Equal(t, now.Add(5*time.Minute), refresh)
+	assert.True(t, s.refreshDue(now.Add(5*time.Minute)))
+}
+
+func TestServiceKnocksAgainAfterWhitelistExpired(t *testing.T) {
+	var knocks atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/knock" {
+			return
+		}
+		knocks.Add(1)
+		json.NewEncoder(w).Encode(api.KnockResponse{
+			WhitelistedEntry: "1.2.3.4",
+			ExpiresAt:        time.Now().Add(time.Hour).Unix(),
+			ExpiresInSeconds: 3600,
+		})
+	}))
+	defer server.Close()
+
+	now := time.Now()
+	s := scheduledService(server.URL, time.Hour, now.Add(-time.Second), 600)
+	s.APIClient = api.NewClient(server.URL, "test-key")
+	s.lastIP = "1.2.3.4"
+	sink := &recordingSink{}
+	s.Sink = sink
+
+	s.paused = true
+	s.tick(now)
+	assert.Zero(t, knocks.Load(), "no knock while paused")
+	assert.Len(t, sink.ofType(EventWhitelistExpired), 1)
+
+	s.whitelists["1.2.3.4"] = &whitelistState{IP: "1.2.3.4", ExpiresUnix: now.Add(-time.Second).Unix(), TTLSeconds: 600}
+	s.paused = false
+	s.tick(now)
+	assert.Equal(t, int32(1), knocks.Load(), "the IP is unchanged, yet the expiry triggers a knock")
+	triggered := sink.ofType(EventKnockTriggered)
+	if assert.Len(t, triggered, 1) {
+		assert.Equal(t, TriggerSourceExpiry, triggered[0].Fields["KNOCKER_TRIGGER_SOURCE"])
+	}
+	assert.True(t, s.Status().Whitelisted)
+
+	// Without an expiry, an unchanged IP is still left alone.
+	s.tick(time.Now())
+	assert.Equal(t, int32(1), knocks.Load())
+}
+
+func TestJitterOffset(t *testing.T) {
+	s := scheduledService("", time.Hour, time.Now(), 600)
+	assert.Zero(t, s.jitterOffset())
+
+	s.Jitter = time.Minute
+	for range 20 {
+		offset := s.jitterOffset()
+		assert.GreaterOrEqual(t, offset, time.Duration(0))
+		assert.Less(t, offset, time.Minute)
+	}
+
+	s.JitterSeed = "web-01"
+	first := s.jitterOffset()
+	assert.Equal(t, first, s.jitterOffset(), "a seeded offset is stable")
+	assert.Less(t, first, time.Minute)
+	s.JitterSeed = "web-02"
+	assert.NotEqual(t, first, s.jitterOffset(), "hosts get different slots")
+}
+
+func TestNextKnockAtJitterStaysBeforeExpiry(t *testing.T) {
+	now := time.Unix(1_750_000_000, 0)
+	s := scheduledService("", 9*time.Minute, now.Add(10*time.Minute), 600)
+	s.JitterSeed = "web-01"
+
+	s.Jitter = 20 * time.Second
+	offset := s.jitterOffset()
+	assert.Equal(t, now.Add(9*time.Minute+offset), s.nextKnockAt(now))
+
+	// However large the jitter, the knock lands halfway between the refresh
+	// time and the expiry at the latest.
+	s.Jitter = time.Hour
+	assert.Equal(t, now.Add(9*time.Minute+30*time.Second), s.nextKnockAt(now))
+
+	// Without a whitelist the cadence is simply delayed.
+	s.whitelists = nil
+	assert.Equal(t, now.Add(9*time.Minute+s.jitterOffset()), s.nextKnockAt(now))
+}
+
+func TestServiceDelaysFirstKnockByJitter(t *testing.T) {
+	var knocks atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		knocks.Add(1)
+	}))
+	defer server.Close()
+
+	service := NewService(api.NewClient(server.URL, "test-key"), &mockIPGetter{}, time.Hour, "", 3600, "ttl", "test", logging.Discard())
+	service.Jitter = time.Hour
+	service.JitterSeed = "web-01"
+	start := time.Now()
+	done := make(chan struct{})
+	go func() {
+		service.Run(nil)
+		close(done)
+	}()
+
+	assert.Eventually(t, func() bool { return service.Status().NextKnockUnix != 0 }, 5*time.Second, 10*time.Millisecond)
+	assert.InDelta(t, start.Add(service.jitterOffset()).Unix(), service.Status().NextKnockUnix, 1)
+	service.Stop()
+	<-done
+	assert.Zero(t, knocks.Load())
+}
@@ -0,0 +1,163 @@
+package service
+
+import (
+	"github.com/FarisZR/knocker-cli/internal/api"
+	"github.com/FarisZR/knocker-cli/internal/events"
+)
+
+// Field types used in the event schema. Every value is transported as a
+// string; the type says how to parse it.
+const (
+	FieldTypeString        = "string"
+	FieldTypeInteger       = "integer"
+	FieldTypeUnixTimestamp = "unix_timestamp"
+	FieldTypeEnum          = "enum"
+	FieldTypeJSON          = "json"
+)
+
+// SchemaField describes one structured field.
+type SchemaField struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Required bool   `json:"required,omitempty"`
+	// Since is the first schema version carrying the field.
+	Since       string   `json:"since"`
+	Values      []string `json:"values,omitempty"`
+	Description string   `json:"description"`
+}
+
+// SchemaEvent describes one event type and the fields specific to it.
+type SchemaEvent struct {
+	Type        string        `json:"type"`
+	Description string        `json:"description"`
+	Fields      []SchemaField `json:"fields"`
+}
+
+// EventSchema is the machine-readable description of the structured events,
+// printed by `knocker events schema`.
+type EventSchema struct {
+	Versions     []string      `json:"versions"`
+	Latest       string        `json:"latest"`
+	CommonFields []SchemaField `json:"common_fields"`
+	Events       []SchemaEvent `json:"events"`
+}
+
+// Schema returns the description of every event type and field.
+func Schema() EventSchema {
+	v1, v2 := events.SchemaV1, events.SchemaV2
+	return EventSchema{
+		Versions: []string{v1, v2},
+		Latest:   v2,
+		CommonFields: []SchemaField{
+			{Name: "KNOCKER_EVENT", Type: FieldTypeEnum, Required: true, Since: v1, Values: eventTypes(), Description: "Event type; determines the remaining fields."},
+			{Name: "KNOCKER_SCHEMA_VERSION", Type: FieldTypeString, Required: true, Since: v1, Values: []string{v1, v2}, Description: "Schema version of the entry."},
+			{Name: "SYSLOG_IDENTIFIER", Type: FieldTypeString, Required: true, Since: v1, Description: "Always \"knocker\"."},
+			{Name: "MESSAGE", Type: FieldTypeString, Required: true, Since: v1, Description: "Human-readable message."},
+			{Name: "PRIORITY", Type: FieldTypeInteger, Required: true, Since: v1, Description: "Syslog priority, 0 (emergency) to 7 (debug)."},
+			{Name: "KNOCKER_CYCLE_ID", Type: FieldTypeString, Since: v2, Description: "Identifies the knock cycle the event belongs to; the trace ID when tracing is enabled. Present on events emitted during a knock."},
+			{Name: "KNOCKER_ATTEMPT", Type: FieldTypeInteger, Since: v2, Description: "Knock attempt within the cycle, starting at 1."},
+			{Name: "KNOCKER_PROFILE", Type: FieldTypeString, Since: v2, Description: "Profile used for a manual knock, when one was selected."},
+		},
+		Events: []SchemaEvent{
+			{
+				Type:        EventServiceState,
+				Description: "Lifecycle transition of the service.",
+				Fields: []SchemaField{
+					{Name: "KNOCKER_SERVICE_STATE", Type: FieldTypeEnum, Required: true, Since: v1, Values: []string{ServiceStateStarted, ServiceStatePaused, ServiceStateResumed, ServiceStateStopping, ServiceStateStopped}, Description: "New service state."},
+					{Name: "KNOCKER_VERSION", Type: FieldTypeString, Since: v1, Description: "Knocker binary version."},
+				},
+			},
+			{
+				Type:        EventStatusSnapshot,
+				Description: "Current state; emitted at startup and whenever it changes materially.",
+				Fields: []SchemaField{
+					{Name: "KNOCKER_WHITELIST_IP", Type: FieldTypeString, Since: v1, Description: "Active whitelist entry."},
+					{Name: "KNOCKER_WHITELIST_IPS_JSON", Type: FieldTypeJSON, Since: v1, Description: "JSON array of every tracked entry, when more than one is tracked."},
+					{Name: "KNOCKER_EXPIRES_UNIX", Type: FieldTypeUnixTimestamp, Since: v1, Description: "Expiry of the active entry."},
+					{Name: "KNOCKER_TTL_SEC", Type: FieldTypeInteger, Since: v1, Description: "TTL granted by the API, in seconds."},
+					{Name: "KNOCKER_NEXT_AT_UNIX", Type: FieldTypeUnixTimestamp, Since: v1, Description: "Next scheduled knock."},
+					{Name: "KNOCKER_CADENCE_SOURCE", Type: FieldTypeEnum, Since: v1, Values: cadenceSources(), Description: "Where the knock cadence comes from."},
+					{Name: "KNOCKER_API_STATE", Type: FieldTypeEnum, Since: v1, Values: []string{string(api.BreakerClosed), string(api.BreakerOpen)}, Description: "Circuit breaker state; \"open\" while the API is unavailable."},
+					{Name: "KNOCKER_API_RETRY_AT_UNIX", Type: FieldTypeUnixTimestamp, Since: v1, Description: "Next probe of an unavailable API."},
+				},
+			},
+			{
+				Type:        EventWhitelistApplied,
+				Description: "A whitelist entry was applied; one event per entry.",
+				Fields: []SchemaField{
+					{Name: "KNOCKER_WHITELIST_IP", Type: FieldTypeString, Since: v1, Description: "Whitelisted IP or CIDR range."},
+					{Name: "KNOCKER_TTL_SEC", Type: FieldTypeInteger, Since: v1, Description: "TTL granted, in seconds."},
+					{Name: "KNOCKER_EXPIRES_UNIX", Type: FieldTypeUnixTimestamp, Since: v1, Description: "Expiry, when provided by the API."},
+					{Name: "KNOCKER_SOURCE", Type: FieldTypeEnum, Since: v1, Values: triggerSources(), Description: "What triggered the knock."},
+				},
+			},
+			{
+				Type:        EventWhitelistExpired,
+				Description: "A tracked whitelist entry expired.",
+				Fields: []SchemaField{
+					{Name: "KNOCKER_WHITELIST_IP", Type: FieldTypeString, Since: v1, Description: "Entry that expired."},
+					{Name: "KNOCKER_EXPIRED_UNIX", Type: FieldTypeUnixTimestamp, Since: v1, Description: "Time the entry expired."},
+				},
+			},
+			{
+				Type:        EventNextKnockUpdated,
+				Description: "The next scheduled knock changed.",
+				Fields: []SchemaField{
+					{Name: "KNOCKER_NEXT_AT_UNIX", Type: FieldTypeUnixTimestamp, Required: true, Since: v1, Description: "Next scheduled knock; \"0\" when the schedule is cleared."},
+					{Name: "KNOCKER_CADENCE_SOURCE", Type: FieldTypeEnum, Since: v1, Values: cadenceSources(), Description: "Where the knock cadence comes from."},
+				},
+			},
+			{
+				Type:        EventKnockTriggered,
+				Description: "A knock request was sent, manually or on schedule.",
+				Fields: []SchemaField{
+					{Name: "KNOCKER_TRIGGER_SOURCE", Type: FieldTypeEnum, Required: true, Since: v1, Values: triggerSources(), Description: "What triggered the knock."},
+					{Name: "KNOCKER_RESULT", Type: FieldTypeEnum, Required: true, Since: v1, Values: []string{ResultSuccess, ResultFailure}, Description: "Outcome of the knock."},
+					{Name: "KNOCKER_WHITELIST_IP", Type: FieldTypeString, Since: v1, Description: "Whitelisted entry on success."},
+					{Name: "KNOCKER_LATENCY_MS", Type: FieldTypeInteger, Since: v2, Description: "Duration of the knock request in milliseconds."},
+					{Name: "KNOCKER_HTTP_STATUS", Type: FieldTypeInteger, Since: v2, Description: "HTTP status returned by the API; absent when no response was received."},
+				},
+			},
+			{
+				Type:        EventError,
+				Description: "An operational error to surface to the user.",
+				Fields: []SchemaField{
+					{Name: "KNOCKER_ERROR_CODE", Type: FieldTypeEnum, Required: true, Since: v1, Values: []string{ErrorCodeIPLookup, ErrorCodeHealthCheck, ErrorCodeKnockFailed, ErrorCodeHookFailed}, Description: "Machine-readable error code."},
+					{Name: "KNOCKER_ERROR_MSG", Type: FieldTypeString, Required: true, Since: v1, Description: "Human-readable error."},
+					{Name: "KNOCKER_CONTEXT", Type: FieldTypeString, Since: v1, Description: "IP, URL or event type involved."},
+					{Name: "KNOCKER_HOOK_COMMAND", Type: FieldTypeString, Since: v1, Description: "Failed command, for hook_failed."},
+				},
+			},
+			{
+				Type:        EventAPIUnavailable,
+				Description: "The circuit breaker opened: the API stopped answering. Emitted once per outage instead of an Error per failed request.",
+				Fields: []SchemaField{
+					{Name: "KNOCKER_CONTEXT", Type: FieldTypeString, Required: true, Since: v1, Description: "API URL."},
+					{Name: "KNOCKER_FAILURES", Type: FieldTypeInteger, Required: true, Since: v1, Description: "Consecutive failed requests."},
+					{Name: "KNOCKER_ERROR_MSG", Type: FieldTypeString, Since: v1, Description: "Last error."},
+					{Name: "KNOCKER_RETRY_AT_UNIX", Type: FieldTypeUnixTimestamp, Since: v1, Description: "Next probe of the API."},
+				},
+			},
+			{
+				Type:        EventAPIRecovered,
+				Description: "The API answered again and the circuit breaker closed.",
+				Fields: []SchemaField{
+					{Name: "KNOCKER_CONTEXT", Type: FieldTypeString, Required: true, Since: v1, Description: "API URL."},
+					{Name: "KNOCKER_DOWNTIME_SEC", Type: FieldTypeInteger, Required: true, Since: v1, Description: "How long the API was unavailable, in seconds."},
+				},
+			},
+		},
+	}
+}
+
+func eventTypes() []string {
+	return []string{EventServiceState, EventStatusSnapshot, EventWhitelistApplied, EventWhitelistExpired, EventNextKnockUpdated, EventKnockTriggered, EventError, EventAPIUnavailable, EventAPIRecovered}
+}
+
+func triggerSources() []string {
+	return []string{TriggerSourceSchedule, TriggerSourceCLI, TriggerSourceExternal, TriggerSourceExpiry}
+}
+
+func cadenceSources() []string {
+	return []string{"ttl", "ttl_response", "check_interval"}
+}
@@ -0,0 +1,37 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/FarisZR/knocker-cli/internal/events"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSchemaDescribesEveryEventAndV2Field(t *testing.T) {
+	schema := Schema()
+
+	var described []string
+	for _, event := range schema.Events {
+		described = append(described, event.Type)
+	}
+	assert.Equal(t, eventTypes(), described)
+
+	since := map[string]string{}
+	for _, field := range schema.CommonFields {
+		since[field.Name] = field.Since
+	}
+	for _, event := range schema.Events {
+		for _, field := range event.Fields {
+			since[field.Name] = field.Since
+		}
+	}
+	for _, name := range events.V2Fields {
+		assert.Equal(t, events.SchemaV2, since[name], name)
+	}
+	// Anything else survives the v1 copy, so it must be declared as v1.
+	for name, version := range since {
+		if version == events.SchemaV2 {
+			assert.Contains(t, events.V2Fields, name)
+		}
+	}
+}
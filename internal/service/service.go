@@ -1,38 +1,113 @@
 package service
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/FarisZR/knocker-cli/internal/api"
+	"github.com/FarisZR/knocker-cli/internal/events"
+	"github.com/FarisZR/knocker-cli/internal/history"
+	"github.com/FarisZR/knocker-cli/internal/logging"
+	"github.com/FarisZR/knocker-cli/internal/metrics"
+	"github.com/FarisZR/knocker-cli/internal/telemetry"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = otel.Tracer("github.com/FarisZR/knocker-cli/internal/service")
+
+// knockCycle identifies one run of the knock logic and the attempt within it.
+type knockCycle struct {
+	ID      string
+	Attempt int
+}
+
 type IPGetter interface {
 	GetPublicIP(url string) (string, error)
 }
 
 type Service struct {
-	APIClient  *api.Client
-	IPGetter   IPGetter
-	Cadence    time.Duration
-	Logger     *log.Logger
-	cadenceSrc string
-	stop       chan struct{}
-	lastIP     string
-	ipCheckURL string
-	ttl        int
+	APIClient *api.Client
+	IPGetter  IPGetter
+	Cadence   time.Duration
+	// Logger receives the runtime log. Records written during a knock
+	// cycle carry its cycle_id and attempt.
+	Logger *slog.Logger
+	// RefreshMargin is how long before a whitelist expires it is refreshed
+	// at the latest. Without it the refresh leaves 10% of the TTL to spare.
+	RefreshMargin time.Duration
+	// Jitter spreads scheduled knocks, including the first one, over up to
+	// this long so hosts restarted together do not knock in lockstep.
+	Jitter time.Duration
+	// JitterSeed, when set, makes the jitter a fixed offset derived from it
+	// (the hostname) instead of a random delay.
+	JitterSeed string
+	// ExtraEntries are additional addresses or CIDR ranges whitelisted
+	// alongside this host on every knock.
+	ExtraEntries []string
+	// Sink receives the structured events; NewService defaults it to journald.
+	Sink events.EventSink
+	// Metrics, when set, is updated alongside the structured events.
+	Metrics *metrics.Metrics
+	// History, when set, records every knock attempt.
+	History *history.Store
+	// OnStatusChange, when set, is called with the new status whenever it is
+	// published: alongside every status snapshot, next-knock update and
+	// state change.
+	OnStatusChange func(Status)
+	cadenceSrc     string
+	stop           chan struct{}
+	lastIP         string
+	ipCheckURL     string
+	ttl            int
 
 	version          string
 	currentWhitelist *whitelistState
+	whitelists       map[string]*whitelistState
 	nextKnockUnix    int64
+	// cycle identifies the knock cycle in progress; events emitted during
+	// a cycle carry it. It is read by emit, which may run on other
+	// goroutines.
+	cycle atomic.Pointer[knockCycle]
+
+	// paused, failures, the number of consecutive failed knock cycles, and
+	// extrasFailures, the number of consecutive knocks that whitelisted the
+	// caller's address but not the extra entries, are only accessed from the
+	// Run loop.
+	paused         bool
+	failures       int
+	extrasFailures int
+
+	// apiState and apiRetryAt are the breaker state and probe time seen
+	// after the last cycle; apiDownSince is when the API became unavailable.
+	// They are only accessed from the Run loop.
+	apiState     api.BreakerState
+	apiRetryAt   time.Time
+	apiDownSince time.Time
+	requests     chan controlRequest
+
+	statusMu sync.RWMutex
+	status   Status
+	state    string
 
 	stopOnce     sync.Once
 	shutdownOnce sync.Once
 }
 
-func NewService(apiClient *api.Client, ipGetter IPGetter, cadence time.Duration, ipCheckURL string, ttl int, cadenceSource string, version string, logger *log.Logger) *Service {
+func NewService(apiClient *api.Client, ipGetter IPGetter, cadence time.Duration, ipCheckURL string, ttl int, cadenceSource string, version string, logger *slog.Logger) *Service {
+	if logger == nil {
+		logger = logging.Discard()
+	}
 	return &Service{
 		APIClient:  apiClient,
 		IPGetter:   ipGetter,
@@ -40,9 +115,11 @@ func NewService(apiClient *api.Client, ipGetter IPGetter, cadence time.Duration,
 		Logger:     logger,
 		cadenceSrc: cadenceSource,
 		stop:       make(chan struct{}),
+		requests:   make(chan controlRequest),
 		ipCheckURL: ipCheckURL,
 		ttl:        ttl,
 		version:    version,
+		Sink:       events.JournaldSink{},
 	}
 }
 
@@ -52,33 +129,39 @@ func (s *Service) Run(quit <-chan struct{}) {
 		source = "ttl"
 	}
 	if s.ipCheckURL == "" {
-		s.Logger.Printf("Service running. Knocking every %v (source: %s).", s.Cadence, source)
+		s.Logger.Info("Service running; knocking on a fixed cadence", "cadence", s.Cadence, "cadence_source", source)
 	} else {
-		s.Logger.Printf("Service running. Checking for IP changes every %v (source: %s).", s.Cadence, source)
+		s.Logger.Info("Service running; checking for IP changes", "cadence", s.Cadence, "cadence_source", source)
 	}
 
-	s.emitServiceState(ServiceStateStarted)
-	// Trigger the first knock immediately so the whitelist is refreshed on startup.
-	s.checkAndKnock()
-	s.updateNextKnock(time.Now().Add(s.Cadence))
+	s.setState(ServiceStateStarted)
+	var timer *time.Timer
+	if delay := s.jitterOffset(); delay > 0 {
+		s.Logger.Info("Delaying the first knock", "delay", delay)
+		timer = time.NewTimer(delay)
+		s.updateNextKnock(time.Now().Add(delay))
+	} else {
+		// Trigger the first knock immediately so the whitelist is refreshed on startup.
+		s.checkAndKnock()
+		timer = time.NewTimer(s.scheduleNext(time.Now()))
+	}
 	s.emitStatusSnapshot()
-
-	ticker := time.NewTicker(s.Cadence)
-	defer ticker.Stop()
+	defer timer.Stop()
 	defer func() {
 		s.clearNextKnock()
 		s.emitStatusSnapshot()
-		s.emitServiceState(ServiceStateStopped)
+		s.setState(ServiceStateStopped)
 	}()
 
 	for {
 		select {
-		case <-ticker.C:
-			now := time.Now()
-			s.checkWhitelistExpiry(now)
-			s.checkAndKnock()
-			ticker.Reset(s.Cadence)
-			s.updateNextKnock(time.Now().Add(s.Cadence))
+		case <-timer.C:
+			s.tick(time.Now())
+			timer.Reset(s.scheduleNext(time.Now()))
+		case req := <-s.requests:
+			if s.handleControl(req) {
+				timer.Reset(s.scheduleNext(time.Now()))
+			}
 		case <-quit:
 			s.NotifyStopping()
 			s.checkWhitelistExpiry(time.Now())
@@ -100,63 +183,177 @@ func (s *Service) Stop() {
 
 func (s *Service) NotifyStopping() {
 	s.shutdownOnce.Do(func() {
-		s.emitServiceState(ServiceStateStopping)
+		s.setState(ServiceStateStopping)
 	})
 }
 
+// tick runs the scheduled work when the Run loop's timer fires: expired
+// whitelists are reported and, unless paused, the service knocks. After an
+// expiry it knocks straight away, whatever the IP.
+func (s *Service) tick(now time.Time) {
+	expired := s.checkWhitelistExpiry(now)
+	switch {
+	case s.paused:
+	case expired:
+		s.Logger.Info("Knocking again after the whitelist expired")
+		_ = s.runKnockCycle(TriggerSourceExpiry, true)
+	default:
+		s.checkAndKnock()
+	}
+}
+
 func (s *Service) checkAndKnock() {
+	force := s.ipCheckURL != "" && s.refreshDue(time.Now())
+	if force {
+		expiry, _ := s.earliestExpiry()
+		s.Logger.Info("Whitelist expires soon; refreshing", "expires_unix", expiry.Unix())
+	} else if s.ipCheckURL != "" && s.extrasFailures > 0 {
+		// The IP has not changed, but the extra entries still need a knock.
+		force = true
+	}
+	_ = s.runKnockCycle(TriggerSourceSchedule, force)
+}
+
+// runKnockCycle runs one knock cycle triggered by source. With force set it
+// knocks even when comparison mode finds the IP unchanged.
+func (s *Service) runKnockCycle(source string, force bool) error {
+	mode := "simple"
+	if s.ipCheckURL != "" {
+		mode = "comparison"
+	}
+	ctx, span := tracer.Start(context.Background(), "knocker.check_and_knock", trace.WithAttributes(
+		attribute.String(telemetry.AttrTriggerSource, source),
+		attribute.String(telemetry.AttrMode, mode),
+		attribute.Int(telemetry.AttrAttempt, 1),
+	))
+	defer span.End()
+
+	s.cycle.Store(&knockCycle{ID: events.NewCycleID(ctx), Attempt: 1})
+	defer s.cycle.Store(nil)
+
+	result, err := s.knockIfNeeded(ctx, source, force)
+	span.SetAttributes(attribute.String(telemetry.AttrResult, result))
+	if err != nil {
+		s.failures++
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	} else {
+		s.failures = 0
+	}
+	s.observeBreaker()
+	return err
+}
+
+// knockIfNeeded runs one iteration of the service loop and reports its
+// result: success, failure, or unchanged when comparison mode found the same
+// IP as before and force is not set.
+func (s *Service) knockIfNeeded(ctx context.Context, source string, force bool) (string, error) {
 	if s.ipCheckURL == "" {
-		s.Logger.Println("Knocking without IP check...")
-		knockResponse, err := s.performKnock("", TriggerSourceSchedule)
+		if result, err := s.skipWhileAPIDown(); err != nil {
+			return result, err
+		}
+		s.log().Debug("Knocking without IP check", "trigger_source", source)
+		knockResponse, err := s.performKnock(ctx, "", source)
 		if err != nil {
-			s.Logger.Printf("Knock failed: %v", err)
-			return
+			s.log().Error("Knock failed", "error_code", ErrorCodeKnockFailed, "error_msg", err.Error())
+			return ResultFailure, err
 		}
 		if knockResponse != nil {
-			s.Logger.Printf("Successfully knocked. Whitelisted entry: %s (ttl: %d seconds)", knockResponse.WhitelistedEntry, knockResponse.ExpiresInSeconds)
+			s.log().Info("Successfully knocked", "whitelist_ip", knockResponse.WhitelistedEntry, "ttl_sec", knockResponse.ExpiresInSeconds)
 		}
-		return
+		return ResultSuccess, nil
 	}
 
-	ip, err := s.IPGetter.GetPublicIP(s.ipCheckURL)
+	ip, err := s.lookupIP(ctx)
+	s.Metrics.IPLookup(err)
 	if err != nil {
-		s.Logger.Printf("Error getting public IP: %v", err)
+		s.log().Error("Error getting public IP", "error_code", ErrorCodeIPLookup, "error_msg", err.Error(), "context", s.ipCheckURL)
 		s.emitError(ErrorCodeIPLookup, fmt.Sprintf("Error getting public IP: %v", err), s.ipCheckURL)
-		return
+		return ResultFailure, err
 	}
 
-	if ip == s.lastIP {
-		return
+	if ip == s.lastIP && !force {
+		return ResultUnchanged, nil
 	}
 
-	s.Logger.Printf("IP changed from %s to %s. Knocking...", s.lastIP, ip)
+	if result, err := s.skipWhileAPIDown(); err != nil {
+		return result, err
+	}
+	if ip != s.lastIP {
+		s.log().Info("IP changed; knocking", "previous_ip", s.lastIP, "ip", ip)
+	}
 
-	if err := s.APIClient.HealthCheck(); err != nil {
-		s.Logger.Printf("Health check failed: %v", err)
-		s.emitError(ErrorCodeHealthCheck, fmt.Sprintf("Health check failed: %v", err), s.APIClient.BaseURL)
-		return
+	start := time.Now()
+	err = s.APIClient.HealthCheckContext(ctx)
+	s.Metrics.ObserveAPIRequest("health_check", time.Since(start), err)
+	if err != nil {
+		s.Metrics.HealthCheckFailed()
+		s.log().Error("Health check failed", "error_code", ErrorCodeHealthCheck, "error_msg", err.Error(), "context", s.APIClient.BaseURL)
+		s.emitAPIError(ErrorCodeHealthCheck, fmt.Sprintf("Health check failed: %v", err), s.APIClient.BaseURL)
+		return ResultFailure, err
 	}
 
-	knockResponse, err := s.performKnock(ip, TriggerSourceSchedule)
+	knockResponse, err := s.performKnock(ctx, ip, source)
 	if err != nil {
-		s.Logger.Printf("Knock failed: %v", err)
-		return
+		s.log().Error("Knock failed", "error_code", ErrorCodeKnockFailed, "error_msg", err.Error(), "context", ip)
+		return ResultFailure, err
 	}
 
 	if knockResponse != nil {
-		s.Logger.Printf("Successfully knocked and updated IP. Whitelisted entry: %s (ttl: %d seconds)", knockResponse.WhitelistedEntry, knockResponse.ExpiresInSeconds)
+		s.log().Info("Successfully knocked and updated IP", "whitelist_ip", knockResponse.WhitelistedEntry, "ttl_sec", knockResponse.ExpiresInSeconds)
 	} else {
-		s.Logger.Println("Successfully knocked and updated IP.")
+		s.log().Info("Successfully knocked and updated IP", "ip", ip)
 	}
 
 	s.lastIP = ip
+	return ResultSuccess, nil
+}
+
+// skipWhileAPIDown returns ErrBreakerOpen, with the skipped result, when
+// the circuit breaker does not let a request through yet.
+func (s *Service) skipWhileAPIDown() (string, error) {
+	if retryAt, blocked := s.apiBlocked(time.Now()); blocked {
+		s.log().Debug("API unavailable; skipping knock", "retry_at", retryAt)
+		return ResultSkipped, api.ErrBreakerOpen
+	}
+	return "", nil
+}
+
+// lookupIP fetches the public IP inside its own span. The lookup service is
+// a third party, so no trace context is sent to it.
+func (s *Service) lookupIP(ctx context.Context) (string, error) {
+	_, span := tracer.Start(ctx, "knocker.ip_lookup", trace.WithAttributes(semconv.URLFull(s.ipCheckURL)))
+	defer span.End()
+
+	ip, err := s.IPGetter.GetPublicIP(s.ipCheckURL)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return ip, err
 }
 
-func (s *Service) performKnock(ip, source string) (*api.KnockResponse, error) {
-	knockResponse, err := s.APIClient.Knock(ip, s.ttl)
+func (s *Service) performKnock(ctx context.Context, ip, source string) (*api.KnockResponse, error) {
+	start := time.Now()
+	knockResponse, err := KnockWithExtras(ctx, s.APIClient, ip, s.ExtraEntries, s.ttl)
+	latency := time.Since(start)
+
+	// When only the extra entries failed, the caller's address is still
+	// whitelisted: the knock counts as a success and only the primary entry
+	// is tracked, while the extras are retried with their own back-off.
+	var extrasErr *ExtrasError
+	if errors.As(err, &extrasErr) {
+		err = nil
+		s.extrasFailures++
+	} else if err == nil {
+		s.extrasFailures = 0
+	}
+
+	s.Metrics.ObserveAPIRequest("knock", latency, err)
+	s.recordHistory(start, source, ip, latency, knockResponse, err)
 	if err != nil {
-		s.emitKnockTriggered(source, ResultFailure, ip)
-		s.emitError(ErrorCodeKnockFailed, fmt.Sprintf("Knock failed: %v", err), ip)
+		s.emitKnockTriggered(source, ResultFailure, ip, latency, err)
+		s.emitAPIError(ErrorCodeKnockFailed, fmt.Sprintf("Knock failed: %v", err), ip)
 		return nil, err
 	}
 
@@ -164,28 +361,86 @@ func (s *Service) performKnock(ip, source string) (*api.KnockResponse, error) {
 	if knockResponse != nil && knockResponse.WhitelistedEntry != "" {
 		whitelistIP = knockResponse.WhitelistedEntry
 	}
-	s.emitKnockTriggered(source, ResultSuccess, whitelistIP)
+	s.emitKnockTriggered(source, ResultSuccess, whitelistIP, latency, nil)
 
 	s.handleWhitelistResponse(knockResponse, source)
 
+	if extrasErr != nil {
+		extras := strings.Join(extrasErr.Extras, ",")
+		s.log().Warn("Knock succeeded but the extra entries were not whitelisted", "error_code", ErrorCodeKnockFailed, "error_msg", extrasErr.Err.Error(), "context", extras)
+		s.emitAPIError(ErrorCodeKnockFailed, fmt.Sprintf("Knock failed: %v", extrasErr), extras)
+	}
+
 	return knockResponse, nil
 }
 
+func (s *Service) recordHistory(start time.Time, source, ip string, latency time.Duration, knockResponse *api.KnockResponse, err error) {
+	if s.History == nil {
+		return
+	}
+	if err := s.History.Append(NewHistoryRecord(start, source, ip, latency, knockResponse, err)); err != nil {
+		s.log().Warn("Failed to record knock history", "error", err)
+	}
+}
+
+// NewHistoryRecord describes a knock attempt for the history store. A knock
+// whose extra entries failed (an *ExtrasError with a response) is recorded as
+// a success for the entries that were whitelisted.
+func NewHistoryRecord(start time.Time, source, ip string, latency time.Duration, knockResponse *api.KnockResponse, err error) history.Record {
+	record := history.Record{
+		Time:      start,
+		Source:    source,
+		Result:    ResultSuccess,
+		IP:        ip,
+		LatencyMS: latency.Milliseconds(),
+	}
+	var extrasErr *ExtrasError
+	if err != nil && !(errors.As(err, &extrasErr) && knockResponse != nil) {
+		record.Result = ResultFailure
+		record.ErrorCode = ErrorCodeKnockFailed
+		return record
+	}
+	if knockResponse != nil {
+		if knockResponse.WhitelistedEntry != "" {
+			record.IP = knockResponse.WhitelistedEntry
+		}
+		record.TTLSeconds = knockResponse.ExpiresInSeconds
+	}
+	return record
+}
+
 func (s *Service) handleWhitelistResponse(knockResponse *api.KnockResponse, source string) {
 	if knockResponse == nil {
 		return
 	}
 
-	s.currentWhitelist = &whitelistState{
-		IP:          knockResponse.WhitelistedEntry,
-		ExpiresUnix: knockResponse.ExpiresAt,
-		TTLSeconds:  knockResponse.ExpiresInSeconds,
-		Source:      source,
+	entries := knockResponse.Entries()
+	if len(entries) == 0 {
+		entries = []string{""}
+	}
+	primary := knockResponse.WhitelistedEntry
+	if primary == "" {
+		primary = entries[0]
+	}
+
+	if s.whitelists == nil {
+		s.whitelists = make(map[string]*whitelistState, len(entries))
+	}
+	for _, entry := range entries {
+		s.whitelists[entry] = &whitelistState{
+			IP:          entry,
+			ExpiresUnix: knockResponse.ExpiresAt,
+			TTLSeconds:  knockResponse.ExpiresInSeconds,
+			Source:      source,
+		}
 	}
+	s.currentWhitelist = s.whitelists[primary]
 
 	s.adjustCadenceForTTL(knockResponse.ExpiresInSeconds)
 
-	s.emitWhitelistApplied(knockResponse.WhitelistedEntry, knockResponse.ExpiresInSeconds, knockResponse.ExpiresAt, source)
+	for _, entry := range entries {
+		s.emitWhitelistApplied(entry, knockResponse.ExpiresInSeconds, knockResponse.ExpiresAt, source)
+	}
 	s.emitStatusSnapshot()
 }
 
@@ -204,35 +459,59 @@ func (s *Service) adjustCadenceForTTL(ttlSeconds int) {
 
 	s.Cadence = newCadence
 	s.cadenceSrc = "ttl_response"
-	s.Logger.Printf("Adjusted knock cadence to %v based on server TTL (%ds).", newCadence, ttlSeconds)
+	s.Logger.Info("Adjusted knock cadence to the server TTL", "cadence", newCadence, "ttl_sec", ttlSeconds, "cadence_source", s.cadenceSrc)
 }
 
-func (s *Service) checkWhitelistExpiry(now time.Time) {
-	if s.currentWhitelist == nil {
-		return
-	}
+// checkWhitelistExpiry drops the whitelists that expired by now, emitting
+// WhitelistExpired for each, and reports whether any did.
+func (s *Service) checkWhitelistExpiry(now time.Time) bool {
+	expired := false
+	for _, entry := range s.trackedEntries() {
+		state := s.whitelists[entry]
+		if state.ExpiresUnix <= 0 || now.Unix() < state.ExpiresUnix {
+			continue
+		}
 
-	if s.currentWhitelist.ExpiresUnix <= 0 {
-		return
-	}
+		delete(s.whitelists, entry)
+		if s.currentWhitelist == state {
+			s.currentWhitelist = nil
+		}
+		expired = true
 
-	if now.Unix() < s.currentWhitelist.ExpiresUnix {
-		return
-	}
+		ip := state.IP
+		expiredUnix := state.ExpiresUnix
+		attrs := []any{}
+		if ip != "" {
+			attrs = append(attrs, "whitelist_ip", ip)
+		}
+		if expiredUnix > 0 {
+			attrs = append(attrs, "expired_unix", expiredUnix)
+		}
+		s.Logger.Warn("Whitelist expired", attrs...)
 
-	ip := s.currentWhitelist.IP
-	expiredUnix := s.currentWhitelist.ExpiresUnix
+		s.emitWhitelistExpired(ip, expiredUnix)
+	}
 
-	s.currentWhitelist = nil
+	if expired {
+		s.emitStatusSnapshot()
+	}
+	return expired
+}
 
-	if ip != "" && expiredUnix > 0 {
-		s.Logger.Printf("Whitelist expired for %s at %s", ip, time.Unix(expiredUnix, 0).UTC().Format(time.RFC3339))
-	} else if ip != "" {
-		s.Logger.Printf("Whitelist expired for %s", ip)
-	} else {
-		s.Logger.Println("Whitelist expired")
+// log returns the service logger, tagged with the knock cycle in progress.
+func (s *Service) log() *slog.Logger {
+	if cycle := s.cycle.Load(); cycle != nil {
+		return s.Logger.With("cycle_id", cycle.ID, "attempt", cycle.Attempt)
 	}
+	return s.Logger
+}
 
-	s.emitWhitelistExpired(ip, expiredUnix)
-	s.emitStatusSnapshot()
+// trackedEntries returns the whitelisted entries in a stable order.
+func (s *Service) trackedEntries() []string {
+	entries := make([]string, 0, len(s.whitelists))
+	for entry := range s.whitelists {
+		entries = append(entries, entry)
+	}
+	sort.Strings(entries)
+	return entries
 }
@@ -1,16 +1,29 @@
 package service
 
 import (
+	"bytes"
 	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
-	"os"
+	"path/filepath"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/FarisZR/knocker-cli/internal/api"
+	"github.com/FarisZR/knocker-cli/internal/events"
+	"github.com/FarisZR/knocker-cli/internal/history"
+	"github.com/FarisZR/knocker-cli/internal/logging"
+	"github.com/FarisZR/knocker-cli/internal/metrics"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
 // Mocking the dependencies
@@ -48,7 +61,7 @@ func TestServiceRun(t *testing.T) {
 		3600,
 		"check_interval",
 		"test",
-		log.New(os.Stdout, "test: ", log.LstdFlags),
+		logging.Discard(),
 	)
 
 	// Create a quit channel for the test
@@ -99,7 +112,7 @@ func TestServiceKnocksImmediatelyOnStart(t *testing.T) {
 		3600,
 		"ttl",
 		"test",
-		log.New(os.Stdout, "test: ", log.LstdFlags),
+		logging.Discard(),
 	)
 
 	quit := make(chan struct{})
@@ -116,7 +129,7 @@ func TestServiceKnocksImmediatelyOnStart(t *testing.T) {
 }
 
 func TestServiceAdjustsCadenceFromServerTTL(t *testing.T) {
-	logger := log.New(os.Stdout, "test: ", log.LstdFlags)
+	logger := logging.Discard()
 	service := NewService(
 		nil,
 		&mockIPGetter{},
@@ -146,7 +159,7 @@ func TestServiceAdjustsCadenceFromServerTTL(t *testing.T) {
 }
 
 func TestServiceDoesNotAdjustCadenceWhenIPCheckEnabled(t *testing.T) {
-	logger := log.New(os.Stdout, "test: ", log.LstdFlags)
+	logger := logging.Discard()
 	service := NewService(
 		nil,
 		&mockIPGetter{},
@@ -173,3 +186,285 @@ func TestServiceDoesNotAdjustCadenceWhenIPCheckEnabled(t *testing.T) {
 		t.Fatalf("expected cadence source check_interval, got %s", service.cadenceSrc)
 	}
 }
+
+func TestServiceTracksEachWhitelistedEntry(t *testing.T) {
+	logger := logging.Discard()
+	service := NewService(nil, &mockIPGetter{}, 5*time.Minute, "", 600, "ttl", "test", logger)
+
+	now := time.Now()
+	service.handleWhitelistResponse(&api.KnockResponse{
+		WhitelistedEntry:   "1.2.3.4",
+		WhitelistedEntries: []string{"1.2.3.4", "203.0.113.0/29"},
+		ExpiresAt:          now.Add(time.Minute).Unix(),
+		ExpiresInSeconds:   60,
+	}, TriggerSourceSchedule)
+
+	assert.Len(t, service.whitelists, 2)
+	assert.Equal(t, "1.2.3.4", service.currentWhitelist.IP)
+
+	service.whitelists["203.0.113.0/29"].ExpiresUnix = now.Add(-time.Second).Unix()
+	service.checkWhitelistExpiry(now)
+
+	assert.Len(t, service.whitelists, 1)
+	assert.NotNil(t, service.currentWhitelist)
+
+	service.checkWhitelistExpiry(now.Add(2 * time.Minute))
+
+	assert.Empty(t, service.whitelists)
+	assert.Nil(t, service.currentWhitelist)
+}
+
+type recordingSink struct {
+	mu     sync.Mutex
+	events []events.Event
+}
+
+func (r *recordingSink) Emit(event events.Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+	return nil
+}
+
+func (r *recordingSink) ofType(eventType string) []events.Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var matched []events.Event
+	for _, event := range r.events {
+		if event.Type == eventType {
+			matched = append(matched, event)
+		}
+	}
+	return matched
+}
+
+func TestServiceEmitsEventsToConfiguredSink(t *testing.T) {
+	logger := logging.Discard()
+	service := NewService(nil, &mockIPGetter{}, 5*time.Minute, "", 600, "ttl", "test", logger)
+	sink := &recordingSink{}
+	service.Sink = sink
+
+	service.handleWhitelistResponse(&api.KnockResponse{
+		WhitelistedEntries: []string{"1.2.3.4", "203.0.113.0/29"},
+		ExpiresAt:          time.Now().Add(time.Minute).Unix(),
+		ExpiresInSeconds:   60,
+	}, TriggerSourceSchedule)
+
+	applied := sink.ofType(EventWhitelistApplied)
+	assert.Len(t, applied, 2)
+	assert.Equal(t, "203.0.113.0/29", applied[1].Fields["KNOCKER_WHITELIST_IP"])
+
+	snapshots := sink.ofType(EventStatusSnapshot)
+	assert.Len(t, snapshots, 1)
+	assert.Equal(t, `["1.2.3.4","203.0.113.0/29"]`, snapshots[0].Fields["KNOCKER_WHITELIST_IPS_JSON"])
+}
+
+func TestServiceUpdatesMetrics(t *testing.T) {
+	expires := time.Now().Add(time.Hour).Unix()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/health":
+			w.WriteHeader(http.StatusOK)
+		case "/knock":
+			json.NewEncoder(w).Encode(api.KnockResponse{
+				WhitelistedEntry: "1.2.3.4",
+				ExpiresAt:        expires,
+				ExpiresInSeconds: 3600,
+			})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	logger := logging.Discard()
+	service := NewService(api.NewClient(server.URL, "test-key"), &mockIPGetter{}, 5*time.Minute, server.URL, 3600, "check_interval", "test", logger)
+	service.Sink = &recordingSink{}
+	service.Metrics = metrics.New()
+
+	service.checkAndKnock()
+	service.updateNextKnock(time.Unix(expires, 0))
+
+	rec := httptest.NewRecorder()
+	service.Metrics.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := io.ReadAll(rec.Body)
+
+	assert.Contains(t, string(body), `knocker_knocks_total{result="success",source="schedule"} 1`)
+	assert.Contains(t, string(body), `knocker_ip_lookups_total{result="success"} 1`)
+	assert.Contains(t, string(body), `knocker_api_request_duration_seconds_count{operation="health_check",result="success"} 1`)
+	assert.Contains(t, string(body), `knocker_api_request_duration_seconds_count{operation="knock",result="success"} 1`)
+	assert.Contains(t, string(body), "knocker_cadence_seconds 300")
+}
+
+func TestServicePublishesStatus(t *testing.T) {
+	logger := logging.Discard()
+	service := NewService(nil, &mockIPGetter{}, 5*time.Minute, "", 600, "ttl", "test", logger)
+	service.Sink = &recordingSink{}
+
+	now := time.Now()
+	assert.False(t, service.Status().Ready(now))
+
+	service.handleWhitelistResponse(&api.KnockResponse{
+		WhitelistedEntry: "1.2.3.4",
+		ExpiresAt:        now.Add(time.Minute).Unix(),
+		ExpiresInSeconds: 60,
+	}, TriggerSourceSchedule)
+	service.updateNextKnock(now.Add(54 * time.Second))
+
+	status := service.Status()
+	assert.Equal(t, "1.2.3.4", status.WhitelistIP)
+	assert.Equal(t, now.Add(54*time.Second).Unix(), status.NextKnockUnix)
+	assert.True(t, status.Ready(now))
+	assert.False(t, status.Ready(now.Add(2*time.Minute)))
+
+	service.checkWhitelistExpiry(now.Add(2 * time.Minute))
+	assert.False(t, service.Status().Whitelisted)
+}
+
+func TestServiceTracesCheckAndKnock(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	defer otel.SetTracerProvider(previous)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(api.KnockResponse{WhitelistedEntry: "1.2.3.4", ExpiresInSeconds: 600})
+	}))
+	defer server.Close()
+
+	logger := logging.Discard()
+	service := NewService(api.NewClient(server.URL, "test-key"), &mockIPGetter{}, 5*time.Minute, server.URL, 600, "check_interval", "test", logger)
+	service.Sink = &recordingSink{}
+
+	service.checkAndKnock()
+	service.checkAndKnock()
+
+	var iterations []sdktrace.ReadOnlySpan
+	names := map[string]int{}
+	for _, span := range recorder.Ended() {
+		names[span.Name()]++
+		if span.Name() == "knocker.check_and_knock" {
+			iterations = append(iterations, span)
+		}
+	}
+	assert.Equal(t, 2, names["knocker.ip_lookup"])
+	assert.Equal(t, 1, names["knocker.api knock"])
+
+	results := []string{}
+	for _, span := range iterations {
+		for _, attr := range span.Attributes() {
+			if attr.Key == "knocker.result" {
+				results = append(results, attr.Value.AsString())
+			}
+		}
+	}
+	assert.Equal(t, []string{ResultSuccess, ResultUnchanged}, results)
+}
+
+func TestServiceRecordsKnockHistory(t *testing.T) {
+	failing := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		json.NewEncoder(w).Encode(api.KnockResponse{WhitelistedEntry: "1.2.3.4", ExpiresInSeconds: 600})
+	}))
+	defer server.Close()
+
+	logger := logging.Discard()
+	service := NewService(api.NewClient(server.URL, "test-key"), &mockIPGetter{}, 5*time.Minute, "", 600, "ttl", "test", logger)
+	service.Sink = &recordingSink{}
+	service.History = history.Open(filepath.Join(t.TempDir(), "history.jsonl"), 10)
+
+	service.checkAndKnock()
+	failing = true
+	service.checkAndKnock()
+
+	records, err := service.History.Load()
+	assert.NoError(t, err)
+	if assert.Len(t, records, 2) {
+		assert.Equal(t, ResultSuccess, records[0].Result)
+		assert.Equal(t, "1.2.3.4", records[0].IP)
+		assert.Equal(t, 600, records[0].TTLSeconds)
+		assert.Equal(t, TriggerSourceSchedule, records[0].Source)
+		assert.Equal(t, ResultFailure, records[1].Result)
+		assert.Equal(t, ErrorCodeKnockFailed, records[1].ErrorCode)
+	}
+}
+
+func TestServiceTagsEventsWithKnockCycle(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(api.KnockResponse{
+			WhitelistedEntry: "1.2.3.4",
+			ExpiresAt:        time.Now().Add(time.Hour).Unix(),
+			ExpiresInSeconds: 3600,
+		})
+	}))
+	defer server.Close()
+
+	logger := logging.Discard()
+	service := NewService(api.NewClient(server.URL, "test-key"), &mockIPGetter{}, 5*time.Minute, "", 3600, "ttl", "test", logger)
+	sink := &recordingSink{}
+	service.Sink = sink
+
+	service.checkAndKnock()
+	service.updateNextKnock(time.Now().Add(time.Hour))
+
+	knocks := sink.ofType(EventKnockTriggered)
+	require.Len(t, knocks, 1)
+	cycleID := knocks[0].Fields["KNOCKER_CYCLE_ID"]
+	assert.Len(t, cycleID, 32)
+	assert.Equal(t, "1", knocks[0].Fields["KNOCKER_ATTEMPT"])
+	assert.Equal(t, "200", knocks[0].Fields["KNOCKER_HTTP_STATUS"])
+	assert.Contains(t, knocks[0].Fields, "KNOCKER_LATENCY_MS")
+
+	applied := sink.ofType(EventWhitelistApplied)
+	require.Len(t, applied, 1)
+	assert.Equal(t, cycleID, applied[0].Fields["KNOCKER_CYCLE_ID"])
+
+	// Events raised outside a knock cycle carry no cycle fields.
+	next := sink.ofType(EventNextKnockUpdated)
+	require.Len(t, next, 1)
+	assert.NotContains(t, next[0].Fields, "KNOCKER_CYCLE_ID")
+}
+
+func TestKnockHTTPStatus(t *testing.T) {
+	assert.Equal(t, http.StatusOK, KnockHTTPStatus(nil))
+	assert.Equal(t, http.StatusForbidden, KnockHTTPStatus(fmt.Errorf("knock: %w", &api.StatusError{Operation: "knock", StatusCode: http.StatusForbidden})))
+	assert.Zero(t, KnockHTTPStatus(errors.New("connection refused")))
+}
+
+func TestServiceLogsCarryStructuredAttributes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(api.KnockResponse{WhitelistedEntry: "1.2.3.4", ExpiresInSeconds: 3600})
+	}))
+	defer server.Close()
+
+	var buf bytes.Buffer
+	service := NewService(api.NewClient(server.URL, "test-key"), &mockIPGetter{}, 5*time.Minute, "", 3600, "ttl", "test", logging.New(&buf, slog.LevelDebug, logging.FormatJSON))
+	service.Sink = &recordingSink{}
+	service.checkAndKnock()
+
+	records := map[string]map[string]any{}
+	for _, line := range bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n")) {
+		var record map[string]any
+		require.NoError(t, json.Unmarshal(line, &record))
+		records[record["msg"].(string)] = record
+	}
+
+	knocking := records["Knocking without IP check"]
+	require.NotNil(t, knocking)
+	assert.Equal(t, "DEBUG", knocking["level"])
+	assert.Equal(t, TriggerSourceSchedule, knocking["trigger_source"])
+	assert.Len(t, knocking["cycle_id"], 32)
+	assert.EqualValues(t, 1, knocking["attempt"])
+
+	knocked := records["Successfully knocked"]
+	require.NotNil(t, knocked)
+	assert.Equal(t, "INFO", knocked["level"])
+	assert.Equal(t, "1.2.3.4", knocked["whitelist_ip"])
+	assert.EqualValues(t, 3600, knocked["ttl_sec"])
+	assert.Equal(t, knocking["cycle_id"], knocked["cycle_id"])
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"time"
+
+	"github.com/FarisZR/knocker-cli/internal/api"
+)
+
+// Status is a point-in-time view of the service, mirroring the fields of the
+// StatusSnapshot event. It is safe to read from other goroutines via
+// Service.Status.
+type Status struct {
+	// State is the lifecycle state: started, paused, stopping or stopped.
+	State         string   `json:"state,omitempty"`
+	Whitelisted   bool     `json:"whitelisted"`
+	WhitelistIP   string   `json:"whitelist_ip,omitempty"`
+	WhitelistIPs  []string `json:"whitelist_ips,omitempty"`
+	ExpiresUnix   int64    `json:"expires_unix,omitempty"`
+	TTLSeconds    int      `json:"ttl_sec,omitempty"`
+	NextKnockUnix int64    `json:"next_at_unix,omitempty"`
+	CadenceSource string   `json:"cadence_source,omitempty"`
+	// APIState is the circuit breaker state: closed, or open while the API
+	// is unavailable. It is empty without a breaker.
+	APIState       string `json:"api_state,omitempty"`
+	APIRetryAtUnix int64  `json:"api_retry_at_unix,omitempty"`
+}
+
+// Ready reports whether a whitelist is active and unexpired at now.
+func (st Status) Ready(now time.Time) bool {
+	if !st.Whitelisted {
+		return false
+	}
+	return st.ExpiresUnix <= 0 || now.Unix() < st.ExpiresUnix
+}
+
+// Status returns the state published with the latest status snapshot or
+// next-knock update.
+func (s *Service) Status() Status {
+	s.statusMu.RLock()
+	defer s.statusMu.RUnlock()
+	return s.status
+}
+
+func (s *Service) publishStatus() {
+	st := Status{
+		NextKnockUnix: s.nextKnockUnix,
+		CadenceSource: s.cadenceSrc,
+		APIState:      string(s.apiState),
+	}
+	if s.apiState == api.BreakerOpen && !s.apiRetryAt.IsZero() {
+		st.APIRetryAtUnix = s.apiRetryAt.Unix()
+	}
+	if s.currentWhitelist != nil {
+		st.Whitelisted = true
+		st.WhitelistIP = s.currentWhitelist.IP
+		st.ExpiresUnix = s.currentWhitelist.ExpiresUnix
+		st.TTLSeconds = s.currentWhitelist.TTLSeconds
+	}
+	if len(s.whitelists) > 1 {
+		st.WhitelistIPs = s.trackedEntries()
+	}
+
+	s.statusMu.Lock()
+	st.State = s.state
+	s.status = st
+	s.statusMu.Unlock()
+
+	s.notifyStatus(st)
+}
+
+func (s *Service) notifyStatus(st Status) {
+	if s.OnStatusChange != nil {
+		s.OnStatusChange(st)
+	}
+}
@@ -0,0 +1,109 @@
+// Package telemetry exports traces and metrics to an OpenTelemetry collector
+// over OTLP/HTTP. Instrumented packages use the global otel providers, which
+// stay no-ops until SetupTracing is called.
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	promBridge "go.opentelemetry.io/contrib/bridges/prometheus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+)
+
+// Attribute keys shared by the instrumented packages.
+const (
+	AttrProfile       = "knocker.profile"
+	AttrTriggerSource = "knocker.trigger_source"
+	AttrResult        = "knocker.result"
+	AttrAttempt       = "knocker.attempt"
+	AttrMode          = "knocker.mode"
+)
+
+// Config describes where to export telemetry.
+type Config struct {
+	// Endpoint is the collector's base URL, such as http://localhost:4318.
+	// Signals are sent to /v1/traces and /v1/metrics below it.
+	Endpoint    string
+	Headers     map[string]string
+	ServiceName string
+	Version     string
+}
+
+// Shutdown flushes pending telemetry and stops the exporters.
+type Shutdown func(context.Context) error
+
+// SetupTracing installs a global tracer provider exporting to cfg.Endpoint
+// and the W3C trace context propagator, so outgoing API requests carry a
+// traceparent header.
+func SetupTracing(ctx context.Context, cfg Config) (Shutdown, error) {
+	exporter, err := otlptracehttp.New(ctx,
+		otlptracehttp.WithEndpointURL(signalURL(cfg.Endpoint, "traces")),
+		otlptracehttp.WithHeaders(cfg.Headers),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(newResource(cfg)),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	return provider.Shutdown, nil
+}
+
+// StartMetricsExport periodically pushes everything gatherer collects (the
+// service's Prometheus metrics) to cfg.Endpoint.
+func StartMetricsExport(ctx context.Context, cfg Config, gatherer prometheus.Gatherer, interval time.Duration) (Shutdown, error) {
+	if gatherer == nil {
+		return nil, errors.New("no metrics to export")
+	}
+
+	exporter, err := otlpmetrichttp.New(ctx,
+		otlpmetrichttp.WithEndpointURL(signalURL(cfg.Endpoint, "metrics")),
+		otlpmetrichttp.WithHeaders(cfg.Headers),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := sdkmetric.NewPeriodicReader(exporter,
+		sdkmetric.WithInterval(interval),
+		sdkmetric.WithProducer(promBridge.NewMetricProducer(promBridge.WithGatherer(gatherer))),
+	)
+	provider := sdkmetric.NewMeterProvider(
+		sdkmetric.WithReader(reader),
+		sdkmetric.WithResource(newResource(cfg)),
+	)
+	return provider.Shutdown, nil
+}
+
+func newResource(cfg Config) *resource.Resource {
+	name := cfg.ServiceName
+	if name == "" {
+		name = "knocker"
+	}
+	attrs := []attribute.KeyValue{semconv.ServiceName(name)}
+	if cfg.Version != "" {
+		attrs = append(attrs, semconv.ServiceVersion(cfg.Version))
+	}
+	return resource.NewWithAttributes(semconv.SchemaURL, attrs...)
+}
+
+func signalURL(endpoint, signal string) string {
+	return strings.TrimRight(endpoint, "/") + "/v1/" + signal
+}
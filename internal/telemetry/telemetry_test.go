@@ -0,0 +1,142 @@
+package telemetry
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/FarisZR/knocker-cli/internal/api"
+	"github.com/FarisZR/knocker-cli/internal/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	collectormetrics "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	collectortrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// collector is an in-process stand-in for an OTLP/HTTP collector.
+type collector struct {
+	mu      sync.Mutex
+	traces  []*collectortrace.ExportTraceServiceRequest
+	metrics []*collectormetrics.ExportMetricsServiceRequest
+	headers []http.Header
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.headers = append(c.headers, r.Header.Clone())
+
+	switch r.URL.Path {
+	case "/v1/traces":
+		req := &collectortrace.ExportTraceServiceRequest{}
+		if err := proto.Unmarshal(body, req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		c.traces = append(c.traces, req)
+	case "/v1/metrics":
+		req := &collectormetrics.ExportMetricsServiceRequest{}
+		if err := proto.Unmarshal(body, req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		c.metrics = append(c.metrics, req)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-protobuf")
+}
+
+func (c *collector) spanNames() map[string]string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	names := map[string]string{}
+	for _, req := range c.traces {
+		for _, rs := range req.ResourceSpans {
+			for _, ss := range rs.ScopeSpans {
+				for _, span := range ss.Spans {
+					names[span.Name] = hex.EncodeToString(span.TraceId)
+				}
+			}
+		}
+	}
+	return names
+}
+
+func TestSetupTracingExportsSpansAndPropagatesContext(t *testing.T) {
+	col := &collector{}
+	collectorServer := httptest.NewServer(col)
+	defer collectorServer.Close()
+
+	var traceparent string
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		json.NewEncoder(w).Encode(api.KnockResponse{WhitelistedEntry: "203.0.113.10", ExpiresInSeconds: 600})
+	}))
+	defer apiServer.Close()
+
+	shutdown, err := SetupTracing(context.Background(), Config{
+		Endpoint: collectorServer.URL,
+		Headers:  map[string]string{"Authorization": "Bearer token"},
+		Version:  "test",
+	})
+	require.NoError(t, err)
+
+	ctx, span := otel.Tracer("test").Start(context.Background(), "knocker.check_and_knock")
+	_, err = api.NewClient(apiServer.URL, "key").KnockContext(ctx, "", 0)
+	require.NoError(t, err)
+	span.End()
+
+	require.NoError(t, shutdown(context.Background()))
+
+	names := col.spanNames()
+	require.Contains(t, names, "knocker.check_and_knock")
+	require.Contains(t, names, "knocker.api knock")
+	traceID := names["knocker.check_and_knock"]
+	assert.Equal(t, traceID, names["knocker.api knock"])
+
+	// W3C traceparent: version-traceid-spanid-flags.
+	parts := strings.Split(traceparent, "-")
+	require.Len(t, parts, 4)
+	assert.Equal(t, traceID, parts[1])
+	assert.Equal(t, "Bearer token", col.headers[0].Get("Authorization"))
+}
+
+func TestStartMetricsExportPushesServiceMetrics(t *testing.T) {
+	col := &collector{}
+	collectorServer := httptest.NewServer(col)
+	defer collectorServer.Close()
+
+	m := metrics.New()
+	m.KnockTriggered("schedule", "success")
+
+	shutdown, err := StartMetricsExport(context.Background(), Config{Endpoint: collectorServer.URL}, m.Gatherer(), time.Hour)
+	require.NoError(t, err)
+	require.NoError(t, shutdown(context.Background()))
+
+	col.mu.Lock()
+	defer col.mu.Unlock()
+	var names []string
+	for _, req := range col.metrics {
+		for _, rm := range req.ResourceMetrics {
+			for _, sm := range rm.ScopeMetrics {
+				for _, metric := range sm.Metrics {
+					names = append(names, metric.Name)
+				}
+			}
+		}
+	}
+	assert.Contains(t, names, "knocker_knocks_total")
+}